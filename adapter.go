@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Map adapts a cache to the method set of sync.Map, for code written against it.
+// Items are stored indefinitely.
+type Map struct {
+	cache Cache
+}
+
+// AsMap returns instance as a Map.
+func AsMap(instance Cache) *Map {
+	return &Map{cache: instance}
+}
+
+// Load returns the item of key, reporting whether it exists.
+func (r *Map) Load(key string) (any, bool) {
+	return r.cache.GetExists(key)
+}
+
+// Store stores value under key.
+func (r *Map) Store(key string, value any) {
+	_ = r.cache.Put(key, value)
+}
+
+// LoadOrStore returns the item of key if it exists, otherwise it stores and returns value.
+// The loaded result is true if the item was loaded, false if stored. If the cache refuses to
+// store the item, e.g. for exceeding its quota, value is returned unstored, as Store drops errors.
+func (r *Map) LoadOrStore(key string, value any) (any, bool) {
+	// An item forgotten between Add and GetExists is retried, a few times at most.
+	for i := 0; i < 3; i++ {
+		if r.cache.Add(key, value, NoExpiration) {
+			return value, false
+		}
+		if actual, ok := r.cache.GetExists(key); ok {
+			return actual, true
+		}
+	}
+
+	return value, false
+}
+
+// LoadAndDelete deletes the item of key, returning it and reporting whether it existed.
+func (r *Map) LoadAndDelete(key string) (any, bool) {
+	val, ok := r.cache.GetExists(key)
+	if ok {
+		r.cache.Forget(key)
+	}
+
+	return val, ok
+}
+
+// Delete deletes the item of key.
+func (r *Map) Delete(key string) {
+	r.cache.Forget(key)
+}
+
+type cachingTransport struct {
+	cache Cache
+	next  http.RoundTripper
+	ttl   time.Duration
+}
+
+// RoundTripper returns an http.RoundTripper caching the 200 responses to GET requests made
+// through next, http.DefaultTransport if nil, so an http.Client using it serves them from
+// instance. Responses are kept for their max-age or Expires, at most ttl, and for ttl when they
+// give neither. Those with a Cache-Control of no-store, no-cache or private aren't cached, nor
+// are the responses to requests with an Authorization or Cookie header unless marked public.
+// Responses are keyed on the method, the URL and the request headers their Vary names, under
+// "roundtrip:"; a Vary of * isn't cached.
+func RoundTripper(instance Cache, next http.RoundTripper, ttl time.Duration) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &cachingTransport{cache: instance, next: next, ttl: ttl}
+}
+
+func (r *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return r.next.RoundTrip(req)
+	}
+
+	// The base key holds the header names the response varies with, the variant key the response.
+	base := "roundtrip:" + req.Method + " " + req.URL.String()
+	credentials := req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != ""
+	request := cacheControl(req.Header)
+	if _, ok := request["no-cache"]; !ok {
+		if vary, ok := r.cache.Get(base).([]string); ok {
+			if data, ok := r.cache.Get(varyKey(base, vary, req)).([]byte); ok {
+				if resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req); err == nil {
+					if _, public := cacheControl(resp.Header)["public"]; public || !credentials {
+						return resp, nil
+					}
+					_ = resp.Body.Close()
+				}
+			}
+		}
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	if _, ok := request["no-store"]; ok {
+		return resp, nil
+	}
+	control := cacheControl(resp.Header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := control[directive]; ok {
+			return resp, nil
+		}
+	}
+	if _, public := control["public"]; credentials && !public {
+		return resp, nil
+	}
+	vary := []string{}
+	for _, value := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name == "*" {
+				return resp, nil
+			} else if name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	ttl := r.lifetime(resp, control)
+	if ttl <= 0 {
+		return resp, nil
+	}
+
+	// DumpResponse reads the body and replaces it with the copy it took.
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, err
+	}
+	if r.cache.Put(varyKey(base, vary, req), data, WithTTL(ttl)) == nil {
+		_ = r.cache.Put(base, vary, WithTTL(ttl))
+	}
+
+	return resp, nil
+}
+
+// lifetime returns how long resp may be cached, from its max-age or Expires, at most r.ttl.
+func (r *cachingTransport) lifetime(resp *http.Response, control map[string]string) time.Duration {
+	if age, ok := control["max-age"]; ok {
+		seconds, err := strconv.Atoi(age)
+		if err != nil {
+			return 0
+		}
+		return min(time.Duration(seconds)*time.Second, r.ttl)
+	}
+	if expires := resp.Header.Get("Expires"); expires != "" {
+		at, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		now := time.Now()
+		if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			now = date
+		}
+		return min(at.Sub(now), r.ttl)
+	}
+
+	return r.ttl
+}
+
+// varyKey returns the key of the response to req among those varying with the headers in vary.
+func varyKey(base string, vary []string, req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(base + "\n")
+	for _, name := range vary {
+		b.WriteString(name + ": " + strings.Join(req.Header.Values(name), ", ") + "\n")
+	}
+
+	return b.String()
+}
+
+// cacheControl parses the Cache-Control directives of h, lowercasing their names.
+func cacheControl(h http.Header) map[string]string {
+	res := make(map[string]string)
+	for _, value := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				res[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdaptiveTTLConfig configures AdaptiveTTL.
+type AdaptiveTTLConfig struct {
+	// Separator ends the prefix keys are grouped by, ":" by default.
+	// Keys without it are grouped under their full name.
+	Separator string
+	// TargetHitRatio is the hit ratio below which longer TTLs are suggested, 0.9 by default.
+	TargetHitRatio float64
+	// Window is the number of reads of a prefix between two suggestions, 100 by default.
+	Window int64
+	// Min and Max bound the suggested TTLs, 1 second and 24 hours by default.
+	Min, Max time.Duration
+	// Apply makes Put and Remember use the suggested TTL instead of the one they were given.
+	Apply bool
+}
+
+// TTLRecommendation is the TTL suggested for the keys of a prefix.
+type TTLRecommendation struct {
+	Prefix string
+	// TTL is the TTL the last write of the prefix was given.
+	TTL time.Duration
+	// Suggested is the TTL recommended for the prefix.
+	Suggested time.Duration
+	// HitRatio is the ratio of reads that hit during the last window.
+	HitRatio float64
+}
+
+type adaptivePrefix struct {
+	ttl, suggested       time.Duration
+	hits, misses, writes int64
+	ratio                float64
+}
+
+type adaptiveState struct {
+	config   AdaptiveTTLConfig
+	mu       sync.Mutex
+	prefixes map[string]*adaptivePrefix
+}
+
+// AdaptiveTTLCache tunes TTLs by hit ratio, see AdaptiveTTL.
+type AdaptiveTTLCache struct {
+	Cache
+	state *adaptiveState
+}
+
+// AdaptiveTTL returns a cache observing the reads and writes of every key prefix and
+// suggesting TTLs for them, retrieved with Recommendations. This is experimental.
+//
+// After every window of reads, a prefix whose hit ratio is below the target is suggested
+// twice its TTL, and a prefix written more often than it's hit half of it.
+// Items stored without expiration are left alone, as are those written with Add, whose TTL
+// usually bounds how long something is held, such as the locks, semaphores and debounces.
+func AdaptiveTTL(instance Cache, config AdaptiveTTLConfig) *AdaptiveTTLCache {
+	if config.Separator == "" {
+		config.Separator = ":"
+	}
+	if config.TargetHitRatio <= 0 {
+		config.TargetHitRatio = 0.9
+	}
+	if config.Window <= 0 {
+		config.Window = 100
+	}
+	if config.Min <= 0 {
+		config.Min = time.Second
+	}
+	if config.Max <= 0 {
+		config.Max = 24 * time.Hour
+	}
+
+	r := &AdaptiveTTLCache{
+		state: &adaptiveState{config: config, prefixes: make(map[string]*adaptivePrefix)},
+	}
+	r.Cache = Use(instance, r.observe)
+
+	return r
+}
+
+// Recommendations returns the TTL suggested for every prefix seen so far, by prefix.
+// Prefixes without a full window of reads yet suggest the TTL they were given.
+func (r *AdaptiveTTLCache) Recommendations() []TTLRecommendation {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+
+	res := make([]TTLRecommendation, 0, len(r.state.prefixes))
+	for prefix, p := range r.state.prefixes {
+		suggested := p.suggested
+		if suggested == 0 {
+			suggested = p.ttl
+		}
+		res = append(res, TTLRecommendation{Prefix: prefix, TTL: p.ttl, Suggested: suggested, HitRatio: p.ratio})
+	}
+	slices.SortFunc(res, func(a, b TTLRecommendation) int {
+		return cmp.Compare(a.Prefix, b.Prefix)
+	})
+
+	return res
+}
+
+func (r *AdaptiveTTLCache) observe(next Handler) Handler {
+	return func(ctx context.Context, op *Op) (any, error) {
+		switch op.Name {
+		case "Get", "Pull":
+			res, err := next(ctx, op)
+			r.state.read(op.Key, res != nil)
+			return res, err
+		case "GetExists":
+			res, err := next(ctx, op)
+			r.state.read(op.Key, err == nil)
+			return res, err
+		case "Has":
+			res, err := next(ctx, op)
+			r.state.read(op.Key, res == true)
+			return res, err
+		case "Put":
+			ttl := resolvePutOptions(func(time.Duration) time.Duration { return 0 }, op.Opts...).ttl
+			if tuned := r.state.write(op.Key, ttl); tuned != ttl {
+				op.Opts = append(slices.Clip(op.Opts), WithTTL(tuned))
+			}
+			return next(ctx, op)
+		case "Remember":
+			var missed atomic.Bool
+			callback, ttl := op.Callback, op.TTL
+			op.Callback = func() (any, error) {
+				missed.Store(true)
+				return callback()
+			}
+			op.TTL = r.state.tune(op.Key, ttl)
+			res, err := next(ctx, op)
+			r.state.read(op.Key, !missed.Load())
+			if missed.Load() {
+				r.state.write(op.Key, ttl)
+			}
+			return res, err
+		default:
+			return next(ctx, op)
+		}
+	}
+}
+
+func (r *adaptiveState) prefix(key string) *adaptivePrefix {
+	prefix, _, _ := strings.Cut(key, r.config.Separator)
+	p, ok := r.prefixes[prefix]
+	if !ok {
+		p = &adaptivePrefix{}
+		r.prefixes[prefix] = p
+	}
+
+	return p
+}
+
+// tune returns the TTL to write key with.
+func (r *adaptiveState) tune(key string, ttl time.Duration) time.Duration {
+	if !r.config.Apply || ttl == NoExpiration {
+		return ttl
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p := r.prefix(key); p.suggested > 0 {
+		return p.suggested
+	}
+
+	return ttl
+}
+
+// write records a write of key with ttl and returns the TTL to write it with.
+func (r *adaptiveState) write(key string, ttl time.Duration) time.Duration {
+	if ttl == NoExpiration {
+		return ttl
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.prefix(key)
+	p.writes++
+	p.ttl = ttl
+	if r.config.Apply && p.suggested > 0 {
+		return p.suggested
+	}
+
+	return ttl
+}
+
+func (r *adaptiveState) read(key string, hit bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.prefix(key)
+	if hit {
+		p.hits++
+	} else {
+		p.misses++
+	}
+	if p.hits+p.misses < r.config.Window {
+		return
+	}
+
+	p.ratio = float64(p.hits) / float64(p.hits+p.misses)
+	base := p.suggested
+	if base == 0 {
+		base = p.ttl
+	}
+	switch {
+	case base == 0:
+	case p.ratio < r.config.TargetHitRatio:
+		p.suggested = min(base*2, r.config.Max)
+	case p.writes > p.hits:
+		p.suggested = max(base/2, r.config.Min)
+	default:
+		p.suggested = base
+	}
+	p.hits, p.misses, p.writes = 0, 0, 0
+}
+
+func (r *AdaptiveTTLCache) Lock(key string, t ...time.Duration) *Lock {
+	return NewLock(r, key, t...)
+}
+
+func (r *AdaptiveTTLCache) Pipeline() *Pipeline {
+	return NewPipeline(r)
+}
+
+func (r *AdaptiveTTLCache) WithContext(ctx context.Context) Cache {
+	return &AdaptiveTTLCache{Cache: r.Cache.WithContext(ctx), state: r.state}
+}
@@ -0,0 +1,11 @@
+package cache
+
+// Appender is implemented by stores that can grow string and byte items in place.
+type Appender interface {
+	// Append adds data at the end of the item of key, creating it if missing,
+	// and returns the new length.
+	Append(key, data string) (int, error)
+	// Prepend adds data at the start of the item of key, creating it if missing,
+	// and returns the new length.
+	Prepend(key, data string) (int, error)
+}
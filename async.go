@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what PutAsync does when the write queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until the queue has room.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop discards the write.
+	OverflowDrop
+	// OverflowError discards the write and returns ErrAsyncQueueFull.
+	OverflowError
+)
+
+type asyncWrite struct {
+	key   string
+	value any
+	opts  []PutOption
+}
+
+// AsyncWriter applies Puts to a cache from a bounded pool of workers,
+// so callers don't wait for the write to finish.
+type AsyncWriter struct {
+	store   Cache
+	policy  OverflowPolicy
+	queue   chan asyncWrite
+	onError func(key string, err error)
+	dropped atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewAsyncWriter(instance Cache, workers, queueSize int, policy OverflowPolicy) *AsyncWriter {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	r := &AsyncWriter{
+		store:  instance,
+		policy: policy,
+		queue:  make(chan asyncWrite, queueSize),
+	}
+
+	r.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go r.work()
+	}
+
+	return r
+}
+
+// OnError registers a callback for writes that failed in the background.
+// It must be set before the first PutAsync.
+func (r *AsyncWriter) OnError(callback func(key string, err error)) *AsyncWriter {
+	r.onError = callback
+	return r
+}
+
+// PutAsync queues an item to be stored in the cache.
+func (r *AsyncWriter) PutAsync(key string, value any, opts ...PutOption) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return ErrAsyncClosed
+	}
+
+	write := asyncWrite{key: key, value: value, opts: opts}
+	if r.policy == OverflowBlock {
+		r.queue <- write
+		return nil
+	}
+
+	select {
+	case r.queue <- write:
+		return nil
+	default:
+		r.dropped.Add(1)
+		if r.policy == OverflowError {
+			return ErrAsyncQueueFull
+		}
+		return nil
+	}
+}
+
+// Dropped returns how many writes were discarded because the queue was full.
+func (r *AsyncWriter) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Close stops accepting writes and waits until the queued ones are applied.
+func (r *AsyncWriter) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.queue)
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+func (r *AsyncWriter) work() {
+	defer r.wg.Done()
+
+	for write := range r.queue {
+		if err := r.store.Put(write.key, write.value, write.opts...); err != nil && r.onError != nil {
+			r.onError(write.key, err)
+		}
+	}
+}
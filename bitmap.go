@@ -0,0 +1,13 @@
+package cache
+
+// Bitmapper is implemented by stores that can address the bits of byte items, as Redis bitmaps.
+// Bit 0 is the most significant bit of the first byte.
+type Bitmapper interface {
+	// SetBit sets or clears the bit at offset of the item of key, growing it as needed,
+	// and returns the previous value of the bit.
+	SetBit(key string, offset int64, value bool) (bool, error)
+	// GetBit returns the bit at offset of the item of key, false past its end.
+	GetBit(key string, offset int64) (bool, error)
+	// BitCount returns how many bits of the item of key are set.
+	BitCount(key string) (int64, error)
+}
@@ -0,0 +1,307 @@
+package cache
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type bloomFilter struct {
+	bits   []atomic.Uint64
+	size   uint64
+	hashes uint64
+}
+
+func newBloomFilter(expected int, falsePositive float64) *bloomFilter {
+	if expected < 1 {
+		expected = 1
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		falsePositive = 0.01
+	}
+
+	size := uint64(math.Ceil(-float64(expected) * math.Log(falsePositive) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Max(1, math.Round(float64(size)/float64(expected)*math.Ln2)))
+
+	return &bloomFilter{
+		bits:   make([]atomic.Uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+func (r *bloomFilter) locations(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+
+	return sum, sum>>32 | sum<<32 | 1
+}
+
+func (r *bloomFilter) Add(key string) {
+	h1, h2 := r.locations(key)
+	for i := uint64(0); i < r.hashes; i++ {
+		bit := (h1 + i*h2) % r.size
+		r.bits[bit/64].Or(1 << (bit % 64))
+	}
+}
+
+func (r *bloomFilter) Test(key string) bool {
+	h1, h2 := r.locations(key)
+	for i := uint64(0); i < r.hashes; i++ {
+		bit := (h1 + i*h2) % r.size
+		if r.bits[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+type bloomState struct {
+	expected      int
+	falsePositive float64
+
+	mu         sync.RWMutex
+	filter     *bloomFilter
+	rebuilding *bloomFilter
+	stops      []func()
+}
+
+// BloomShield records every key written through it in a Bloom filter, and answers
+// reads for keys that were never written without asking the underlying cache.
+// Forgotten keys stay in the filter until the next Rebuild.
+type BloomShield struct {
+	Cache
+	state *bloomState
+}
+
+func NewBloomShield(instance Cache, expected int, falsePositive float64) *BloomShield {
+	return &BloomShield{
+		Cache: instance,
+		state: &bloomState{
+			expected:      expected,
+			falsePositive: falsePositive,
+			filter:        newBloomFilter(expected, falsePositive),
+		},
+	}
+}
+
+// Rebuild replaces the filter with one holding only the given keys.
+// Keys written while the rebuild runs are kept.
+func (r *BloomShield) Rebuild(keys []string) {
+	r.rebuild(func() []string {
+		return keys
+	})
+}
+
+// rebuild replaces the filter with one holding the keys returned by source. The new filter
+// records writes before source is called, so keys written while it lists them aren't lost.
+func (r *BloomShield) rebuild(source func() []string) {
+	filter := newBloomFilter(r.state.expected, r.state.falsePositive)
+
+	r.state.mu.Lock()
+	r.state.rebuilding = filter
+	r.state.mu.Unlock()
+
+	for _, key := range source() {
+		filter.Add(key)
+	}
+
+	r.state.mu.Lock()
+	r.state.filter = filter
+	r.state.rebuilding = nil
+	r.state.mu.Unlock()
+}
+
+// RebuildEvery calls Rebuild with the keys returned by source on every interval, until stop is called.
+// Once stop returns no rebuild is running anymore.
+func (r *BloomShield) RebuildEvery(interval time.Duration, source func() []string) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done, exited := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-ticker.C:
+				r.rebuild(source)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			<-exited
+		})
+	}
+
+	r.state.mu.Lock()
+	r.state.stops = append(r.state.stops, stop)
+	r.state.mu.Unlock()
+
+	return stop
+}
+
+func (r *BloomShield) mark(key string) {
+	r.state.mu.RLock()
+	r.state.filter.Add(key)
+	if r.state.rebuilding != nil {
+		r.state.rebuilding.Add(key)
+	}
+	r.state.mu.RUnlock()
+}
+
+func (r *BloomShield) mayExist(key string) bool {
+	r.state.mu.RLock()
+	defer r.state.mu.RUnlock()
+
+	return r.state.filter.Test(key)
+}
+
+func (r *BloomShield) Add(key string, value any, t time.Duration) bool {
+	r.mark(key)
+	return r.Cache.Add(key, value, t)
+}
+
+// Close stops the rebuilds started with RebuildEvery and closes the underlying cache.
+func (r *BloomShield) Close(ctx context.Context) error {
+	r.state.mu.Lock()
+	stops := r.state.stops
+	r.state.stops = nil
+	r.state.mu.Unlock()
+
+	for _, stop := range stops {
+		stop()
+	}
+
+	return r.Cache.Close(ctx)
+}
+
+func (r *BloomShield) Decrement(key string, value ...int64) (int64, error) {
+	r.mark(key)
+	return r.Cache.Decrement(key, value...)
+}
+
+func (r *BloomShield) Forever(key string, value any) bool {
+	r.mark(key)
+	return r.Cache.Forever(key, value)
+}
+
+func (r *BloomShield) Get(key string, def ...any) any {
+	if !r.mayExist(key) {
+		return defaultValue(def...)
+	}
+
+	return r.Cache.Get(key, def...)
+}
+
+func (r *BloomShield) GetExists(key string) (any, bool) {
+	if !r.mayExist(key) {
+		return nil, false
+	}
+
+	return r.Cache.GetExists(key)
+}
+
+func (r *BloomShield) GetBool(key string, def ...bool) bool {
+	if !r.mayExist(key) {
+		if len(def) == 0 {
+			return false
+		}
+		return def[0]
+	}
+
+	return r.Cache.GetBool(key, def...)
+}
+
+func (r *BloomShield) GetInt(key string, def ...int) int {
+	if !r.mayExist(key) {
+		if len(def) == 0 {
+			return 0
+		}
+		return def[0]
+	}
+
+	return r.Cache.GetInt(key, def...)
+}
+
+func (r *BloomShield) GetInt64(key string, def ...int64) int64 {
+	if !r.mayExist(key) {
+		if len(def) == 0 {
+			return 0
+		}
+		return def[0]
+	}
+
+	return r.Cache.GetInt64(key, def...)
+}
+
+func (r *BloomShield) GetString(key string, def ...string) string {
+	if !r.mayExist(key) {
+		if len(def) == 0 {
+			return ""
+		}
+		return def[0]
+	}
+
+	return r.Cache.GetString(key, def...)
+}
+
+func (r *BloomShield) Has(key string) bool {
+	return r.mayExist(key) && r.Cache.Has(key)
+}
+
+func (r *BloomShield) Increment(key string, value ...int64) (int64, error) {
+	r.mark(key)
+	return r.Cache.Increment(key, value...)
+}
+
+func (r *BloomShield) Lock(key string, t ...time.Duration) *Lock {
+	return NewLock(r, key, t...)
+}
+
+func (r *BloomShield) Pipeline() *Pipeline {
+	return NewPipeline(r)
+}
+
+func (r *BloomShield) Put(key string, value any, opts ...PutOption) error {
+	r.mark(key)
+	return r.Cache.Put(key, value, opts...)
+}
+
+func (r *BloomShield) Pull(key string, def ...any) any {
+	if !r.mayExist(key) {
+		return defaultValue(def...)
+	}
+
+	return r.Cache.Pull(key, def...)
+}
+
+func (r *BloomShield) Remember(key string, ttl time.Duration, callback func() (any, error)) (any, error) {
+	r.mark(key)
+	return r.Cache.Remember(key, ttl, callback)
+}
+
+func (r *BloomShield) RememberForever(key string, callback func() (any, error)) (any, error) {
+	r.mark(key)
+	return r.Cache.RememberForever(key, callback)
+}
+
+func (r *BloomShield) Transaction(ctx context.Context, fn func(tx Cache) error) error {
+	return r.Cache.Transaction(ctx, func(tx Cache) error {
+		return fn(&BloomShield{Cache: tx, state: r.state})
+	})
+}
+
+func (r *BloomShield) WithContext(ctx context.Context) Cache {
+	return &BloomShield{Cache: r.Cache.WithContext(ctx), state: r.state}
+}
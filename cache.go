@@ -8,6 +8,8 @@ import (
 type Cache interface {
 	// Add an item in the cache if the key does not exist.
 	Add(key string, value any, t time.Duration) bool
+	// Close releases what the driver holds, such as timers and goroutines; it must not be used afterwards.
+	Close(ctx context.Context) error
 	// Decrement decrements the value of an item in the cache.
 	Decrement(key string, value ...int64) (int64, error)
 	// Forever add an item in the cache indefinitely.
@@ -18,6 +20,8 @@ type Cache interface {
 	Flush() bool
 	// Get retrieve an item from the cache by key.
 	Get(key string, def ...any) any
+	// GetExists retrieve an item from the cache by key, reporting whether it exists, even holding nil.
+	GetExists(key string) (any, bool)
 	// GetBool retrieves an item from the cache by key as a boolean.
 	GetBool(key string, def ...bool) bool
 	// GetInt retrieves an item from the cache by key as an integer.
@@ -32,18 +36,36 @@ type Cache interface {
 	Increment(key string, value ...int64) (int64, error)
 	// Lock get a lock instance.
 	Lock(key string, t ...time.Duration) *Lock
-	// Put Driver an item in the cache for a given time.
-	Put(key string, value any, t time.Duration) error
+	// Pipeline get a pipeline instance for batching mutations.
+	Pipeline() *Pipeline
+	// Put Driver an item in the cache, indefinitely unless WithTTL is given.
+	Put(key string, value any, opts ...PutOption) error
 	// Pull retrieve an item from the cache and delete it.
 	Pull(key string, def ...any) any
 	// Remember gets an item from the cache, or execute the given Closure and store the result.
 	Remember(key string, ttl time.Duration, callback func() (any, error)) (any, error)
 	// RememberForever get an item from the cache, or execute the given Closure and store the result forever.
 	RememberForever(key string, callback func() (any, error)) (any, error)
+	// Transaction runs fn against a staged view of the cache and applies its writes atomically.
+	Transaction(ctx context.Context, fn func(tx Cache) error) error
 	// WithContext returns a new Cache instance with the given context.
 	WithContext(ctx context.Context) Cache
 }
 
 func NewCache() Cache {
-	return &Memory{}
+	return NewMemory()
+}
+
+// defaultValue resolves the optional default passed to Get, calling it when it's a func() any.
+func defaultValue(def ...any) any {
+	if len(def) == 0 {
+		return nil
+	}
+
+	switch s := def[0].(type) {
+	case func() any:
+		return s()
+	default:
+		return s
+	}
 }
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ChaosConfig configures the faults injected by Chaos.
+type ChaosConfig struct {
+	// Latency delays every operation.
+	Latency time.Duration
+	// Jitter adds a random delay in [0, Jitter) on top of Latency.
+	Jitter time.Duration
+	// ErrorRate is the probability in [0, 1] that an operation fails with Err.
+	// Failed reads report a miss and failed writes report false.
+	ErrorRate float64
+	// DropRate is the probability in [0, 1] that a write is reported as done without being applied.
+	DropRate float64
+	// Err is the error failed operations return, ErrDriverUnavailable by default.
+	Err error
+	// Rand is the source of randomness, seed it to replay the same faults. It's safe to share.
+	Rand *rand.Rand
+}
+
+// Chaos returns a cache injecting latency, errors and dropped writes into its operations,
+// for verifying that code copes with a degraded cache.
+func Chaos(instance Cache, config ChaosConfig) Cache {
+	return Use(instance, ChaosMiddleware(config))
+}
+
+// ChaosMiddleware is the middleware behind Chaos, to combine with others in Use.
+func ChaosMiddleware(config ChaosConfig) Middleware {
+	if config.Err == nil {
+		config.Err = ErrDriverUnavailable
+	}
+
+	var mu sync.Mutex
+	float := func() float64 {
+		if config.Rand == nil {
+			return rand.Float64()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return config.Rand.Float64()
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Op) (any, error) {
+			if delay := config.Latency + chaosJitter(config.Jitter, float); delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, ctx.Err()
+				case <-timer.C:
+				}
+			}
+
+			if config.ErrorRate > 0 && float() < config.ErrorRate {
+				return nil, config.Err
+			}
+			if config.DropRate > 0 && chaosWrite(op.Name) && float() < config.DropRate {
+				switch op.Name {
+				case "Put":
+					return nil, nil
+				default:
+					return true, nil
+				}
+			}
+
+			return next(ctx, op)
+		}
+	}
+}
+
+func chaosJitter(jitter time.Duration, float func() float64) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+
+	return time.Duration(float() * float64(jitter))
+}
+
+// chaosWrite reports whether the operation only stores an item, so it can be dropped.
+func chaosWrite(name string) bool {
+	return name == "Add" || name == "Forever" || name == "Put"
+}
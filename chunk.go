@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"context"
+	"hash/crc32"
+	"slices"
+)
+
+// Chunking is a middleware splitting the string and []byte values longer than maxSize into
+// chunks of maxSize, for backends limiting the size of items, e.g. 1 MiB for Memcached.
+// Encode other values first, e.g. with PutEncoded. A manifest stored under the key, written
+// after the chunks, lists them with the size and checksum of the value, so reads see either
+// the old value or the new one, and report a miss when a chunk has been evicted or overwritten.
+// Overwritten and forgotten values drop their chunks.
+func Chunking(maxSize int) Middleware {
+	return func(next Handler) Handler {
+		r := &chunker{next: next, size: maxSize}
+		return r.handle
+	}
+}
+
+type chunker struct {
+	next Handler
+	size int
+}
+
+func (r *chunker) handle(ctx context.Context, op *Op) (any, error) {
+	switch op.Name {
+	case "Add", "Forever", "Put":
+		var prev streamManifest
+		if op.Name != "Add" {
+			prev, _ = r.manifest(ctx, op.Key)
+		}
+
+		var manifest streamManifest
+		var chunked bool
+		if data, text, ok := r.chunkable(op.Value); ok {
+			var err error
+			if manifest, err = r.split(ctx, op.Key, data, text, r.options(op)); err != nil {
+				return nil, err
+			}
+			chunked = true
+			op = &Op{Name: op.Name, Key: op.Key, Value: manifest, TTL: op.TTL, Opts: op.Opts}
+		}
+
+		res, err := r.next(ctx, op)
+		if err != nil || res == false {
+			if chunked {
+				r.forget(ctx, op.Key, manifest)
+			}
+			return res, err
+		}
+		r.forget(ctx, op.Key, prev)
+		return res, nil
+	case "Get", "GetExists", "Pull":
+		res, err := r.next(ctx, op)
+		manifest, ok := res.(streamManifest)
+		if err != nil || !ok {
+			return res, err
+		}
+
+		val, err := r.join(ctx, op.Key, manifest)
+		if op.Name == "Pull" {
+			r.forget(ctx, op.Key, manifest)
+		}
+		if err != nil {
+			if op.Name == "GetExists" {
+				return nil, ErrKeyNotFound
+			}
+			return nil, nil
+		}
+		return val, nil
+	case "Forget":
+		prev, _ := r.manifest(ctx, op.Key)
+		res, err := r.next(ctx, op)
+		r.forget(ctx, op.Key, prev)
+		return res, err
+	case "Remember", "RememberForever":
+		var val any
+		var manifest streamManifest
+		var chunked bool
+		callback, opts := op.Callback, r.options(op)
+		op = &Op{Name: op.Name, Key: op.Key, TTL: op.TTL, Callback: func() (any, error) {
+			res, err := callback()
+			data, text, ok := r.chunkable(res)
+			if err != nil || !ok {
+				return res, err
+			}
+			if manifest, err = r.split(ctx, op.Key, data, text, opts); err != nil {
+				return nil, err
+			}
+			val, chunked = res, true
+			return manifest, nil
+		}}
+
+		res, err := r.next(ctx, op)
+		stored, ok := res.(streamManifest)
+		switch {
+		case err != nil || !ok:
+			return res, err
+		case chunked && stored.ID == manifest.ID:
+			return val, nil
+		}
+
+		// The value stored by someone else is broken, compute it again rather than failing.
+		joined, err := r.join(ctx, op.Key, stored)
+		if err != nil && !chunked {
+			_, _ = r.next(ctx, &Op{Name: "Forget", Key: op.Key})
+			return r.handle(ctx, &Op{Name: op.Name, Key: op.Key, TTL: op.TTL, Callback: callback})
+		}
+		return joined, err
+	default:
+		return r.next(ctx, op)
+	}
+}
+
+// chunkable returns the bytes of value if it's a string or []byte longer than the chunks.
+func (r *chunker) chunkable(value any) ([]byte, bool, bool) {
+	switch v := value.(type) {
+	case []byte:
+		return v, false, len(v) > r.size
+	case string:
+		return []byte(v), true, len(v) > r.size
+	default:
+		return nil, false, false
+	}
+}
+
+// options returns the options the chunks of op are written with, so they expire with the manifest.
+func (r *chunker) options(op *Op) []PutOption {
+	switch op.Name {
+	case "Put":
+		return op.Opts
+	case "Add", "Remember":
+		return []PutOption{WithTTL(op.TTL)}
+	default:
+		return nil
+	}
+}
+
+func (r *chunker) split(ctx context.Context, key string, data []byte, text bool, opts []PutOption) (streamManifest, error) {
+	manifest := newStreamManifest()
+	manifest.Size = int64(len(data))
+	manifest.Checksum = crc32.Checksum(data, crc32c)
+	manifest.Text = text
+
+	for chunk := range slices.Chunk(data, r.size) {
+		op := &Op{Name: "Put", Key: manifest.chunk(key, manifest.Chunks), Value: chunk, Opts: opts}
+		if _, err := r.next(ctx, op); err != nil {
+			r.forget(ctx, key, manifest)
+			return streamManifest{}, err
+		}
+		manifest.Chunks++
+	}
+
+	return manifest, nil
+}
+
+func (r *chunker) join(ctx context.Context, key string, manifest streamManifest) (any, error) {
+	data := make([]byte, 0, manifest.Size)
+	for i := 0; i < manifest.Chunks; i++ {
+		res, _ := r.next(ctx, &Op{Name: "Get", Key: manifest.chunk(key, i)})
+		chunk, ok := res.([]byte)
+		if !ok {
+			return nil, ErrChunkMissing
+		}
+		data = append(data, chunk...)
+	}
+	if err := manifest.verify(key, int64(len(data)), crc32.Checksum(data, crc32c)); err != nil {
+		return nil, err
+	}
+
+	if manifest.Text {
+		return string(data), nil
+	}
+	return data, nil
+}
+
+// manifest returns the manifest stored under key, reporting whether the value there is chunked.
+func (r *chunker) manifest(ctx context.Context, key string) (streamManifest, bool) {
+	res, _ := r.next(ctx, &Op{Name: "Get", Key: key})
+	manifest, ok := res.(streamManifest)
+
+	return manifest, ok
+}
+
+func (r *chunker) forget(ctx context.Context, key string, manifest streamManifest) {
+	for i := 0; i < manifest.Chunks; i++ {
+		_, _ = r.next(ctx, &Op{Name: "Forget", Key: manifest.chunk(key, i)})
+	}
+}
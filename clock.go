@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Clock tells the time to a Memory driver.
+type Clock interface {
+	Now() time.Time
+}
+
+// FakeClock is a Clock that only moves when told to, for reproducible tests.
+type FakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	hooks []func()
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (r *FakeClock) Now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.now
+}
+
+// Advance moves the clock forward by d, expiring the items of the drivers using it before returning.
+func (r *FakeClock) Advance(d time.Duration) {
+	r.mu.Lock()
+	r.now = r.now.Add(d)
+	hooks := r.hooks
+	r.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// onAdvance registers fn to run every time the clock is advanced.
+func (r *FakeClock) onAdvance(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks = append(r.hooks, fn)
+}
+
+// lockedRand is a rand.Rand safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (r *lockedRand) duration(n time.Duration) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return time.Duration(r.r.Int64N(int64(n)))
+}
+
+// WithClock makes the driver tell the time with clock. Expiration timers aren't armed then,
+// items expire once clock reaches their expiration: when they're next accessed, on FlushExpired,
+// or right away when clock is a FakeClock being advanced.
+func WithClock(clock Clock) MemoryOption {
+	return func(r *Memory) {
+		r.clock = clock
+		if fake, ok := clock.(*FakeClock); ok {
+			fake.onAdvance(func() {
+				r.FlushExpired()
+			})
+		}
+	}
+}
+
+// WithDeterministic makes the driver reproducible for property-based and fuzz tests: it runs on
+// clock as with WithClock, and draws WithJitter durations from a generator seeded with seed.
+func WithDeterministic(clock *FakeClock, seed uint64) MemoryOption {
+	return func(r *Memory) {
+		WithClock(clock)(r)
+		r.rand = &lockedRand{r: rand.New(rand.NewPCG(seed, seed))}
+	}
+}
+
+// now returns the time on the driver's clock.
+func (r *memoryState) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+
+	return r.clock.Now()
+}
+
+// putOptions resolves the options of a write, drawing jitter from the driver's generator if it has one.
+func (r *memoryState) putOptions(opts ...PutOption) putOptions {
+	if r.rand == nil {
+		return newPutOptions(opts...)
+	}
+
+	return resolvePutOptions(r.rand.duration, opts...)
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+type coalesceRecord struct {
+	hash uint64
+	at   time.Time
+}
+
+type coalesceState struct {
+	mu        sync.Mutex
+	records   map[string]coalesceRecord
+	lastPrune time.Time
+}
+
+// coalescer skips Puts that repeat the last value written to a key within a window.
+type coalescer struct {
+	Cache
+	window time.Duration
+	state  *coalesceState
+}
+
+// Coalesce returns a cache that drops a Put when the same key was given an equal
+// value with the same options within window and the item is still there, saving
+// redundant writes for idempotent refreshes. Values are compared by hashing their
+// Go-syntax representation; Puts with a validator are never dropped.
+func Coalesce(instance Cache, window time.Duration) Cache {
+	return &coalescer{
+		Cache:  instance,
+		window: window,
+		state:  &coalesceState{records: make(map[string]coalesceRecord)},
+	}
+}
+
+func (r *coalescer) Put(key string, value any, opts ...PutOption) error {
+	hash, ok := coalesceHash(value, opts...)
+	if !ok {
+		r.forget(key)
+		return r.Cache.Put(key, value, opts...)
+	}
+	now := time.Now()
+
+	r.state.mu.Lock()
+	if now.Sub(r.state.lastPrune) > r.window {
+		for k, record := range r.state.records {
+			if now.Sub(record.at) > r.window {
+				delete(r.state.records, k)
+			}
+		}
+		r.state.lastPrune = now
+	}
+	record, ok := r.state.records[key]
+	r.state.mu.Unlock()
+	// The item may have expired or been evicted since, or written around the coalescer.
+	if ok && record.hash == hash && now.Sub(record.at) <= r.window && r.Cache.Has(key) {
+		return nil
+	}
+
+	if err := r.Cache.Put(key, value, opts...); err != nil {
+		return err
+	}
+
+	r.state.mu.Lock()
+	r.state.records[key] = coalesceRecord{hash: hash, at: now}
+	r.state.mu.Unlock()
+
+	return nil
+}
+
+func (r *coalescer) Forever(key string, value any) bool {
+	return r.Put(key, value) == nil
+}
+
+func (r *coalescer) Add(key string, value any, t time.Duration) bool {
+	r.forget(key)
+	return r.Cache.Add(key, value, t)
+}
+
+func (r *coalescer) Decrement(key string, value ...int64) (int64, error) {
+	r.forget(key)
+	return r.Cache.Decrement(key, value...)
+}
+
+func (r *coalescer) Forget(key string) bool {
+	r.forget(key)
+	return r.Cache.Forget(key)
+}
+
+func (r *coalescer) Flush() bool {
+	r.reset()
+	return r.Cache.Flush()
+}
+
+func (r *coalescer) Increment(key string, value ...int64) (int64, error) {
+	r.forget(key)
+	return r.Cache.Increment(key, value...)
+}
+
+func (r *coalescer) Lock(key string, t ...time.Duration) *Lock {
+	return NewLock(r, key, t...)
+}
+
+func (r *coalescer) Pipeline() *Pipeline {
+	return NewPipeline(r)
+}
+
+func (r *coalescer) Pull(key string, def ...any) any {
+	r.forget(key)
+	return r.Cache.Pull(key, def...)
+}
+
+func (r *coalescer) Transaction(ctx context.Context, fn func(tx Cache) error) error {
+	defer r.reset()
+	return r.Cache.Transaction(ctx, fn)
+}
+
+func (r *coalescer) WithContext(ctx context.Context) Cache {
+	return &coalescer{
+		Cache:  r.Cache.WithContext(ctx),
+		window: r.window,
+		state:  r.state,
+	}
+}
+
+func (r *coalescer) forget(key string) {
+	r.state.mu.Lock()
+	delete(r.state.records, key)
+	r.state.mu.Unlock()
+}
+
+func (r *coalescer) reset() {
+	r.state.mu.Lock()
+	clear(r.state.records)
+	r.state.mu.Unlock()
+}
+
+// coalesceHash hashes value with the options it's written with, reporting false for
+// options that can't be compared, such as validators.
+func coalesceHash(value any, opts ...PutOption) (uint64, bool) {
+	var o putOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.validate != nil {
+		return 0, false
+	}
+
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%T:%#v", value, value)
+	_, _ = fmt.Fprintf(h, "|%d|%d|%d|%d|%d", o.ttl, o.jitter, o.softTTL, o.cost, o.priority)
+	return h.Sum64(), true
+}
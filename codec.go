@@ -0,0 +1,369 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Codec turns values into bytes and back, for items kept serialized.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into the value v points to.
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSONCodec encodes values with encoding/json.
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec encodes values with encoding/gob.
+	GobCodec Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// CodecHook encodes the values of one type in place of the codec it's given to.
+type CodecHook struct {
+	typ       reflect.Type
+	marshal   func(v any) ([]byte, error)
+	unmarshal func(data []byte) (any, error)
+}
+
+// TypeHook creates a hook encoding values of type T with marshal and decoding them with unmarshal,
+// e.g. to keep a time.Time with its location or a decimal without losing precision.
+func TypeHook[T any](marshal func(T) ([]byte, error), unmarshal func([]byte) (T, error)) CodecHook {
+	return CodecHook{
+		typ: reflect.TypeFor[T](),
+		marshal: func(v any) ([]byte, error) {
+			return marshal(v.(T))
+		},
+		unmarshal: func(data []byte) (any, error) {
+			return unmarshal(data)
+		},
+	}
+}
+
+type hookedCodec struct {
+	base    Codec
+	hooks   map[reflect.Type]CodecHook
+	shadows sync.Map // reflect.Type to *codecShadow
+}
+
+// codecShadow is the type base encodes in place of another holding hooked fields,
+// in which those fields are []byte holding what their hook encoded.
+type codecShadow struct {
+	typ     reflect.Type
+	changed bool
+}
+
+// WithHooks returns a codec encoding values with the hook registered for their type, and with base otherwise.
+// Hooks also apply to the exported fields of structs, followed through pointers, slices, arrays and map
+// values, which base then encodes as the bytes the hook returned. Values held in interfaces aren't walked.
+func WithHooks(base Codec, hooks ...CodecHook) Codec {
+	r := &hookedCodec{base: base, hooks: make(map[reflect.Type]CodecHook, len(hooks))}
+	for _, hook := range hooks {
+		r.hooks[hook.typ] = hook
+	}
+
+	return r
+}
+
+func (r *hookedCodec) Marshal(v any) ([]byte, error) {
+	if hook, ok := r.hooks[reflect.TypeOf(v)]; ok {
+		return hook.marshal(v)
+	}
+
+	if v != nil {
+		if shadow := r.shadow(reflect.TypeOf(v), nil); shadow.changed {
+			sv, err := r.toShadow(reflect.ValueOf(v), shadow.typ)
+			if err != nil {
+				return nil, err
+			}
+			return r.base.Marshal(sv.Interface())
+		}
+	}
+
+	return r.base.Marshal(v)
+}
+
+func (r *hookedCodec) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("cache: can't decode into %T, not a pointer", v)
+	}
+
+	hook, ok := r.hooks[rv.Elem().Type()]
+	if !ok {
+		shadow := r.shadow(rv.Elem().Type(), nil)
+		if !shadow.changed {
+			return r.base.Unmarshal(data, v)
+		}
+
+		sv := reflect.New(shadow.typ)
+		if err := r.base.Unmarshal(data, sv.Interface()); err != nil {
+			return err
+		}
+		return r.fromShadow(sv.Elem(), rv.Elem())
+	}
+
+	val, err := hook.unmarshal(data)
+	if err != nil {
+		return err
+	}
+	rv.Elem().Set(reflect.ValueOf(val))
+
+	return nil
+}
+
+var bytesType = reflect.TypeFor[[]byte]()
+
+// shadow returns the shadow of t, building it once. visiting holds the types being built,
+// whose recursive uses are left as they are.
+func (r *hookedCodec) shadow(t reflect.Type, visiting map[reflect.Type]bool) *codecShadow {
+	if shadow, ok := r.shadows.Load(t); ok {
+		return shadow.(*codecShadow)
+	}
+	if _, ok := r.hooks[t]; ok {
+		return &codecShadow{typ: bytesType, changed: true}
+	}
+	if visiting[t] {
+		return &codecShadow{typ: t}
+	}
+	// Only complete shadows are kept, not those of types met while a recursive one is built.
+	top := visiting == nil
+	if top {
+		visiting = make(map[reflect.Type]bool)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	shadow := &codecShadow{typ: t}
+	switch t.Kind() {
+	case reflect.Pointer:
+		if elem := r.shadow(t.Elem(), visiting); elem.changed {
+			shadow = &codecShadow{typ: reflect.PointerTo(elem.typ), changed: true}
+		}
+	case reflect.Slice:
+		if elem := r.shadow(t.Elem(), visiting); elem.changed {
+			shadow = &codecShadow{typ: reflect.SliceOf(elem.typ), changed: true}
+		}
+	case reflect.Array:
+		if elem := r.shadow(t.Elem(), visiting); elem.changed {
+			shadow = &codecShadow{typ: reflect.ArrayOf(t.Len(), elem.typ), changed: true}
+		}
+	case reflect.Map:
+		if elem := r.shadow(t.Elem(), visiting); elem.changed {
+			shadow = &codecShadow{typ: reflect.MapOf(t.Key(), elem.typ), changed: true}
+		}
+	case reflect.Struct:
+		var (
+			fields  []reflect.StructField
+			changed bool
+		)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if elem := r.shadow(field.Type, visiting); elem.changed {
+				// An embedded field whose type changed can't be embedded anymore.
+				field.Type, field.Anonymous, changed = elem.typ, false, true
+			}
+			field.Index, field.Offset = nil, 0
+			fields = append(fields, field)
+		}
+		if changed {
+			shadow = &codecShadow{typ: reflect.StructOf(fields), changed: true}
+		}
+	}
+
+	if top {
+		r.shadows.Store(t, shadow)
+	}
+	return shadow
+}
+
+// toShadow converts v into a value of its shadow type st, encoding the hooked values.
+func (r *hookedCodec) toShadow(v reflect.Value, st reflect.Type) (reflect.Value, error) {
+	if v.Type() == st {
+		return v, nil
+	}
+	if hook, ok := r.hooks[v.Type()]; ok {
+		data, err := hook.marshal(v.Interface())
+		return reflect.ValueOf(data), err
+	}
+
+	sv := reflect.New(st).Elem()
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return sv, nil
+		}
+		elem, err := r.toShadow(v.Elem(), st.Elem())
+		if err != nil {
+			return sv, err
+		}
+		sv = reflect.New(st.Elem())
+		sv.Elem().Set(elem)
+		return sv, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return sv, nil
+		}
+		sv = reflect.MakeSlice(st, v.Len(), v.Len())
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			elem, err := r.toShadow(v.Index(i), st.Elem())
+			if err != nil {
+				return sv, err
+			}
+			sv.Index(i).Set(elem)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return sv, nil
+		}
+		sv = reflect.MakeMapWithSize(st, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			elem, err := r.toShadow(iter.Value(), st.Elem())
+			if err != nil {
+				return sv, err
+			}
+			sv.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.Struct:
+		for i := 0; i < st.NumField(); i++ {
+			name := st.Field(i).Name
+			elem, err := r.toShadow(v.FieldByName(name), st.Field(i).Type)
+			if err != nil {
+				return sv, err
+			}
+			sv.Field(i).Set(elem)
+		}
+	}
+
+	return sv, nil
+}
+
+// fromShadow sets dst from sv, a value of the shadow type of dst, decoding the hooked values.
+func (r *hookedCodec) fromShadow(sv, dst reflect.Value) error {
+	if sv.Type() == dst.Type() {
+		dst.Set(sv)
+		return nil
+	}
+	if hook, ok := r.hooks[dst.Type()]; ok {
+		if sv.Len() == 0 {
+			return nil
+		}
+		val, err := hook.unmarshal(sv.Bytes())
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(val))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Pointer:
+		if sv.IsNil() {
+			return nil
+		}
+		dst.Set(reflect.New(dst.Type().Elem()))
+		return r.fromShadow(sv.Elem(), dst.Elem())
+	case reflect.Slice:
+		if sv.IsNil() {
+			return nil
+		}
+		dst.Set(reflect.MakeSlice(dst.Type(), sv.Len(), sv.Len()))
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < sv.Len(); i++ {
+			if err := r.fromShadow(sv.Index(i), dst.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if sv.IsNil() {
+			return nil
+		}
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), sv.Len()))
+		for iter := sv.MapRange(); iter.Next(); {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := r.fromShadow(iter.Value(), elem); err != nil {
+				return err
+			}
+			dst.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.Struct:
+		for i := 0; i < sv.NumField(); i++ {
+			if err := r.fromShadow(sv.Field(i), dst.FieldByName(sv.Type().Field(i).Name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// PutEncoded stores value encoded with codec, as a []byte.
+func PutEncoded(instance Cache, codec Codec, key string, value any, opts ...PutOption) error {
+	data, err := codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return instance.Put(key, data, opts...)
+}
+
+// GetDecoded retrieves an item stored with PutEncoded, decoding it with codec as T.
+// It reports false, with no error, when the item is missing, was written for another
+// schema version or is corrupted, forgetting it in the latter cases.
+func GetDecoded[T any](instance Cache, codec Codec, key string) (T, bool, error) {
+	var res T
+	val, exist := instance.GetExists(key)
+	if !exist {
+		return res, false, nil
+	}
+
+	data, ok := val.([]byte)
+	if !ok {
+		return res, false, fmt.Errorf("cache: value of key %s is %T, not encoded", key, val)
+	}
+	if err := codec.Unmarshal(data, &res); err != nil {
+		if errors.Is(err, ErrSchemaMismatch) || errors.Is(err, ErrChecksumMismatch) {
+			instance.Forget(key)
+			return res, false, nil
+		}
+		return res, false, err
+	}
+
+	return res, true, nil
+}
@@ -1,7 +1,12 @@
 package cache
 
 import (
+	"math"
 	"time"
 )
 
 const NoExpiration time.Duration = 0
+
+// KeepForever is the TTL the callbacks of RememberWithTTL return to store their result
+// indefinitely, a TTL of NoExpiration or less returning it without storing it.
+const KeepForever time.Duration = math.MaxInt64
@@ -0,0 +1,24 @@
+package cache
+
+import (
+	"context"
+)
+
+type contextKey struct{}
+
+// IntoContext returns a copy of ctx carrying store, e.g. for a middleware to hand the store
+// of a tenant or an environment to the handlers downstream, which get it with FromContext.
+func IntoContext(ctx context.Context, store Cache) context.Context {
+	return context.WithValue(ctx, contextKey{}, store)
+}
+
+// FromContext returns the store carried by ctx, bound to ctx with WithContext,
+// reporting whether there's one.
+func FromContext(ctx context.Context) (Cache, bool) {
+	store, ok := ctx.Value(contextKey{}).(Cache)
+	if !ok {
+		return nil, false
+	}
+
+	return store.WithContext(ctx), true
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"sync/atomic"
+)
+
+// Cloner is implemented by values that copy themselves, used by WithCopyValues
+// instead of a gob round trip.
+type Cloner interface {
+	Clone() any
+}
+
+// copyValue returns a copy of v that shares no memory with it. Immutable values and the
+// counters used by Increment/Decrement are returned as is.
+func copyValue(v any) (any, error) {
+	switch nv := v.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, complex64, complex128:
+		return v, nil
+	case *atomic.Int64, *atomic.Int32, *int64, *int32:
+		return v, nil
+	case Cloner:
+		return nv.Clone(), nil
+	case []byte:
+		return bytes.Clone(nv), nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	res := reflect.New(reflect.TypeOf(v))
+	if err := gob.NewDecoder(&buf).DecodeValue(res); err != nil {
+		return nil, err
+	}
+
+	return res.Elem().Interface(), nil
+}
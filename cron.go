@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a parsed cron expression, one bit per allowed value of each field.
+type schedule struct {
+	minute, hour, dom, month, dow uint64
+	// A restricted day of month and day of week match when either of them does, as in cron.
+	domAny, dowAny bool
+}
+
+var scheduleDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseSchedule parses a standard five field cron expression, "minute hour day-of-month
+// month day-of-week", or one of the @yearly, @monthly, @weekly, @daily and @hourly shorthands.
+func parseSchedule(spec string) (*schedule, error) {
+	if expr, ok := scheduleDescriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w %q: expected 5 fields", ErrInvalidSchedule, spec)
+	}
+
+	var res schedule
+	var err error
+	if res.minute, err = parseScheduleField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidSchedule, spec, err)
+	}
+	if res.hour, err = parseScheduleField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidSchedule, spec, err)
+	}
+	if res.dom, err = parseScheduleField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidSchedule, spec, err)
+	}
+	if res.month, err = parseScheduleField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidSchedule, spec, err)
+	}
+	if res.dow, err = parseScheduleField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidSchedule, spec, err)
+	}
+	// Sunday is both 0 and 7.
+	if res.dow&(1<<7) != 0 {
+		res.dow |= 1
+	}
+	res.domAny = fields[2] == "*"
+	res.dowAny = fields[4] == "*"
+
+	return &res, nil
+}
+
+func parseScheduleField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			expr, step = part[:i], n
+		}
+
+		start, end := lo, hi
+		if expr != "*" {
+			from, to, isRange := strings.Cut(expr, "-")
+			var err error
+			if start, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", part)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value in %q", part)
+				}
+			} else if step > 1 {
+				end = hi
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, lo, hi)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+
+	return bits, nil
+}
+
+// next returns the first time after t the schedule fires, or the zero time if it never does.
+func (r *schedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	loc := t.Location()
+
+	for t.Before(limit) {
+		if r.month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !r.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if r.hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if r.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (r *schedule) dayMatches(t time.Time) bool {
+	dom := r.dom&(1<<t.Day()) != 0
+	dow := r.dow&(1<<int(t.Weekday())) != 0
+	if r.domAny || r.dowAny {
+		return dom && dow
+	}
+
+	return dom || dow
+}
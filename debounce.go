@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"time"
+)
+
+// Debounce runs fn unless it already ran for key within window, reporting whether it ran.
+// The window starts when fn is called, so duplicates arriving while it runs are suppressed too.
+// If fn fails the key is released, so the next call can retry it. A panic of fn is returned as a *PanicError.
+func Debounce(instance Cache, key string, window time.Duration, fn func() error) (bool, error) {
+	if !instance.Add(key, true, window) {
+		return false, nil
+	}
+
+	if _, err := safeCall(func() (any, error) { return nil, fn() }); err != nil {
+		instance.Forget(key)
+		return true, err
+	}
+
+	return true, nil
+}
+
+// Throttle reports whether another call for key is allowed, at most limit calls per window.
+// The window is fixed, it starts with the first call and the count resets once it expires.
+func Throttle(instance Cache, key string, limit int64, window time.Duration) (bool, error) {
+	n, err := incrementCounter(instance, key, 1, window)
+	if err != nil {
+		return false, err
+	}
+
+	return n <= limit, nil
+}
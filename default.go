@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"sync/atomic"
+)
+
+var defaultStore atomic.Pointer[Cache]
+
+// SetDefault makes store the one returned by Default, for applications that don't need to pass
+// their store around. It's safe to call concurrently with Default; a nil store unsets it.
+func SetDefault(store Cache) {
+	if store == nil {
+		defaultStore.Store(nil)
+		return
+	}
+
+	defaultStore.Store(&store)
+}
+
+// Default returns the store given to SetDefault, or ErrNoDefault if there's none.
+func Default() (Cache, error) {
+	store := defaultStore.Load()
+	if store == nil {
+		return nil, ErrNoDefault
+	}
+
+	return *store, nil
+}
@@ -2,7 +2,7 @@ package cache
 
 import (
 	"context"
-	"errors"
+	"path"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -10,21 +10,194 @@ import (
 	"github.com/spf13/cast"
 )
 
+// Memory is an in-process driver, create it with NewMemory.
 type Memory struct {
-	ctx      context.Context
-	instance sync.Map
+	ctx context.Context
+	*memoryState
+}
+
+// memoryState is shared by a Memory driver and the copies made by WithContext.
+type memoryState struct {
+	current atomic.Pointer[memoryItems]
+	locks   KeyedMutex
+
+	maxCost      int64
+	policy       EvictionPolicy
+	namespaces   []*memoryNamespace
+	copyValues   bool
+	racyRemember bool
+	keyAnalytics bool
+	alarm        *cardinalityAlarm
+	readOnly     atomic.Bool
+	clock        Clock
+	rand         *lockedRand
+	profiler     *memoryProfiler
+	onExpired    func(key string, value any)
+	reclaimMin   int64
+	pressure     *memoryPressure
+	janitor      *JanitorConfig
+	reclaiming   atomic.Bool
+	closed       atomic.Bool
+	done         chan struct{}
+
+	dependencies dependencyGraph
+	watchers     keyWatchers
+	pins         keyPins
+	stats        memoryStats
+
+	remembering KeyedMutex
+	flightsMu   sync.Mutex
+	flights     map[string]*rememberFlight
+	inflight    atomic.Int32
+	group       flightGroup
+}
+
+// MemoryOption configures a Memory driver.
+type MemoryOption func(*Memory)
+
+// WithMaxCost caps the total cost of the items held, evicting items by priority and
+// then by the eviction policy once it's exceeded. Items cost 1 unless stored WithCost.
+func WithMaxCost(n int64) MemoryOption {
+	return func(r *Memory) {
+		r.maxCost = n
+	}
+}
+
+// WithEvictionPolicy selects which items are evicted first, EvictLRU by default.
+func WithEvictionPolicy(policy EvictionPolicy) MemoryOption {
+	return func(r *Memory) {
+		r.policy = policy
+	}
+}
+
+// WithCopyValues stores a copy of every value written and hands out a copy on every read,
+// so callers can't change cached values through the references they hold. Values are copied
+// with their Clone method when they implement Cloner, and with a gob round trip otherwise,
+// which drops unexported fields.
+func WithCopyValues() MemoryOption {
+	return func(r *Memory) {
+		r.copyValues = true
+	}
+}
+
+// WithoutRememberLock lets concurrent Remember calls missing the same key all run their callback,
+// the last result stored winning. By default they're serialized per key, and callers that waited
+// get the item stored by the first one, so a callback must not Remember its own key.
+func WithoutRememberLock() MemoryOption {
+	return func(r *Memory) {
+		r.racyRemember = true
+	}
+}
+
+// WithReadOnly starts the driver in read-only mode, see SetReadOnly.
+func WithReadOnly() MemoryOption {
+	return func(r *Memory) {
+		r.readOnly.Store(true)
+	}
+}
+
+// OnExpired calls callback with every item that expires, on the goroutine noticing it, e.g. to
+// release resources held by the value. Items forgotten, flushed or evicted are not reported.
+// The callback must not block nor write the expired key.
+func OnExpired(callback func(key string, value any)) MemoryOption {
+	return func(r *Memory) {
+		r.onExpired = callback
+	}
+}
+
+func NewMemory(opts ...MemoryOption) *Memory {
+	r := &Memory{memoryState: &memoryState{done: make(chan struct{})}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	for i, ns := range r.namespaces {
+		ns.index = i
+	}
+	r.current.Store(r.newItems())
+	if r.reclaimMin > 0 {
+		r.watchGC()
+	}
+	if r.pressure != nil {
+		go r.watchPressure(r.pressure)
+	}
+	if r.janitor != nil {
+		go r.runJanitor(r.janitor)
+	}
+
+	return r
 }
 
 // Add an item in the cache if the key does not exist.
 func (r *Memory) Add(key string, value any, t time.Duration) bool {
-	if t != NoExpiration {
-		time.AfterFunc(t, func() {
-			r.Forget(key)
-		})
+	if r.readOnly.Load() {
+		return false
+	}
+
+	e, err := r.newEntry(key, value, r.putOptions(WithTTL(t)))
+	if err != nil {
+		return false
+	}
+
+	// The lock keeps the write from landing between the checks and the writes of a transaction,
+	// the expiration callback runs once it's released.
+	r.locks.Lock(key)
+	expired, ok := r.add(key, e, t)
+	r.locks.Unlock(key)
+	if expired != nil && r.onExpired != nil {
+		r.onExpired(key, expired.value)
+	}
+	return ok
+}
+
+// add stores e under key unless a live item is there, with the lock of key held.
+// It returns the expired item cleared out of the way, if any.
+func (r *Memory) add(key string, e *memoryEntry, t time.Duration) (*memoryEntry, bool) {
+	var expired *memoryEntry
+	items := r.items()
+	for {
+		prev, loaded := items.m.LoadOrStore(key, e)
+		if !loaded {
+			break
+		}
+		// An expired or invalid item that wasn't collected yet doesn't count as existing.
+		pe := prev.(*memoryEntry)
+		switch {
+		case pe.expired(r.now()):
+			if !r.discard(items, key, pe, EventExpire) {
+				return expired, false
+			}
+			r.stats.expirations.Add(1)
+			expired = pe
+		case !pe.invalid() || !r.discard(items, key, pe, EventDelete):
+			return expired, false
+		}
 	}
 
-	_, loaded := r.instance.LoadOrStore(key, value)
-	return !loaded
+	// Expiration is only armed once the item is ours, a failed Add must not
+	// shorten the lifetime of the item already there.
+	r.invalidate(key)
+	if t != NoExpiration && r.clock == nil {
+		e.timer.Store(time.AfterFunc(t, func() {
+			r.expire(items, key, e)
+		}))
+	}
+	r.stored(items, key, e, nil)
+	return expired, true
+}
+
+// Close cancels the pending expirations, drops every item, closes the channels of watchers
+// and stops the background work of the driver.
+func (r *Memory) Close(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if r.closed.CompareAndSwap(false, true) {
+		close(r.done)
+	}
+	r.flush()
+	r.watchers.closeAll()
+	return nil
 }
 
 // Decrement decrements the value of an item in the cache.
@@ -32,8 +205,14 @@ func (r *Memory) Decrement(key string, value ...int64) (int64, error) {
 	if len(value) == 0 {
 		value = append(value, 1)
 	}
+	if r.readOnly.Load() {
+		return 0, ErrReadOnly
+	}
 
 	r.Add(key, new(int64), NoExpiration)
+	// Counters are changed in place, under the lock a transaction commits with.
+	r.locks.Lock(key)
+	defer r.locks.Unlock(key)
 	pv := r.Get(key)
 	switch nv := pv.(type) {
 	case *atomic.Int64:
@@ -45,13 +224,13 @@ func (r *Memory) Decrement(key string, value ...int64) (int64, error) {
 	case *int32:
 		return int64(atomic.AddInt32(nv, int32(-value[0]))), nil
 	default:
-		return 0, errors.New("invalid int value type")
+		return 0, ErrInvalidValueType
 	}
 }
 
 // Forever Put an item in the cache indefinitely.
 func (r *Memory) Forever(key string, value any) bool {
-	if err := r.Put(key, value, NoExpiration); err != nil {
+	if err := r.Put(key, value); err != nil {
 		return false
 	}
 
@@ -60,33 +239,105 @@ func (r *Memory) Forever(key string, value any) bool {
 
 // Forget Remove an item from the cache.
 func (r *Memory) Forget(key string) bool {
-	r.instance.Delete(key)
+	if r.readOnly.Load() {
+		return false
+	}
+
+	r.invalidate(key)
+	r.locks.Lock(key)
+	r.remove(key)
+	r.locks.Unlock(key)
+	r.cascade(key)
 
 	return true
 }
 
-// Flush Remove all items from the cache.
+// remove deletes the item of key, with its lock held.
+func (r *Memory) remove(key string) {
+	items := r.items()
+	if val, loaded := items.m.LoadAndDelete(key); loaded {
+		r.removed(items, val.(*memoryEntry))
+		r.stats.deletes.Add(1)
+		r.watchers.notify(Event{Type: EventDelete, Key: key})
+	}
+}
+
+// ForgetPattern removes the items whose key matches the glob pattern, as in path.Match, returning how many.
+func (r *Memory) ForgetPattern(pattern string) int {
+	if r.readOnly.Load() {
+		return 0
+	}
+
+	var n int
+	r.items().m.Range(func(key, _ any) bool {
+		if ok, _ := path.Match(pattern, key.(string)); ok {
+			r.Forget(key.(string))
+			n++
+		}
+		return true
+	})
+
+	return n
+}
+
+// Flush Remove all items from the cache, except the pinned ones.
+// The items are swapped out at once, and their pending expirations are canceled.
+// When keys are pinned, the other items are removed one by one instead.
 func (r *Memory) Flush() bool {
-	r.instance = sync.Map{}
+	if r.readOnly.Load() {
+		return false
+	}
+
+	if r.pins.n.Load() > 0 {
+		r.flushUnpinned()
+	} else {
+		r.flush()
+	}
 	return true
 }
 
+// flush drops every item, canceling their pending expirations.
+func (r *Memory) flush() {
+	r.invalidateAll()
+	r.dependencies.reset()
+	old := r.current.Swap(r.newItems())
+	watched := r.watchers.watched()
+	old.m.Range(func(key, val any) bool {
+		val.(*memoryEntry).stop()
+		if watched {
+			r.watchers.notify(Event{Type: EventDelete, Key: key.(string)})
+		}
+		return true
+	})
+}
+
+// FlushExpired removes the items that have expired but weren't collected yet, returning how many.
+func (r *Memory) FlushExpired() int {
+	return r.sweep()
+}
+
 // Get Retrieve an item from the cache by key.
 func (r *Memory) Get(key string, def ...any) any {
-	val, exist := r.instance.Load(key)
+	e, exist := r.load(key)
 	if exist {
-		return val
-	}
-	if len(def) == 0 {
-		return nil
+		r.hit(key, e)
+		return r.output(e.value)
 	}
 
-	switch s := def[0].(type) {
-	case func() any:
-		return s()
-	default:
-		return s
+	r.stats.misses.Add(1)
+	return defaultValue(def...)
+}
+
+// GetExists Retrieve an item from the cache by key, reporting whether it exists.
+func (r *Memory) GetExists(key string) (any, bool) {
+	e, exist := r.load(key)
+	if !exist {
+		r.stats.misses.Add(1)
+		return nil, false
 	}
+
+	r.hit(key, e)
+	return r.output(e.value), true
 }
 
 func (r *Memory) GetBool(key string, def ...bool) bool {
@@ -103,7 +354,12 @@ func (r *Memory) GetInt(key string, def ...int) int {
 		def = append(def, 0)
 	}
 
-	return cast.ToInt(r.Get(key, def[0]))
+	res := r.Get(key, def[0])
+	if n, ok := counterValue(res); ok {
+		return int(n)
+	}
+
+	return cast.ToInt(res)
 }
 
 func (r *Memory) GetInt64(key string, def ...int64) int64 {
@@ -111,7 +367,13 @@ func (r *Memory) GetInt64(key string, def ...int64) int64 {
 		def = append(def, 0)
 	}
 
-	return cast.ToInt64(r.Get(key, def[0]))
+	// Counters are read atomically, Increment may be changing them.
+	res := r.Get(key, def[0])
+	if n, ok := counterValue(res); ok {
+		return n
+	}
+
+	return cast.ToInt64(res)
 }
 
 func (r *Memory) GetString(key string, def ...string) string {
@@ -124,7 +386,7 @@ func (r *Memory) GetString(key string, def ...string) string {
 
 // Has Checks an item exists in the cache.
 func (r *Memory) Has(key string) bool {
-	_, exist := r.instance.Load(key)
+	_, exist := r.load(key)
 	return exist
 }
 
@@ -132,8 +394,13 @@ func (r *Memory) Increment(key string, value ...int64) (int64, error) {
 	if len(value) == 0 {
 		value = append(value, 1)
 	}
+	if r.readOnly.Load() {
+		return 0, ErrReadOnly
+	}
 
 	r.Add(key, new(int64), NoExpiration)
+	r.locks.Lock(key)
+	defer r.locks.Unlock(key)
 	pv := r.Get(key)
 	switch nv := pv.(type) {
 	case *atomic.Int64:
@@ -145,14 +412,28 @@ func (r *Memory) Increment(key string, value ...int64) (int64, error) {
 	case *int32:
 		return int64(atomic.AddInt32(nv, int32(value[0]))), nil
 	default:
-		return 0, errors.New("invalid int value type")
+		return 0, ErrInvalidValueType
+	}
+}
+
+// Inspect returns the metadata of an item in the cache, without counting it as a read.
+func (r *Memory) Inspect(key string) (EntryInfo, bool) {
+	e, exist := r.load(key)
+	if !exist {
+		return EntryInfo{}, false
 	}
+
+	return e.info(r.now()), true
 }
 
 func (r *Memory) Lock(key string, t ...time.Duration) *Lock {
 	return NewLock(r, key, t...)
 }
 
+func (r *Memory) Pipeline() *Pipeline {
+	return NewPipeline(r)
+}
+
 // Pull Retrieve an item from the cache and delete it.
 func (r *Memory) Pull(key string, def ...any) any {
 	var res any
@@ -166,60 +447,203 @@ func (r *Memory) Pull(key string, def ...any) any {
 	return res
 }
 
-// Put an item in the cache for a given number of seconds.
-func (r *Memory) Put(key string, value any, t time.Duration) error {
-	if t != NoExpiration {
-		time.AfterFunc(t, func() {
-			r.Forget(key)
-		})
+// Put an item in the cache, for the TTL given by WithTTL.
+func (r *Memory) Put(key string, value any, opts ...PutOption) error {
+	if r.readOnly.Load() {
+		return ErrReadOnly
 	}
 
-	r.instance.Store(key, value)
+	// The lock keeps the write from landing between the checks and the writes of a transaction.
+	r.invalidate(key)
+	r.locks.Lock(key)
+	_, _, err := r.put(key, value, r.putOptions(opts...))
+	r.locks.Unlock(key)
+	if err != nil {
+		return err
+	}
+	r.cascade(key)
+
 	return nil
 }
 
 // Remember Get an item from the cache, or execute the given Closure and store the result.
+// A stale item is returned as is while the Closure refreshes it in the background.
+// The result isn't stored if the key is written or forgotten while the Closure runs.
 func (r *Memory) Remember(key string, seconds time.Duration, callback func() (any, error)) (any, error) {
-	val := r.Get(key, nil)
-	if val != nil {
-		return val, nil
+	if e, exist := r.load(key); exist {
+		r.hit(key, e)
+		r.refresh(key, e, seconds, callback)
+		return r.output(e.value), nil
 	}
 
-	var err error
-	val, err = callback()
-	if err != nil {
-		return nil, err
+	return r.remember(key, callback, WithTTL(seconds))
+}
+
+// RememberForever Get an item from the cache, or execute the given Closure and store the result forever.
+func (r *Memory) RememberForever(key string, callback func() (any, error)) (any, error) {
+	if e, exist := r.load(key); exist {
+		r.hit(key, e)
+		r.refresh(key, e, NoExpiration, callback)
+		return r.output(e.value), nil
 	}
 
-	if err := r.Put(key, val, seconds); err != nil {
-		return nil, err
+	return r.remember(key, callback)
+}
+
+// Transaction runs fn against a staged view of the cache and commits its writes once fn returns nil.
+// Keys read or written by fn are locked during commit, against Put, Forget, Increment and Decrement
+// too, and ErrTransactionConflict is returned without writing anything if any of them changed
+// since fn observed it.
+func (r *Memory) Transaction(ctx context.Context, fn func(tx Cache) error) error {
+	if r.readOnly.Load() {
+		return ErrReadOnly
+	}
+
+	tx := newMemoryTransaction(ctx, r)
+	if err := fn(tx); err != nil {
+		return err
 	}
 
-	return val, nil
+	return tx.commit()
 }
 
-// RememberForever Get an item from the cache, or execute the given Closure and store the result forever.
-func (r *Memory) RememberForever(key string, callback func() (any, error)) (any, error) {
-	val := r.Get(key, nil)
-	if val != nil {
-		return val, nil
+// SetReadOnly toggles read-only mode, e.g. during a maintenance window. While it's on, writes
+// are refused: Put, Increment, Decrement and Transaction return ErrReadOnly, Add, Forever, Forget
+// and Flush return false, and Remember hands out the result of its Closure without storing it.
+// Items still expire.
+func (r *Memory) SetReadOnly(readOnly bool) {
+	r.readOnly.Store(readOnly)
+}
+
+// WithContext returns a copy of the driver bound to ctx, sharing the same items.
+func (r *Memory) WithContext(ctx context.Context) Cache {
+	return &Memory{ctx: ctx, memoryState: r.memoryState}
+}
+
+// refresh recomputes a stale item in the background, one refresh at a time per item.
+func (r *Memory) refresh(key string, e *memoryEntry, t time.Duration, callback func() (any, error)) {
+	if !e.isStale(r.now()) || !e.refreshing.CompareAndSwap(false, true) {
+		return
 	}
 
-	var err error
-	val, err = callback()
+	go func() {
+		val, err := safeCall(callback)
+		if err != nil || val == nil {
+			e.refreshing.Store(false)
+			return
+		}
+
+		_ = r.Put(key, val, WithTTL(t), WithSoftTTL(e.softTTL))
+	}()
+}
+
+// put stores value under key, returning the generation and entry it was stored as.
+func (r *Memory) put(key string, value any, o putOptions) (*memoryItems, *memoryEntry, error) {
+	e, err := r.newEntry(key, value, o)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if err = r.Put(key, val, NoExpiration); err != nil {
-		return nil, err
+	items := r.items()
+	if o.ttl != NoExpiration && r.clock == nil {
+		e.timer.Store(time.AfterFunc(o.ttl, func() {
+			r.expire(items, key, e)
+		}))
 	}
 
-	return val, nil
+	prev, _ := items.m.Swap(key, e)
+	r.stored(items, key, e, prev)
+	return items, e, nil
 }
 
-func (r *Memory) WithContext(ctx context.Context) Cache {
-	r.ctx = ctx
+// modify replaces the item of key with what fn makes of it, keeping its expiration, cost
+// and priority. A missing item is created without expiration. Modifications of a key are
+// serialized, and fn is called again if the item is written meanwhile.
+func (r *Memory) modify(key string, fn func(value any, exists bool) (any, error)) error {
+	if r.readOnly.Load() {
+		return ErrReadOnly
+	}
 
-	return r
+	r.locks.Lock(key)
+	defer r.locks.Unlock(key)
+
+	for {
+		items := r.items()
+		now := r.now()
+		o := putOptions{cost: 1}
+
+		var current any
+		prev, loaded := items.m.Load(key)
+		exists := false
+		if loaded {
+			if pe := prev.(*memoryEntry); !pe.expired(now) {
+				current, exists = pe.value, true
+				o.cost, o.priority, o.validate = pe.cost, pe.priority, pe.validate
+				if !pe.expires.IsZero() {
+					o.ttl = max(pe.expires.Sub(now), time.Nanosecond)
+				}
+			}
+		}
+
+		val, err := fn(current, exists)
+		if err != nil {
+			return err
+		}
+		e, err := r.newEntry(key, val, o)
+		if err != nil {
+			return err
+		}
+
+		r.invalidate(key)
+		if loaded && !items.m.CompareAndSwap(key, prev, e) {
+			continue
+		}
+		if !loaded {
+			if _, loaded = items.m.LoadOrStore(key, e); loaded {
+				continue
+			}
+		}
+
+		if o.ttl != NoExpiration && r.clock == nil {
+			e.timer.Store(time.AfterFunc(o.ttl, func() {
+				r.expire(items, key, e)
+			}))
+		}
+		r.stored(items, key, e, prev)
+		r.cascade(key)
+		return nil
+	}
+}
+
+// output returns the value to hand out for a stored one, a copy of it with WithCopyValues.
+func (r *Memory) output(value any) any {
+	if !r.copyValues {
+		return value
+	}
+	if res, err := copyValue(value); err == nil {
+		return res
+	}
+
+	return value
+}
+
+// load returns the entry stored under key, treating expired entries as missing.
+func (r *Memory) load(key string) (*memoryEntry, bool) {
+	items := r.items()
+	val, exist := items.m.Load(key)
+	if !exist {
+		return nil, false
+	}
+
+	e := val.(*memoryEntry)
+	if e.expired(r.now()) {
+		r.expire(items, key, e)
+		return nil, false
+	}
+	if e.invalid() {
+		r.discard(items, key, e, EventDelete)
+		return nil, false
+	}
+
+	return e, true
 }
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+const (
+	// envelopeMagic starts every payload written by an envelope codec.
+	envelopeMagic = 0xce
+	// envelopeChecksumMagic starts the payloads followed by a checksum of the value.
+	envelopeChecksumMagic = 0xcf
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+type envelopeCodec struct {
+	codec    Codec
+	version  uint64
+	checksum bool
+}
+
+// EnvelopeOption configures an envelope codec.
+type EnvelopeOption func(*envelopeCodec)
+
+// WithSchemaVersion tags payloads with the version of the value's schema. Payloads tagged with
+// another version fail to decode with ErrSchemaMismatch, so bump it when the type of a cached
+// value changes, and items written by the previous deployment are discarded instead of misread.
+func WithSchemaVersion(version uint64) EnvelopeOption {
+	return func(r *envelopeCodec) {
+		r.version = version
+	}
+}
+
+// WithChecksum adds a CRC-32C checksum of the value to payloads, which then fail to decode with
+// ErrChecksumMismatch when they were corrupted, e.g. truncated or with flipped bits. Payloads
+// are verified whenever they carry a checksum, whether or not the option is given.
+func WithChecksum() EnvelopeOption {
+	return func(r *envelopeCodec) {
+		r.checksum = true
+	}
+}
+
+// Envelope returns a codec wrapping the payloads of codec in an envelope carrying metadata about them.
+// Payloads that weren't written by an envelope fail to decode with ErrSchemaMismatch.
+func Envelope(codec Codec, opts ...EnvelopeOption) Codec {
+	r := &envelopeCodec{codec: codec}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func (r *envelopeCodec) Marshal(v any) ([]byte, error) {
+	payload, err := r.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, 1+binary.MaxVarintLen64+crc32.Size+len(payload))
+	if r.checksum {
+		data = append(data, envelopeChecksumMagic)
+	} else {
+		data = append(data, envelopeMagic)
+	}
+	data = binary.AppendUvarint(data, r.version)
+	if r.checksum {
+		data = binary.BigEndian.AppendUint32(data, crc32.Checksum(payload, crc32c))
+	}
+
+	return append(data, payload...), nil
+}
+
+func (r *envelopeCodec) Unmarshal(data []byte, v any) error {
+	if len(data) == 0 || (data[0] != envelopeMagic && data[0] != envelopeChecksumMagic) {
+		return fmt.Errorf("%w: payload has no envelope", ErrSchemaMismatch)
+	}
+
+	version, n := binary.Uvarint(data[1:])
+	if n <= 0 {
+		return fmt.Errorf("%w: malformed envelope", ErrSchemaMismatch)
+	}
+	payload := data[1+n:]
+	if data[0] == envelopeChecksumMagic {
+		if len(payload) < crc32.Size {
+			return fmt.Errorf("%w: payload is truncated", ErrChecksumMismatch)
+		}
+		sum := binary.BigEndian.Uint32(payload)
+		payload = payload[crc32.Size:]
+		if crc32.Checksum(payload, crc32c) != sum {
+			return ErrChecksumMismatch
+		}
+	}
+	if version != r.version {
+		return fmt.Errorf("%w: payload has version %d, want %d", ErrSchemaMismatch, version, r.version)
+	}
+
+	return r.codec.Unmarshal(payload, v)
+}
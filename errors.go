@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"errors"
+)
+
+var (
+	// ErrKeyNotFound is returned by operations that need an existing item.
+	ErrKeyNotFound = errors.New("cache: key not found")
+	// ErrDriverUnavailable is returned when the store behind a cache can't be reached.
+	ErrDriverUnavailable = errors.New("cache: driver unavailable")
+	// ErrLockTimeout is returned when a lock couldn't be acquired in time.
+	ErrLockTimeout = errors.New("cache: timed out acquiring the lock")
+	// ErrClosed is returned by operations waiting on a driver that was closed.
+	ErrClosed = errors.New("cache: driver is closed")
+	// ErrSchemaMismatch is returned when decoding a payload written for another schema version.
+	ErrSchemaMismatch = errors.New("cache: schema version mismatch")
+	// ErrChecksumMismatch is returned when decoding a payload that was corrupted.
+	ErrChecksumMismatch = errors.New("cache: checksum mismatch")
+	// ErrReadOnly is returned by writes to a driver in read-only mode.
+	ErrReadOnly = errors.New("cache: driver is read-only")
+	// ErrInvalidValueType is returned by Increment and Decrement when the item isn't a counter.
+	ErrInvalidValueType = errors.New("cache: invalid int value type")
+	// ErrTransactionConflict is returned by a transaction when a key it read changed before commit.
+	ErrTransactionConflict = errors.New("cache: transaction conflict: a key changed before commit")
+	// ErrCostExceeded is returned by writes of an item costing more than the cache holds.
+	ErrCostExceeded = errors.New("cache: item cost exceeds the max cost of the cache")
+	// ErrQuotaExceeded is returned by writes of an item larger than the quota of its namespace.
+	ErrQuotaExceeded = errors.New("cache: item size exceeds the quota of its namespace")
+	// ErrAsyncQueueFull is returned by PutAsync when the write queue is full.
+	ErrAsyncQueueFull = errors.New("cache: async write queue is full")
+	// ErrAsyncClosed is returned by PutAsync once the async writer is closed.
+	ErrAsyncClosed = errors.New("cache: async writer is closed")
+	// ErrNotLeader is returned by Renew and Resign when this instance doesn't hold the leadership.
+	ErrNotLeader = errors.New("cache: not the leader")
+	// ErrInvalidSchedule is returned when a cron schedule can't be parsed.
+	ErrInvalidSchedule = errors.New("cache: invalid schedule")
+	// ErrPatternUnsupported is returned when forgetting keys by pattern from a store that can't.
+	ErrPatternUnsupported = errors.New("cache: store can't forget keys by pattern")
+	// ErrWaitTimeout is returned when no item was stored under a key waited for in time.
+	ErrWaitTimeout = errors.New("cache: timed out waiting for the key")
+	// ErrChunkMissing is returned when reading a chunked value one of whose chunks is gone.
+	ErrChunkMissing = errors.New("cache: chunk of the value is missing")
+	// ErrNoDefault is returned by Default when no store was set with SetDefault.
+	ErrNoDefault = errors.New("cache: no default store, call SetDefault first")
+	// ErrKeyPolicy is wrapped by the errors of writes violating a KeyPolicy.
+	ErrKeyPolicy = errors.New("cache: key violates the key policy")
+	// ErrRecordTooLarge is returned by Import when a record is larger than 64 MiB.
+	ErrRecordTooLarge = errors.New("cache: import record is too large")
+)
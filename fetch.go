@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Fetched is a response body fetched by FetchConditional, with the validators it came with.
+type Fetched struct {
+	Body         []byte
+	ETag         string
+	LastModified string
+	// Changed reports whether the body was fetched afresh rather than taken from the cache.
+	Changed bool
+}
+
+// FetchConditional gets url, sending the validators of the response stored for it, if any, in
+// If-None-Match and If-Modified-Since headers, so the body is only downloaded again when upstream
+// changed. Responses are stored indefinitely under "http:" and the url. Statuses other than
+// 200 and 304 are reported as errors, leaving the stored response alone.
+func FetchConditional(ctx context.Context, instance Cache, url string) (Fetched, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Fetched{}, err
+	}
+
+	key := "http:" + url
+	stored, cached := instance.Get(key).(Fetched)
+	if cached {
+		if stored.ETag != "" {
+			req.Header.Set("If-None-Match", stored.ETag)
+		}
+		if stored.LastModified != "" {
+			req.Header.Set("If-Modified-Since", stored.LastModified)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Fetched{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		return stored, nil
+	case resp.StatusCode != http.StatusOK:
+		return Fetched{}, fmt.Errorf("cache: fetch of %s: %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Fetched{}, err
+	}
+
+	res := Fetched{Body: body, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if err = instance.Put(key, res); err != nil {
+		return Fetched{}, err
+	}
+
+	res.Changed = true
+	return res, nil
+}
@@ -0,0 +1,154 @@
+// Package flags caches feature-flag evaluations on top of any cache driver.
+package flags
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cast"
+
+	"github.com/go-rat/cache"
+)
+
+const keyPrefix = "flags:"
+
+// Provider evaluates feature flags from their source of truth.
+type Provider interface {
+	// Flags returns the values of the given flags, leaving out the ones it doesn't know.
+	Flags(ctx context.Context, names []string) (map[string]any, error)
+}
+
+// ProviderFunc adapts a function to a Provider.
+type ProviderFunc func(ctx context.Context, names []string) (map[string]any, error)
+
+func (f ProviderFunc) Flags(ctx context.Context, names []string) (map[string]any, error) {
+	return f(ctx, names)
+}
+
+// Store serves flag values from the cache, asking the provider for the ones that
+// are missing or expired.
+type Store struct {
+	cache    cache.Cache
+	provider Provider
+	ttl      time.Duration
+}
+
+func NewStore(instance cache.Cache, provider Provider, ttl time.Duration) *Store {
+	return &Store{
+		cache:    instance,
+		provider: provider,
+		ttl:      ttl,
+	}
+}
+
+// Value returns the value of a flag, and false if the provider doesn't know it.
+func (r *Store) Value(ctx context.Context, name string) (any, bool, error) {
+	values, err := r.Values(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	val, ok := values[name]
+	return val, ok, nil
+}
+
+// Values returns the values of several flags, asking the provider once for all the missing ones.
+func (r *Store) Values(ctx context.Context, names ...string) (map[string]any, error) {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = keyPrefix + name
+	}
+
+	cached, err := cache.RememberMany(r.cache, keys, r.ttl, func(missing []string) (map[string]any, error) {
+		missingNames := make([]string, len(missing))
+		for i, key := range missing {
+			missingNames[i] = key[len(keyPrefix):]
+		}
+
+		values, err := r.provider.Flags(ctx, missingNames)
+		if err != nil {
+			return nil, err
+		}
+
+		res := make(map[string]any, len(values))
+		for name, val := range values {
+			res[keyPrefix+name] = val
+		}
+		return res, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]any, len(cached))
+	for key, val := range cached {
+		res[key[len(keyPrefix):]] = val
+	}
+
+	return res, nil
+}
+
+// Refresh asks the provider for the given flags and replaces their cached values.
+func (r *Store) Refresh(ctx context.Context, names ...string) error {
+	values, err := r.provider.Flags(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	pipeline := r.cache.Pipeline()
+	for _, name := range names {
+		if val, ok := values[name]; ok {
+			pipeline.Put(keyPrefix+name, val, cache.WithTTL(r.ttl))
+		} else {
+			pipeline.Forget(keyPrefix + name)
+		}
+	}
+
+	_, err = pipeline.Exec(ctx)
+	return err
+}
+
+// BoolFlag returns a flag as a boolean, or def if it's unknown or can't be evaluated.
+func (r *Store) BoolFlag(ctx context.Context, name string, def bool) bool {
+	val, ok, err := r.Value(ctx, name)
+	if err != nil || !ok {
+		return def
+	}
+
+	res, err := cast.ToBoolE(val)
+	if err != nil {
+		return def
+	}
+
+	return res
+}
+
+// StringFlag returns a flag as a string, or def if it's unknown or can't be evaluated.
+func (r *Store) StringFlag(ctx context.Context, name string, def string) string {
+	val, ok, err := r.Value(ctx, name)
+	if err != nil || !ok {
+		return def
+	}
+
+	res, err := cast.ToStringE(val)
+	if err != nil {
+		return def
+	}
+
+	return res
+}
+
+// IntFlag returns a flag as an integer, or def if it's unknown or can't be evaluated.
+func (r *Store) IntFlag(ctx context.Context, name string, def int) int {
+	val, ok, err := r.Value(ctx, name)
+	if err != nil || !ok {
+		return def
+	}
+
+	res, err := cast.ToIntE(val)
+	if err != nil {
+		return def
+	}
+
+	return res
+}
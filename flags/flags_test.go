@@ -0,0 +1,88 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/go-rat/cache"
+)
+
+type FlagsTestSuite struct {
+	suite.Suite
+	memory *cache.Memory
+	values map[string]any
+	calls  [][]string
+	err    error
+	store  *Store
+}
+
+func TestFlagsTestSuite(t *testing.T) {
+	suite.Run(t, new(FlagsTestSuite))
+}
+
+func (s *FlagsTestSuite) SetupTest() {
+	s.memory = cache.NewMemory()
+	s.values = map[string]any{"new-ui": true, "theme": "dark", "limit": "10"}
+	s.calls = nil
+	s.err = nil
+	s.store = NewStore(s.memory, ProviderFunc(func(ctx context.Context, names []string) (map[string]any, error) {
+		s.calls = append(s.calls, names)
+		if s.err != nil {
+			return nil, s.err
+		}
+
+		res := make(map[string]any)
+		for _, name := range names {
+			if val, ok := s.values[name]; ok {
+				res[name] = val
+			}
+		}
+		return res, nil
+	}), time.Minute)
+}
+
+func (s *FlagsTestSuite) TestTypedFlags() {
+	ctx := context.Background()
+	s.True(s.store.BoolFlag(ctx, "new-ui", false))
+	s.Equal("dark", s.store.StringFlag(ctx, "theme", "light"))
+	s.Equal(10, s.store.IntFlag(ctx, "limit", 0))
+	s.Equal(5, s.store.IntFlag(ctx, "theme", 5))
+	s.Equal("light", s.store.StringFlag(ctx, "missing", "light"))
+
+	s.True(s.store.BoolFlag(ctx, "new-ui", false))
+	s.Len(s.calls, 4)
+
+	s.err = errors.New("error")
+	s.True(s.store.BoolFlag(ctx, "other", true))
+}
+
+func (s *FlagsTestSuite) TestValues() {
+	values, err := s.store.Values(context.Background(), "new-ui", "theme", "missing")
+	s.Nil(err)
+	s.Equal(map[string]any{"new-ui": true, "theme": "dark"}, values)
+	s.Equal([][]string{{"new-ui", "theme", "missing"}}, s.calls)
+
+	values, err = s.store.Values(context.Background(), "new-ui", "theme")
+	s.Nil(err)
+	s.Len(values, 2)
+	s.Len(s.calls, 1)
+}
+
+func (s *FlagsTestSuite) TestRefresh() {
+	ctx := context.Background()
+	s.True(s.store.BoolFlag(ctx, "new-ui", false))
+	s.Equal("dark", s.store.StringFlag(ctx, "theme", ""))
+
+	s.values["new-ui"] = false
+	delete(s.values, "theme")
+	s.Nil(s.store.Refresh(ctx, "new-ui", "theme"))
+	s.False(s.store.BoolFlag(ctx, "new-ui", true))
+	s.False(s.memory.Has("flags:theme"))
+
+	s.err = errors.New("error")
+	s.EqualError(s.store.Refresh(ctx, "new-ui"), "error")
+}
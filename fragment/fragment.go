@@ -0,0 +1,54 @@
+// Package fragment caches the rendered fragments of server-rendered pages, such as the output
+// of templates, so they're written out from the cache instead of being rendered on every request.
+package fragment
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-rat/cache"
+)
+
+// Store keeps rendered fragments in a cache.
+type Store struct {
+	cache cache.Cache
+}
+
+// NewStore creates a Store keeping fragments in instance.
+func NewStore(instance cache.Cache) *Store {
+	return &Store{cache: instance}
+}
+
+// Fragment writes the fragment of key to w, calling render to produce and store it for ttl
+// when it isn't cached. Fragments are rendered to a buffer first, so a failing render writes
+// nothing to w and isn't stored. Concurrent misses of a key render once, as with Remember.
+func (r *Store) Fragment(w io.Writer, key string, ttl time.Duration, render func(w io.Writer) error) error {
+	val, err := r.cache.Remember(key, ttl, func() (any, error) {
+		var buf bytes.Buffer
+		if err := render(&buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch v := val.(type) {
+	case []byte:
+		_, err = w.Write(v)
+	case string:
+		_, err = io.WriteString(w, v)
+	default:
+		err = fmt.Errorf("fragment: value of key %s is %T, not []byte", key, val)
+	}
+
+	return err
+}
+
+// Forget drops the fragment of key, so it's rendered again next time.
+func (r *Store) Forget(key string) bool {
+	return r.cache.Forget(key)
+}
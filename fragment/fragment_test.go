@@ -0,0 +1,65 @@
+package fragment
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/go-rat/cache"
+)
+
+type FragmentTestSuite struct {
+	suite.Suite
+	memory *cache.Memory
+	store  *Store
+}
+
+func TestFragmentTestSuite(t *testing.T) {
+	suite.Run(t, new(FragmentTestSuite))
+}
+
+func (s *FragmentTestSuite) SetupTest() {
+	s.memory = cache.NewMemory()
+	s.store = NewStore(s.memory)
+}
+
+func (s *FragmentTestSuite) TestFragment() {
+	tmpl := template.Must(template.New("nav").Parse(`<nav>{{.}}</nav>`))
+	var renders int
+	render := func(w io.Writer) error {
+		renders++
+		return tmpl.Execute(w, "Rat")
+	}
+
+	var buf bytes.Buffer
+	s.Nil(s.store.Fragment(&buf, "nav", 1*time.Second, render))
+	s.Equal("<nav>Rat</nav>", buf.String())
+	buf.Reset()
+	s.Nil(s.store.Fragment(&buf, "nav", 1*time.Second, render))
+	s.Equal("<nav>Rat</nav>", buf.String())
+	s.Equal(1, renders)
+
+	s.True(s.store.Forget("nav"))
+	buf.Reset()
+	s.Nil(s.store.Fragment(&buf, "nav", 1*time.Second, render))
+	s.Equal(2, renders)
+}
+
+func (s *FragmentTestSuite) TestFailedRender() {
+	var buf bytes.Buffer
+	err := s.store.Fragment(&buf, "broken", 1*time.Second, func(w io.Writer) error {
+		_, _ = io.WriteString(w, "<nav>")
+		return errors.New("boom")
+	})
+	s.EqualError(err, "boom")
+	s.Empty(buf.String())
+	s.False(s.memory.Has("broken"))
+
+	s.Nil(s.memory.Put("number", 1))
+	s.EqualError(s.store.Fragment(&buf, "number", 1*time.Second, nil), "fragment: value of key number is int, not []byte")
+}
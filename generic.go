@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetT retrieve an item from the cache by key as T.
+// The default is returned when the item is missing or holds a different type.
+func GetT[T any](instance Cache, key string, def ...T) T {
+	if val, ok := instance.Get(key).(T); ok {
+		return val
+	}
+	if len(def) == 0 {
+		var zero T
+		return zero
+	}
+
+	return def[0]
+}
+
+// RememberT get an item from the cache as T, or execute the given Closure and store the result.
+func RememberT[T any](instance Cache, key string, ttl time.Duration, callback func() (T, error)) (T, error) {
+	return typed[T](key)(instance.Remember(key, ttl, func() (any, error) {
+		return callback()
+	}))
+}
+
+// RememberForeverT get an item from the cache as T, or execute the given Closure and store the result forever.
+func RememberForeverT[T any](instance Cache, key string, callback func() (T, error)) (T, error) {
+	return typed[T](key)(instance.RememberForever(key, func() (any, error) {
+		return callback()
+	}))
+}
+
+func typed[T any](key string) func(val any, err error) (T, error) {
+	return func(val any, err error) (T, error) {
+		var zero T
+		if err != nil {
+			return zero, err
+		}
+
+		res, ok := val.(T)
+		if !ok {
+			return zero, fmt.Errorf("cache: value of key %s is %T, not %T", key, val, zero)
+		}
+
+		return res, nil
+	}
+}
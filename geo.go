@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"errors"
+	"math"
+)
+
+// Geoer is implemented by stores indexing locations by position, as Redis GEO commands.
+// As with Redis, the locations of a key are kept in a sorted set scored by geohash,
+// positions being accurate to well under a meter.
+type Geoer interface {
+	// GeoAdd adds locations to the set of key, creating it if missing, moving those already
+	// there. It returns how many locations were added.
+	GeoAdd(key string, locations ...GeoLocation) (int, error)
+	// GeoPos returns the location name in the set of key, reporting whether it's there.
+	GeoPos(key, name string) (GeoLocation, bool, error)
+	// GeoDist returns the distance in meters between two locations of the set of key,
+	// reporting whether both are there.
+	GeoDist(key, a, b string) (float64, bool, error)
+	// GeoRadius returns the locations of the set of key within radius meters of the given
+	// position, nearest first, with their Dist set. A positive count keeps the nearest count.
+	GeoRadius(key string, longitude, latitude, radius float64, count int) ([]GeoLocation, error)
+}
+
+// GeoLocation is a named position.
+type GeoLocation struct {
+	Name      string
+	Longitude float64
+	Latitude  float64
+	// Dist is the distance in meters from the center of a GeoRadius search.
+	Dist float64
+}
+
+const (
+	geoStep        = 26
+	geoLatitudeMax = 85.05112878
+	earthRadius    = 6372797.560856
+)
+
+var errInvalidCoordinates = errors.New("cache: invalid coordinates")
+
+func geoValid(longitude, latitude float64) bool {
+	return longitude >= -180 && longitude <= 180 && latitude >= -geoLatitudeMax && latitude <= geoLatitudeMax
+}
+
+// geoEncode returns the 52 bit geohash of a position, latitude bits in the even positions.
+func geoEncode(longitude, latitude float64) float64 {
+	lat := uint64((latitude + geoLatitudeMax) / (2 * geoLatitudeMax) * (1 << geoStep))
+	lon := uint64((longitude + 180) / 360 * (1 << geoStep))
+	lat, lon = min(lat, 1<<geoStep-1), min(lon, 1<<geoStep-1)
+
+	return float64(geoSpread(lat) | geoSpread(lon)<<1)
+}
+
+// geoDecode returns the center of the cell of a geohash.
+func geoDecode(hash float64) (longitude, latitude float64) {
+	bits := uint64(hash)
+	lat, lon := geoSquash(bits), geoSquash(bits>>1)
+
+	latitude = (float64(lat)+0.5)/(1<<geoStep)*(2*geoLatitudeMax) - geoLatitudeMax
+	longitude = (float64(lon)+0.5)/(1<<geoStep)*360 - 180
+	return longitude, latitude
+}
+
+// geoSpread moves the bits of v to the even positions.
+func geoSpread(v uint64) uint64 {
+	v = (v | v<<16) & 0x0000FFFF0000FFFF
+	v = (v | v<<8) & 0x00FF00FF00FF00FF
+	v = (v | v<<4) & 0x0F0F0F0F0F0F0F0F
+	v = (v | v<<2) & 0x3333333333333333
+	v = (v | v<<1) & 0x5555555555555555
+
+	return v
+}
+
+// geoSquash is the inverse of geoSpread.
+func geoSquash(v uint64) uint64 {
+	v &= 0x5555555555555555
+	v = (v | v>>1) & 0x3333333333333333
+	v = (v | v>>2) & 0x0F0F0F0F0F0F0F0F
+	v = (v | v>>4) & 0x00FF00FF00FF00FF
+	v = (v | v>>8) & 0x0000FFFF0000FFFF
+	v = (v | v>>16) & 0x00000000FFFFFFFF
+
+	return v
+}
+
+// geoDistance returns the great-circle distance in meters between two positions.
+func geoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1, lat2 = lat1*math.Pi/180, lat2*math.Pi/180
+	u := math.Sin((lat2 - lat1) / 2)
+	v := math.Sin((lon2 - lon1) * math.Pi / 180 / 2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1)*math.Cos(lat2)*v*v))
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// hllPrecision is the number of hash bits picking a register, for a standard error of about 0.8%.
+const hllPrecision = 14
+
+// DistinctCounter is implemented by stores that count distinct elements approximately,
+// as Redis HyperLogLogs.
+type DistinctCounter interface {
+	// PFAdd adds elements to the counter of key, creating it if missing,
+	// and reports whether the estimate changed.
+	PFAdd(key string, elements ...string) (bool, error)
+	// PFCount returns the estimated number of distinct elements added to the counters of keys.
+	PFCount(keys ...string) (int64, error)
+}
+
+// HyperLogLog estimates the number of distinct elements added to it in a fixed 16 KiB.
+// It's safe for concurrent use.
+type HyperLogLog struct {
+	mu        sync.RWMutex
+	registers [1 << hllPrecision]uint8
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{}
+}
+
+// Add adds elements, reporting whether the estimate changed.
+func (r *HyperLogLog) Add(elements ...string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var changed bool
+	for _, element := range elements {
+		hash := hllHash(element)
+		index := hash >> (64 - hllPrecision)
+		rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+		if rank > r.registers[index] {
+			r.registers[index] = rank
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// Count returns the estimated number of distinct elements added.
+func (r *HyperLogLog) Count() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return hllEstimate(&r.registers)
+}
+
+// Merge adds the elements of others.
+func (r *HyperLogLog) Merge(others ...*HyperLogLog) {
+	for _, other := range others {
+		if other == r {
+			continue
+		}
+		other.mu.RLock()
+		registers := other.registers
+		other.mu.RUnlock()
+
+		r.mu.Lock()
+		for i, v := range registers {
+			r.registers[i] = max(r.registers[i], v)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *HyperLogLog) Clone() any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return &HyperLogLog{registers: r.registers}
+}
+
+// MarshalBinary encodes the registers, so codecs such as GobCodec can store the counter.
+func (r *HyperLogLog) MarshalBinary() ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]byte(nil), r.registers[:]...), nil
+}
+
+func (r *HyperLogLog) UnmarshalBinary(data []byte) error {
+	if len(data) != len(r.registers) {
+		return errors.New("cache: invalid HyperLogLog encoding")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copy(r.registers[:], data)
+	return nil
+}
+
+func hllHash(element string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(element))
+
+	// FNV clusters similar inputs, so the bits are mixed with the splitmix64 finalizer.
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
+
+func hllEstimate(registers *[1 << hllPrecision]uint8) int64 {
+	m := float64(len(registers))
+
+	var sum float64
+	var zeros int
+	for _, v := range registers {
+		sum += math.Ldexp(1, -int(v))
+		if v == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// Small cardinalities are estimated better by linear counting.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return int64(math.Round(estimate))
+}
@@ -0,0 +1,136 @@
+// Package idempotency remembers the outcome of requests by their idempotency key,
+// so retried requests get the original result instead of running again.
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/go-rat/cache"
+)
+
+var (
+	// ErrInProgress is returned by Begin while another attempt of the request runs.
+	ErrInProgress = errors.New("idempotency: request is still in progress")
+	// ErrFingerprintMismatch is returned when the key belongs to a different request.
+	ErrFingerprintMismatch = errors.New("idempotency: key was used by a different request")
+	// ErrNotStarted is returned by Complete and Fail for a request that wasn't started with Begin.
+	ErrNotStarted = errors.New("idempotency: request was not started")
+	// ErrNotStored is returned by Begin when the cache refuses to store the record.
+	ErrNotStored = errors.New("idempotency: cache refused to store the record")
+)
+
+// claimAttempts bounds how many times Begin tries to claim a key that keeps disappearing.
+const claimAttempts = 3
+
+// State is the stage a request is at.
+type State int
+
+const (
+	// StateInProgress means the request was started but hasn't completed yet.
+	StateInProgress State = iota
+	// StateCompleted means the request completed and its result is stored.
+	StateCompleted
+)
+
+// Record is what's stored for an idempotency key.
+type Record struct {
+	State       State
+	Fingerprint string
+	Result      any
+}
+
+// Store keeps the records of requests in a cache.
+type Store struct {
+	cache   cache.Cache
+	ttl     time.Duration
+	pending time.Duration
+}
+
+// NewStore creates a Store keeping completed requests for ttl. A request left in progress
+// for longer than pending, e.g. because its handler crashed, can be started again.
+func NewStore(instance cache.Cache, ttl, pending time.Duration) *Store {
+	return &Store{
+		cache:   instance,
+		ttl:     ttl,
+		pending: pending,
+	}
+}
+
+// Begin claims key for the request identified by fingerprint, returning a nil record when the
+// request should run. If it already completed its record is returned, holding the original result.
+// ErrInProgress is returned while another attempt runs, and ErrFingerprintMismatch when the key
+// belongs to a different request. ErrNotStored is returned when the cache refuses the record,
+// e.g. for being read-only or over its quota, so the request can't be run safely.
+func (r *Store) Begin(key, fingerprint string) (*Record, error) {
+	pending := Record{State: StateInProgress, Fingerprint: fingerprint}
+	for i := 0; ; i++ {
+		if r.cache.Add(key, pending, r.pending) {
+			return nil, nil
+		}
+
+		val := r.cache.Get(key)
+		if val == nil {
+			// The record expired or was failed in between, try to claim the key again, unless
+			// it's missing because the cache doesn't store it.
+			if i+1 == claimAttempts {
+				return nil, ErrNotStored
+			}
+			time.Sleep(time.Duration(i+1) * time.Millisecond)
+			continue
+		}
+		rec, ok := val.(Record)
+		if !ok || rec.Fingerprint != fingerprint {
+			return nil, ErrFingerprintMismatch
+		}
+		if rec.State == StateInProgress {
+			return nil, ErrInProgress
+		}
+
+		return &rec, nil
+	}
+}
+
+// Complete stores the result of a request started with Begin.
+func (r *Store) Complete(key, fingerprint string, result any) error {
+	if err := r.check(key, fingerprint); err != nil {
+		return err
+	}
+
+	return r.cache.Put(key, Record{State: StateCompleted, Fingerprint: fingerprint, Result: result}, cache.WithTTL(r.ttl))
+}
+
+// Fail releases a request started with Begin, so it can be retried.
+func (r *Store) Fail(key, fingerprint string) error {
+	if err := r.check(key, fingerprint); err != nil {
+		return err
+	}
+
+	r.cache.Forget(key)
+	return nil
+}
+
+func (r *Store) check(key, fingerprint string) error {
+	rec, ok := r.cache.Get(key).(Record)
+	if !ok || rec.State != StateInProgress {
+		return ErrNotStarted
+	}
+	if rec.Fingerprint != fingerprint {
+		return ErrFingerprintMismatch
+	}
+
+	return nil
+}
+
+// Fingerprint hashes the parts identifying a request, such as its method, path and body.
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		_, _ = h.Write(part)
+		_, _ = h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,82 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/go-rat/cache"
+)
+
+type IdempotencyTestSuite struct {
+	suite.Suite
+	memory *cache.Memory
+	store  *Store
+}
+
+func TestIdempotencyTestSuite(t *testing.T) {
+	suite.Run(t, new(IdempotencyTestSuite))
+}
+
+func (s *IdempotencyTestSuite) SetupTest() {
+	s.memory = cache.NewMemory()
+	s.store = NewStore(s.memory, 1*time.Second, 100*time.Millisecond)
+}
+
+func (s *IdempotencyTestSuite) TestComplete() {
+	fingerprint := Fingerprint([]byte("POST"), []byte("/orders"), []byte(`{"id":1}`))
+
+	rec, err := s.store.Begin("order", fingerprint)
+	s.Nil(err)
+	s.Nil(rec)
+
+	_, err = s.store.Begin("order", fingerprint)
+	s.ErrorIs(err, ErrInProgress)
+	_, err = s.store.Begin("order", Fingerprint([]byte("other")))
+	s.ErrorIs(err, ErrFingerprintMismatch)
+
+	s.ErrorIs(s.store.Complete("order", "other", "created"), ErrFingerprintMismatch)
+	s.Nil(s.store.Complete("order", fingerprint, "created"))
+	s.ErrorIs(s.store.Complete("order", fingerprint, "created"), ErrNotStarted)
+
+	rec, err = s.store.Begin("order", fingerprint)
+	s.Nil(err)
+	s.Equal(&Record{State: StateCompleted, Fingerprint: fingerprint, Result: "created"}, rec)
+}
+
+func (s *IdempotencyTestSuite) TestFail() {
+	rec, err := s.store.Begin("payment", "fingerprint")
+	s.Nil(err)
+	s.Nil(rec)
+
+	s.Nil(s.store.Fail("payment", "fingerprint"))
+	s.ErrorIs(s.store.Fail("payment", "fingerprint"), ErrNotStarted)
+
+	rec, err = s.store.Begin("payment", "fingerprint")
+	s.Nil(err)
+	s.Nil(rec)
+}
+
+func (s *IdempotencyTestSuite) TestPendingExpired() {
+	rec, err := s.store.Begin("refund", "fingerprint")
+	s.Nil(err)
+	s.Nil(rec)
+
+	time.Sleep(150 * time.Millisecond)
+	rec, err = s.store.Begin("refund", "fingerprint")
+	s.Nil(err)
+	s.Nil(rec)
+}
+
+func (s *IdempotencyTestSuite) TestNotStored() {
+	s.memory.SetReadOnly(true)
+	rec, err := s.store.Begin("refund", "fingerprint")
+	s.ErrorIs(err, ErrNotStored)
+	s.Nil(rec)
+}
+
+func (s *IdempotencyTestSuite) TestFingerprint() {
+	s.Equal(Fingerprint([]byte("a"), []byte("b")), Fingerprint([]byte("a"), []byte("b")))
+	s.NotEqual(Fingerprint([]byte("ab")), Fingerprint([]byte("a"), []byte("b")))
+}
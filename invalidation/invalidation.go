@@ -0,0 +1,91 @@
+// Package invalidation keeps caches consistent with their source of truth by
+// forgetting keys when change events arrive, e.g. from a database's change feed.
+package invalidation
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-rat/cache"
+)
+
+// Event is a change reported by a source.
+type Event struct {
+	// Entity is what changed, such as a table name.
+	Entity string `json:"entity"`
+	// ID identifies the changed record.
+	ID string `json:"id"`
+	// Op is the kind of change, such as insert, update or delete.
+	Op string `json:"op"`
+	// Data holds any other attributes of the change.
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// Invalidation is what to forget for an event.
+type Invalidation struct {
+	// Keys are forgotten as is.
+	Keys []string
+	// Patterns are forgotten with cache.PatternForgetter.
+	Patterns []string
+}
+
+// Mapper decides what an event invalidates.
+type Mapper func(Event) Invalidation
+
+// Source delivers change events to handle until ctx is done or it fails.
+// Adapters for brokers such as Kafka or Postgres LISTEN/NOTIFY implement it
+// on top of their client, WebhookSource receives events over HTTP.
+type Source interface {
+	Run(ctx context.Context, handle func(Event) error) error
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func(ctx context.Context, handle func(Event) error) error
+
+func (f SourceFunc) Run(ctx context.Context, handle func(Event) error) error {
+	return f(ctx, handle)
+}
+
+// Listener applies the events of sources to a cache.
+type Listener struct {
+	store  cache.Cache
+	mapper Mapper
+}
+
+func NewListener(instance cache.Cache, mapper Mapper) *Listener {
+	return &Listener{
+		store:  instance,
+		mapper: mapper,
+	}
+}
+
+// Listen consumes the events of source until ctx is done, returning nil in that case.
+func (r *Listener) Listen(ctx context.Context, source Source) error {
+	err := source.Run(ctx, r.Handle)
+	if ctx.Err() != nil && (err == nil || errors.Is(err, ctx.Err())) {
+		return nil
+	}
+
+	return err
+}
+
+// Handle forgets what the event invalidates.
+func (r *Listener) Handle(event Event) error {
+	inv := r.mapper(event)
+	for _, key := range inv.Keys {
+		r.store.Forget(key)
+	}
+
+	if len(inv.Patterns) == 0 {
+		return nil
+	}
+	forgetter, ok := r.store.(cache.PatternForgetter)
+	if !ok {
+		return cache.ErrPatternUnsupported
+	}
+	for _, pattern := range inv.Patterns {
+		forgetter.ForgetPattern(pattern)
+	}
+
+	return nil
+}
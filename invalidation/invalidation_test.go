@@ -0,0 +1,156 @@
+package invalidation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/go-rat/cache"
+)
+
+type InvalidationTestSuite struct {
+	suite.Suite
+	memory   *cache.Memory
+	listener *Listener
+}
+
+func TestInvalidationTestSuite(t *testing.T) {
+	suite.Run(t, new(InvalidationTestSuite))
+}
+
+func (s *InvalidationTestSuite) SetupTest() {
+	s.memory = cache.NewMemory()
+	s.listener = NewListener(s.memory, func(event Event) Invalidation {
+		return Invalidation{
+			Keys:     []string{event.Entity + ":" + event.ID},
+			Patterns: []string{"list:" + event.Entity + ":*"},
+		}
+	})
+
+	s.Nil(s.memory.Put("users:1", "Rat"))
+	s.Nil(s.memory.Put("users:2", "Go"))
+	s.Nil(s.memory.Put("list:users:1", []string{"Rat", "Go"}))
+}
+
+func (s *InvalidationTestSuite) TestHandle() {
+	s.Nil(s.listener.Handle(Event{Entity: "users", ID: "1", Op: "update"}))
+	s.False(s.memory.Has("users:1"))
+	s.False(s.memory.Has("list:users:1"))
+	s.True(s.memory.Has("users:2"))
+
+	s.ErrorIs(NewListener(cache.Coalesce(s.memory, time.Second), s.listener.mapper).Handle(Event{Entity: "users", ID: "2"}), cache.ErrPatternUnsupported)
+	s.False(s.memory.Has("users:2"))
+}
+
+func (s *InvalidationTestSuite) TestListen() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Nil(s.listener.Listen(ctx, SourceFunc(func(ctx context.Context, handle func(Event) error) error {
+		defer cancel()
+		return handle(Event{Entity: "users", ID: "1", Op: "delete"})
+	})))
+	s.False(s.memory.Has("users:1"))
+
+	s.EqualError(s.listener.Listen(context.Background(), SourceFunc(func(context.Context, func(Event) error) error {
+		return errors.New("error")
+	})), "error")
+}
+
+func (s *InvalidationTestSuite) TestWebhookSource() {
+	secret := []byte("secret")
+	source := NewWebhookSource(secret, WithMaxBody(64))
+	server := httptest.NewServer(source)
+	defer server.Close()
+
+	send := func(body, signature string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+		s.Nil(err)
+		req.Header.Set(SignatureHeader, signature)
+		res, err := http.DefaultClient.Do(req)
+		s.Nil(err)
+		s.Nil(res.Body.Close())
+		return res.StatusCode
+	}
+	post := func(body string) int {
+		return send(body, Sign(secret, []byte(body)))
+	}
+
+	s.Equal(http.StatusServiceUnavailable, post(`{"entity":"users","id":"1"}`))
+	s.True(s.memory.Has("users:1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.listener.Listen(ctx, source)
+	}()
+	s.Eventually(func() bool {
+		return post(`{"entity":"users","id":"1"}`) == http.StatusNoContent
+	}, time.Second, 10*time.Millisecond)
+	s.False(s.memory.Has("users:1"))
+	s.False(s.memory.Has("list:users:1"))
+
+	s.Equal(http.StatusNoContent, post(`[{"entity":"users","id":"2"}]`))
+	s.False(s.memory.Has("users:2"))
+	s.Equal(http.StatusBadRequest, post(`{`))
+
+	// Unsigned, badly signed and oversized requests are refused.
+	s.Nil(s.memory.Put("users:1", "Rat"))
+	s.Equal(http.StatusUnauthorized, send(`{"entity":"users","id":"1"}`, ""))
+	s.Equal(http.StatusUnauthorized, send(`{"entity":"users","id":"1"}`, Sign([]byte("other"), []byte(`{"entity":"users","id":"1"}`))))
+	s.True(s.memory.Has("users:1"))
+	s.Equal(http.StatusRequestEntityTooLarge, post(`[`+strings.Repeat(`{"entity":"users","id":"1"},`, 10)+`]`))
+	s.True(s.memory.Has("users:1"))
+	s.Panics(func() {
+		NewWebhookSource(nil)
+	})
+
+	res, err := http.Get(server.URL)
+	s.Nil(err)
+	s.Nil(res.Body.Close())
+	s.Equal(http.StatusMethodNotAllowed, res.StatusCode)
+
+	cancel()
+	s.Nil(<-done)
+}
+
+type user struct {
+	ID int
+}
+
+func (r user) CacheEntity() string {
+	return "users"
+}
+
+func (r user) CacheID() string {
+	return strconv.Itoa(r.ID)
+}
+
+func (s *InvalidationTestSuite) TestModelHooks() {
+	listener := NewListener(s.memory, Templates("{entity}:{id}", "list:{entity}:*"))
+
+	s.Nil(listener.AfterUpdate(user{ID: 1}))
+	s.False(s.memory.Has("users:1"))
+	s.False(s.memory.Has("list:users:1"))
+	s.True(s.memory.Has("users:2"))
+
+	s.Nil(listener.AfterDelete(user{ID: 2}))
+	s.False(s.memory.Has("users:2"))
+	s.Nil(listener.AfterCreate(user{ID: 3}))
+}
+
+func (s *InvalidationTestSuite) TestTemplates() {
+	inv := Templates("{entity}:{id}", "list:{entity}:*", "count:{entity}")(Event{Entity: "users", ID: "1"})
+	s.Equal([]string{"users:1", "count:users"}, inv.Keys)
+	s.Equal([]string{"list:users:*"}, inv.Patterns)
+
+	// Glob metacharacters of the event are matched literally.
+	inv = Templates("{entity}:{id}", "list:{entity}:*")(Event{Entity: "us[e]rs", ID: "1*"})
+	s.Equal([]string{"us[e]rs:1*"}, inv.Keys)
+	s.Equal([]string{`list:us\[e]rs:*`}, inv.Patterns)
+}
@@ -0,0 +1,73 @@
+package invalidation
+
+import (
+	"path"
+	"strings"
+)
+
+// Model is a record cached by entity and ID, such as an ORM model.
+type Model interface {
+	// CacheEntity returns what the model is, such as its table name.
+	CacheEntity() string
+	// CacheID returns what identifies the record, such as its primary key.
+	CacheID() string
+}
+
+// AfterCreate handles the insertion of model, to call from the after-create callback of an ORM,
+// e.g. the AfterCreate hook of a GORM model.
+func (r *Listener) AfterCreate(model Model) error {
+	return r.Handle(Event{Entity: model.CacheEntity(), ID: model.CacheID(), Op: "insert"})
+}
+
+// AfterUpdate handles the update of model, to call from the after-update callback of an ORM.
+func (r *Listener) AfterUpdate(model Model) error {
+	return r.Handle(Event{Entity: model.CacheEntity(), ID: model.CacheID(), Op: "update"})
+}
+
+// AfterDelete handles the deletion of model, to call from the after-delete callback of an ORM.
+func (r *Listener) AfterDelete(model Model) error {
+	return r.Handle(Event{Entity: model.CacheEntity(), ID: model.CacheID(), Op: "delete"})
+}
+
+// Templates returns a Mapper invalidating the given templates, in which {entity} and {id} are
+// replaced by those of the event, e.g. "{entity}:{id}" and "list:{entity}:*". Templates holding
+// glob metacharacters are forgotten as patterns, in which those of the event are matched literally,
+// the others as keys.
+func Templates(templates ...string) Mapper {
+	return func(event Event) Invalidation {
+		keys := strings.NewReplacer("{entity}", event.Entity, "{id}", event.ID)
+		patterns := strings.NewReplacer("{entity}", escapeGlob(event.Entity), "{id}", escapeGlob(event.ID))
+
+		var res Invalidation
+		for _, template := range templates {
+			if strings.ContainsAny(template, globMeta) {
+				if _, err := path.Match(template, ""); err == nil {
+					res.Patterns = append(res.Patterns, patterns.Replace(template))
+					continue
+				}
+			}
+			res.Keys = append(res.Keys, keys.Replace(template))
+		}
+
+		return res
+	}
+}
+
+// globMeta holds the characters path.Match treats specially.
+const globMeta = `*?[\`
+
+// escapeGlob escapes the glob metacharacters of s, so a pattern matches it literally.
+func escapeGlob(s string) string {
+	if !strings.ContainsAny(s, globMeta) {
+		return s
+	}
+
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(globMeta, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+package invalidation
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// SignatureHeader is the header carrying the signature of webhook requests, "sha256=" followed
+// by the hex HMAC-SHA256 of the body keyed with the secret of the WebhookSource.
+const SignatureHeader = "X-Signature"
+
+// WebhookSource is a Source receiving events as JSON over HTTP, one event or an array of them per request.
+// Requests must be signed with the shared secret, see SignatureHeader, or are answered with
+// 401 Unauthorized, and bodies over the maximum size with 413 Request Entity Too Large.
+// Requests are answered with 503 Service Unavailable while no listener runs it.
+type WebhookSource struct {
+	mu      sync.RWMutex
+	handle  func(Event) error
+	secret  []byte
+	maxBody int64
+}
+
+// WebhookOption configures a WebhookSource.
+type WebhookOption func(*WebhookSource)
+
+// WithMaxBody bounds the size of request bodies, 1 MiB by default.
+func WithMaxBody(n int64) WebhookOption {
+	return func(r *WebhookSource) {
+		r.maxBody = n
+	}
+}
+
+// NewWebhookSource creates a WebhookSource accepting the requests signed with secret.
+// It panics if secret is empty, as anyone reaching it could otherwise invalidate any key.
+func NewWebhookSource(secret []byte, opts ...WebhookOption) *WebhookSource {
+	if len(secret) == 0 {
+		panic("invalidation: webhook secret is empty")
+	}
+
+	r := &WebhookSource{secret: secret, maxBody: 1 << 20}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Sign returns the value of SignatureHeader for body signed with secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (r *WebhookSource) Run(ctx context.Context, handle func(Event) error) error {
+	r.mu.Lock()
+	r.handle = handle
+	r.mu.Unlock()
+
+	<-ctx.Done()
+
+	r.mu.Lock()
+	r.handle = nil
+	r.mu.Unlock()
+
+	return ctx.Err()
+}
+
+func (r *WebhookSource) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, r.maxBody))
+	if err != nil {
+		if maxErr := new(http.MaxBytesError); errors.As(err, &maxErr) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	signature := req.Header.Get(SignatureHeader)
+	if !hmac.Equal([]byte(signature), []byte(Sign(r.secret, body))) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var raw json.RawMessage
+	if err = json.NewDecoder(bytes.NewReader(body)).Decode(&raw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var events []Event
+	if len(raw) > 0 && raw[0] == '[' {
+		if err := json.Unmarshal(raw, &events); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		var event Event
+		if err := json.Unmarshal(raw, &event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		events = append(events, event)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.handle == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	for _, event := range events {
+		if err := r.handle(event); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// KeyOf returns a stable key for v, such as a struct of request parameters, so it can be used
+// directly as a cache key. It's the SHA-256 of the type of v and its JSON encoding, in which
+// map keys are sorted, so equal values give the same key across processes and restarts.
+// Only exported fields count; values JSON can't encode, such as funcs, are hashed as printed
+// by %#v instead, which isn't stable for pointers.
+func KeyOf(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%#v", v))
+	}
+
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%T\x00", v)
+	_, _ = h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// LeaderElector elects a single leader among the instances sharing a cache.
+// The leader holds key for ttl and renews it every third of ttl while it leads.
+type LeaderElector struct {
+	store   Cache
+	key     string
+	ttl     time.Duration
+	id      string
+	signals chan bool
+
+	mu     sync.Mutex
+	leader bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func NewLeaderElector(instance Cache, key string, ttl time.Duration) *LeaderElector {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	return &LeaderElector{
+		store:   instance,
+		key:     key,
+		ttl:     ttl,
+		id:      hex.EncodeToString(id),
+		signals: make(chan bool, 1),
+	}
+}
+
+// ID returns the identity this elector holds the key with.
+func (r *LeaderElector) ID() string {
+	return r.id
+}
+
+// Campaign blocks until this instance becomes the leader or ctx is done.
+// Once elected the lease is renewed in the background until Resign is called
+// or a renewal fails, which is reported on IsLeader.
+func (r *LeaderElector) Campaign(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval())
+	defer ticker.Stop()
+
+	for {
+		if r.store.Add(r.key, r.id, r.ttl) {
+			r.elected()
+			return nil
+		}
+		if r.Renew() == nil {
+			r.elected()
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Renew extends the lease, returning ErrNotLeader if the key isn't held by this instance.
+func (r *LeaderElector) Renew() error {
+	return r.store.Transaction(context.Background(), func(tx Cache) error {
+		if tx.Get(r.key) != r.id {
+			return ErrNotLeader
+		}
+
+		return tx.Put(r.key, r.id, WithTTL(r.ttl))
+	})
+}
+
+// Resign stops renewing the lease and releases the key, if this instance holds it.
+func (r *LeaderElector) Resign() error {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	err := r.store.Transaction(context.Background(), func(tx Cache) error {
+		if tx.Get(r.key) != r.id {
+			return ErrNotLeader
+		}
+
+		tx.Forget(r.key)
+		return nil
+	})
+	r.setLeader(false)
+
+	if errors.Is(err, ErrNotLeader) {
+		return nil
+	}
+	return err
+}
+
+// Leader reports whether this instance currently leads.
+func (r *LeaderElector) Leader() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.leader
+}
+
+// IsLeader returns a channel signaling whenever this instance gains or loses leadership.
+// Only the latest change is kept if the channel isn't drained.
+func (r *LeaderElector) IsLeader() <-chan bool {
+	return r.signals
+}
+
+func (r *LeaderElector) interval() time.Duration {
+	return max(r.ttl/3, time.Millisecond)
+}
+
+func (r *LeaderElector) elected() {
+	r.mu.Lock()
+	if r.stop == nil {
+		r.stop, r.done = make(chan struct{}), make(chan struct{})
+		go r.renew(r.stop, r.done)
+	}
+	r.mu.Unlock()
+
+	r.setLeader(true)
+}
+
+func (r *LeaderElector) renew(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if r.Renew() != nil {
+				r.mu.Lock()
+				if r.stop == stop {
+					r.stop, r.done = nil, nil
+				}
+				r.mu.Unlock()
+				r.setLeader(false)
+				return
+			}
+		}
+	}
+}
+
+func (r *LeaderElector) setLeader(leader bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.leader == leader {
+		return
+	}
+	r.leader = leader
+
+	select {
+	case <-r.signals:
+	default:
+	}
+	r.signals <- leader
+}
@@ -1,14 +1,23 @@
 package cache
 
 import (
+	"context"
+	"fmt"
 	"time"
 )
 
 type Lock struct {
-	store Cache
-	key   string
-	time  *time.Duration
-	get   bool
+	store    Cache
+	key      string
+	time     *time.Duration
+	get      bool
+	token    int64
+	unfenced bool
+	observer LockObserver
+
+	waiting   time.Time
+	contended bool
+	acquired  time.Time
 }
 
 func NewLock(instance Cache, key string, t ...time.Duration) *Lock {
@@ -27,6 +36,9 @@ func NewLock(instance Cache, key string, t ...time.Duration) *Lock {
 }
 
 func (r *Lock) Block(t time.Duration, callback ...func()) bool {
+	r.wait()
+	defer r.waited()
+
 	timer := time.NewTimer(t)
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
@@ -55,27 +67,157 @@ func (r *Lock) Block(t time.Duration, callback ...func()) bool {
 	return <-res
 }
 
+// BlockContext waits until the lock is acquired like Block, or until ctx is done,
+// in which case it returns an error wrapping both ErrLockTimeout and the error of ctx.
+// When callback is given it's run with the lock held, and the lock released afterward.
+func (r *Lock) BlockContext(ctx context.Context, callback ...func()) error {
+	r.wait()
+	defer r.waited()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %w", ErrLockTimeout, err)
+		}
+		if ok, err := r.acquire(r.store.WithContext(ctx)); err != nil {
+			return err
+		} else if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	if len(callback) > 0 {
+		callback[0]()
+		r.Release()
+	}
+
+	return nil
+}
+
 func (r *Lock) Get(callback ...func()) bool {
+	if ok, _ := r.acquire(r.store); !ok {
+		return false
+	}
+
+	if len(callback) == 0 {
+		return true
+	}
+
+	callback[0]()
+
+	return r.Release()
+}
+
+// GetContext acquires the lock like Get, unless ctx is done.
+func (r *Lock) GetContext(ctx context.Context, callback ...func()) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if ok, _ := r.acquire(r.store.WithContext(ctx)); !ok {
+		return false
+	}
+
+	if len(callback) == 0 {
+		return true
+	}
+
+	callback[0]()
+
+	return r.Release()
+}
+
+// acquire takes the lock and its fencing token. The lock is given back when the token can't be
+// taken, as holding it without one would let a holder write with the token of an earlier one.
+func (r *Lock) acquire(store Cache) (bool, error) {
 	var res bool
 	if r.time == nil {
-		res = r.store.Add(r.key, 1, NoExpiration)
+		res = store.Add(r.key, 1, NoExpiration)
 	} else {
-		res = r.store.Add(r.key, 1, *r.time)
+		res = store.Add(r.key, 1, *r.time)
 	}
 
 	if !res {
-		return false
+		if r.observer != nil && !r.contended {
+			r.contended = !r.waiting.IsZero()
+			r.observer.LockContended(r.key)
+		}
+		return false, nil
+	}
+
+	if r.unfenced {
+		r.get = true
+		r.observeAcquired()
+		return true, nil
 	}
 
+	// The counter is pinned so that flushing or evicting it doesn't restart the tokens at 1.
+	fence := r.key + ":fence"
+	if pinner, ok := store.(Pinner); ok {
+		pinner.Pin(fence)
+	}
+	token, err := store.Increment(fence)
+	if err != nil {
+		store.Forget(r.key)
+		return false, fmt.Errorf("cache: fencing token of %s: %w", r.key, err)
+	}
 	r.get = true
+	r.token = token
+	r.observeAcquired()
 
-	if len(callback) == 0 {
-		return true
+	return true, nil
+}
+
+// observeAcquired reports the acquisition to the observer.
+func (r *Lock) observeAcquired() {
+	if r.observer == nil {
+		return
 	}
 
-	callback[0]()
+	r.acquired = time.Now()
+	var wait time.Duration
+	if !r.waiting.IsZero() {
+		wait = r.acquired.Sub(r.waiting)
+	}
+	r.observer.LockAcquired(r.key, wait)
+}
 
-	return r.Release()
+// wait marks the start of a blocking acquisition, for the observer.
+func (r *Lock) wait() {
+	r.waiting = time.Now()
+	r.contended = false
+}
+
+func (r *Lock) waited() {
+	r.waiting = time.Time{}
+	r.contended = false
+}
+
+// GetToken acquires the lock like Get, returning its fencing token.
+func (r *Lock) GetToken() (int64, bool) {
+	if !r.Get() {
+		return 0, false
+	}
+
+	return r.token, true
+}
+
+// Token returns the fencing token of the last acquisition, 0 if the lock was never acquired.
+// Tokens increase with every acquisition of the key, so a downstream system can reject writes
+// carrying a token lower than one it already saw, from a holder whose lock expired meanwhile.
+// They're counted under the key with ":fence" appended, pinned on stores implementing Pinner,
+// and only increase while that counter survives: a store losing it, e.g. on ForceFlush or a
+// restart, starts over at 1. The lock isn't acquired when the counter can't be incremented.
+// As a counter is kept for good for every key ever locked, lock a bounded set of keys; the
+// per-item locks of GetOrLock and RememberDistributed have no token and keep no counter.
+func (r *Lock) Token() int64 {
+	return r.token
 }
 
 func (r *Lock) Release() bool {
@@ -87,5 +229,34 @@ func (r *Lock) Release() bool {
 }
 
 func (r *Lock) ForceRelease() bool {
-	return r.store.Forget(r.key)
+	res := r.store.Forget(r.key)
+	if res && r.observer != nil && !r.acquired.IsZero() {
+		r.observer.LockReleased(r.key, time.Since(r.acquired))
+		r.acquired = time.Time{}
+	}
+
+	return res
+}
+
+// GetOrLock retrieve an item from the cache, or on a miss acquire a lock for computing it.
+// The lock is nil if another caller already holds it; that caller is computing the item,
+// so retry the read later. Whoever gets the lock must store the item and Release it.
+// The lock has no fencing token, so locking every item doesn't leave a counter behind for each.
+func GetOrLock(instance Cache, key string, lockTTL time.Duration) (any, bool, *Lock) {
+	if val, exist := instance.GetExists(key); exist {
+		return val, true, nil
+	}
+
+	lock := instance.Lock(key+":lock", lockTTL)
+	lock.unfenced = true
+	if !lock.Get() {
+		return nil, false, nil
+	}
+	// The item may have been stored between the read and acquiring the lock.
+	if val, exist := instance.GetExists(key); exist {
+		lock.Release()
+		return val, true, nil
+	}
+
+	return nil, false, lock
 }
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// LockObserver is told about the acquisitions and releases of locks, see ObserveLocks.
+type LockObserver interface {
+	// LockAcquired is called when the lock of key is acquired, after waiting wait for it.
+	// The wait is 0 for locks acquired without blocking.
+	LockAcquired(key string, wait time.Duration)
+	// LockContended is called when the lock of key is held by someone else.
+	// A blocking acquisition reports it once, however long it waits.
+	LockContended(key string)
+	// LockReleased is called when the lock of key is released, after holding it for held.
+	LockReleased(key string, held time.Duration)
+}
+
+// lockDurationBuckets are the upper bounds of the wait and hold histograms.
+var lockDurationBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+}
+
+// DurationBucket counts the durations up to UpTo, and above the previous bucket.
+// The last bucket has an UpTo of math.MaxInt64 and counts the rest.
+type DurationBucket struct {
+	UpTo  time.Duration
+	Count int64
+}
+
+// LockStats is what LockMetrics collected for a key.
+type LockStats struct {
+	// Acquisitions is how many times the lock was acquired.
+	Acquisitions int64
+	// Contentions is how many times the lock was found held by someone else.
+	Contentions int64
+	// Waits is the distribution of the time waited for acquiring the lock.
+	Waits []DurationBucket
+	// Holds is the distribution of the time the lock was held.
+	Holds []DurationBucket
+}
+
+type lockKeyStats struct {
+	acquisitions, contentions int64
+	waits, holds              [7]int64
+}
+
+// LockMetrics is a LockObserver collecting LockStats per key.
+type LockMetrics struct {
+	mu   sync.Mutex
+	keys map[string]*lockKeyStats
+}
+
+func NewLockMetrics() *LockMetrics {
+	return &LockMetrics{keys: make(map[string]*lockKeyStats)}
+}
+
+func (r *LockMetrics) stats(key string) *lockKeyStats {
+	stats, ok := r.keys[key]
+	if !ok {
+		stats = &lockKeyStats{}
+		r.keys[key] = stats
+	}
+
+	return stats
+}
+
+func (r *LockMetrics) LockAcquired(key string, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.stats(key)
+	stats.acquisitions++
+	stats.waits[lockDurationBucket(wait)]++
+}
+
+func (r *LockMetrics) LockContended(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats(key).contentions++
+}
+
+func (r *LockMetrics) LockReleased(key string, held time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats(key).holds[lockDurationBucket(held)]++
+}
+
+// Stats returns what was collected for every key seen so far.
+func (r *LockMetrics) Stats() map[string]LockStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make(map[string]LockStats, len(r.keys))
+	for key, stats := range r.keys {
+		res[key] = LockStats{
+			Acquisitions: stats.acquisitions,
+			Contentions:  stats.contentions,
+			Waits:        lockDurationHistogram(stats.waits),
+			Holds:        lockDurationHistogram(stats.holds),
+		}
+	}
+
+	return res
+}
+
+func lockDurationBucket(d time.Duration) int {
+	for i, upTo := range lockDurationBuckets {
+		if d <= upTo {
+			return i
+		}
+	}
+
+	return len(lockDurationBuckets)
+}
+
+func lockDurationHistogram(counts [7]int64) []DurationBucket {
+	res := make([]DurationBucket, 0, len(counts))
+	for i, count := range counts {
+		upTo := time.Duration(math.MaxInt64)
+		if i < len(lockDurationBuckets) {
+			upTo = lockDurationBuckets[i]
+		}
+		res = append(res, DurationBucket{UpTo: upTo, Count: count})
+	}
+
+	return res
+}
+
+type lockObserved struct {
+	Cache
+	observer LockObserver
+}
+
+// ObserveLocks returns a cache whose locks report to observer, e.g. a LockMetrics.
+func ObserveLocks(instance Cache, observer LockObserver) Cache {
+	return &lockObserved{Cache: instance, observer: observer}
+}
+
+func (r *lockObserved) Lock(key string, t ...time.Duration) *Lock {
+	lock := r.Cache.Lock(key, t...)
+	lock.observer = r.observer
+
+	return lock
+}
+
+// Pin pins key on the underlying store if it implements Pinner, for the fencing counters of locks.
+func (r *lockObserved) Pin(key string) {
+	if pinner, ok := r.Cache.(Pinner); ok {
+		pinner.Pin(key)
+	}
+}
+
+// Unpin unpins key on the underlying store if it implements Pinner.
+func (r *lockObserved) Unpin(key string) {
+	if pinner, ok := r.Cache.(Pinner); ok {
+		pinner.Unpin(key)
+	}
+}
+
+func (r *lockObserved) Pipeline() *Pipeline {
+	return NewPipeline(r)
+}
+
+func (r *lockObserved) Transaction(ctx context.Context, fn func(tx Cache) error) error {
+	return r.Cache.Transaction(ctx, func(tx Cache) error {
+		return fn(&lockObserved{Cache: tx, observer: r.observer})
+	})
+}
+
+func (r *lockObserved) WithContext(ctx context.Context) Cache {
+	return &lockObserved{Cache: r.Cache.WithContext(ctx), observer: r.observer}
+}
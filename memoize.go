@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Memoize wraps fn so its results are cached for ttl, keyed by name and its argument.
+// Concurrent calls with the same argument share a single call to fn. The name must be unique
+// to fn, yet the same across the instances of a binary sharing its results, e.g. "users.Find";
+// functions memoized under the same name return each other's results.
+func Memoize[K comparable, V any](instance Cache, name string, ttl time.Duration, fn func(K) (V, error)) func(K) (V, error) {
+	prefix := "memoize:" + name
+	var group flightGroup
+
+	return func(k K) (V, error) {
+		return memoize(instance, &group, fmt.Sprintf("%s:%v", prefix, k), ttl, func() (V, error) {
+			return fn(k)
+		})
+	}
+}
+
+// Memoize2 is Memoize for functions of two arguments.
+func Memoize2[K1, K2 comparable, V any](instance Cache, name string, ttl time.Duration, fn func(K1, K2) (V, error)) func(K1, K2) (V, error) {
+	prefix := "memoize:" + name
+	var group flightGroup
+
+	return func(k1 K1, k2 K2) (V, error) {
+		return memoize(instance, &group, fmt.Sprintf("%s:%v:%v", prefix, k1, k2), ttl, func() (V, error) {
+			return fn(k1, k2)
+		})
+	}
+}
+
+func memoize[V any](instance Cache, group *flightGroup, key string, ttl time.Duration, fn func() (V, error)) (V, error) {
+	if val, ok := instance.Get(key).(V); ok {
+		return val, nil
+	}
+
+	return typed[V](key)(group.do(key, func() (any, error) {
+		return RememberT(instance, key, ttl, fn)
+	}))
+}
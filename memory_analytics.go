@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+var (
+	uuidPattern   = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	hashPattern   = regexp.MustCompile(`(?i)\b[0-9a-f]{16,}\b`)
+	digitsPattern = regexp.MustCompile(`[0-9]+`)
+)
+
+// PatternCount is the number of items held whose keys share a pattern.
+type PatternCount struct {
+	Pattern string
+	Keys    int64
+}
+
+// WithKeyAnalytics makes Stats group the keys held by pattern, see KeyPattern, and report how many
+// there are of each, to spot keys growing without bounds, such as one per request or per user.
+// Every Stats then walks the keys.
+func WithKeyAnalytics() MemoryOption {
+	return func(r *Memory) {
+		r.keyAnalytics = true
+	}
+}
+
+// cardinalityAlarm is the alarm set with WithCardinalityAlarm, with the patterns it was raised for.
+type cardinalityAlarm struct {
+	threshold int64
+	fn        func(PatternCount)
+	mu        sync.Mutex
+	raised    map[string]bool
+}
+
+// WithCardinalityAlarm makes Stats call alarm with the key patterns holding more than threshold
+// items, once until they hold threshold or fewer again, e.g. to page when a key made per request
+// fills the cache. Patterns are only counted by Stats, so call it periodically, such as when
+// scraping metrics. It implies WithKeyAnalytics.
+func WithCardinalityAlarm(threshold int64, alarm func(PatternCount)) MemoryOption {
+	return func(r *Memory) {
+		r.keyAnalytics = true
+		r.alarm = &cardinalityAlarm{threshold: threshold, fn: alarm, raised: make(map[string]bool)}
+	}
+}
+
+// check calls the alarm with the patterns over the threshold it wasn't raised for yet.
+func (r *cardinalityAlarm) check(patterns []PatternCount) {
+	r.mu.Lock()
+	var raise []PatternCount
+	over := make(map[string]bool)
+	for _, p := range patterns {
+		if p.Keys > r.threshold {
+			over[p.Pattern] = true
+			if !r.raised[p.Pattern] {
+				raise = append(raise, p)
+			}
+		}
+	}
+	r.raised = over
+	r.mu.Unlock()
+
+	for _, p := range raise {
+		r.fn(p)
+	}
+}
+
+// KeyPattern returns the pattern of key, with its UUIDs, hex hashes and numbers replaced by *,
+// so "user:42:session:9f86d081884c7d65" becomes "user:*:session:*".
+func KeyPattern(key string) string {
+	key = uuidPattern.ReplaceAllString(key, "*")
+	key = hashPattern.ReplaceAllString(key, "*")
+
+	return digitsPattern.ReplaceAllString(key, "*")
+}
+
+// patterns counts the keys held by pattern, most first.
+func (r *Memory) patterns() []PatternCount {
+	now := r.now()
+	counts := make(map[string]int64)
+	r.items().m.Range(func(key, val any) bool {
+		if !val.(*memoryEntry).expired(now) {
+			counts[KeyPattern(key.(string))]++
+		}
+		return true
+	})
+
+	res := make([]PatternCount, 0, len(counts))
+	for pattern, keys := range counts {
+		res = append(res, PatternCount{Pattern: pattern, Keys: keys})
+	}
+	slices.SortFunc(res, func(a, b PatternCount) int {
+		return cmp.Or(cmp.Compare(b.Keys, a.Keys), cmp.Compare(a.Pattern, b.Pattern))
+	})
+
+	return res
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"fmt"
+)
+
+// Append adds data at the end of the string or []byte item of key, keeping its expiration,
+// and returns the new length. A missing item is created as a string that never expires.
+// Byte items grow their slice, so appending repeatedly doesn't copy them every time.
+func (r *Memory) Append(key, data string) (int, error) {
+	return r.concat(key, data, false)
+}
+
+// Prepend adds data at the start of the string or []byte item of key, like Append.
+func (r *Memory) Prepend(key, data string) (int, error) {
+	return r.concat(key, data, true)
+}
+
+func (r *Memory) concat(key, data string, prepend bool) (int, error) {
+	var n int
+	err := r.modify(key, func(value any, exists bool) (any, error) {
+		if !exists {
+			n = len(data)
+			return data, nil
+		}
+
+		res, err := concatValue(key, value, data, prepend)
+		if err != nil {
+			return nil, err
+		}
+		switch v := res.(type) {
+		case []byte:
+			n = len(v)
+		case string:
+			n = len(v)
+		}
+		return res, nil
+	})
+
+	return n, err
+}
+
+func concatValue(key string, value any, data string, prepend bool) (any, error) {
+	switch v := value.(type) {
+	case string:
+		if prepend {
+			return data + v, nil
+		}
+		return v + data, nil
+	case []byte:
+		if prepend {
+			res := make([]byte, 0, len(data)+len(v))
+			return append(append(res, data...), v...), nil
+		}
+		return append(v, data...), nil
+	default:
+		return nil, fmt.Errorf("cache: value of key %s is %T, not a string", key, value)
+	}
+}
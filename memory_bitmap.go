@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+var errNegativeOffset = errors.New("cache: bit offset is negative")
+
+// SetBit sets or clears the bit at offset of the []byte item of key, keeping its expiration,
+// and returns its previous value. A missing item is created. The item is copied on every change,
+// so slices handed out before aren't modified.
+func (r *Memory) SetBit(key string, offset int64, value bool) (bool, error) {
+	if offset < 0 {
+		return false, errNegativeOffset
+	}
+
+	var prev bool
+	err := r.modify(key, func(current any, exists bool) (any, error) {
+		var b []byte
+		if exists {
+			var err error
+			if b, err = bitmapValue(key, current); err != nil {
+				return nil, err
+			}
+		}
+
+		res := make([]byte, max(len(b), int(offset/8)+1))
+		copy(res, b)
+		mask := byte(0x80) >> (offset % 8)
+		prev = res[offset/8]&mask != 0
+		if value {
+			res[offset/8] |= mask
+		} else {
+			res[offset/8] &^= mask
+		}
+		return res, nil
+	})
+
+	return prev, err
+}
+
+// GetBit returns the bit at offset of the []byte item of key, false past its end or when it's missing.
+func (r *Memory) GetBit(key string, offset int64) (bool, error) {
+	if offset < 0 {
+		return false, errNegativeOffset
+	}
+
+	e, exist := r.load(key)
+	if !exist {
+		return false, nil
+	}
+	b, err := bitmapValue(key, e.value)
+	if err != nil || offset/8 >= int64(len(b)) {
+		return false, err
+	}
+
+	return b[offset/8]&(byte(0x80)>>(offset%8)) != 0, nil
+}
+
+// BitCount returns how many bits of the []byte item of key are set, 0 when it's missing.
+func (r *Memory) BitCount(key string) (int64, error) {
+	e, exist := r.load(key)
+	if !exist {
+		return 0, nil
+	}
+	b, err := bitmapValue(key, e.value)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for _, c := range b {
+		n += int64(bits.OnesCount8(c))
+	}
+
+	return n, nil
+}
+
+func bitmapValue(key string, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("cache: value of key %s is %T, not a bitmap", key, value)
+	}
+}
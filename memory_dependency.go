@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// dependencyGraph links keys stored with PutWithDependencies to the keys they depend on.
+type dependencyGraph struct {
+	mu           sync.Mutex
+	dependencies map[string][]string
+	dependents   map[string]map[string]struct{}
+}
+
+func (r *dependencyGraph) add(key string, deps []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.dependencies == nil {
+		r.dependencies = make(map[string][]string)
+		r.dependents = make(map[string]map[string]struct{})
+	}
+	r.dependencies[key] = append(r.dependencies[key], deps...)
+	for _, dep := range deps {
+		if r.dependents[dep] == nil {
+			r.dependents[dep] = make(map[string]struct{})
+		}
+		r.dependents[dep][key] = struct{}{}
+	}
+}
+
+// detach drops the links of key, returning the keys that depended on it.
+func (r *dependencyGraph) detach(key string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, dep := range r.dependencies[key] {
+		delete(r.dependents[dep], key)
+		if len(r.dependents[dep]) == 0 {
+			delete(r.dependents, dep)
+		}
+	}
+	delete(r.dependencies, key)
+
+	var res []string
+	for dependent := range r.dependents[key] {
+		res = append(res, dependent)
+	}
+	delete(r.dependents, key)
+
+	return res
+}
+
+func (r *dependencyGraph) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dependencies = nil
+	r.dependents = nil
+}
+
+// PutWithDependencies stores an item that is forgotten whenever one of deps is written or forgotten,
+// and in turn everything depending on it. Dependencies that merely expire don't cascade.
+func (r *Memory) PutWithDependencies(key string, value any, ttl time.Duration, deps ...string) error {
+	if err := r.Put(key, value, WithTTL(ttl)); err != nil {
+		return err
+	}
+
+	r.dependencies.add(key, deps)
+	return nil
+}
+
+// cascade forgets the items depending on key, which was just written or forgotten.
+func (r *Memory) cascade(key string) {
+	for _, dependent := range r.dependencies.detach(key) {
+		r.Forget(dependent)
+	}
+}
+
+// PutWithValidator stores an item that is only read while validate reports its value as valid,
+// e.g. a token that hasn't expired yet. The first read finding it invalid forgets it and misses.
+// The validator runs on every read, so it must be cheap, and must not use the driver.
+func (r *Memory) PutWithValidator(key string, value any, ttl time.Duration, validate func(value any) bool) error {
+	return r.Put(key, value, WithTTL(ttl), func(o *putOptions) {
+		o.validate = validate
+	})
+}
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"reflect"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// EntryInfo describes an item held by the Memory driver.
+type EntryInfo struct {
+	// Created is when the item was stored.
+	Created time.Time
+	// Expires is when the item expires, zero if it never does.
+	Expires time.Time
+	// StaleAt is when the item's soft TTL elapses, zero if it has none.
+	StaleAt time.Time
+	// Stale reports whether the soft TTL has elapsed.
+	Stale bool
+	// TTL is the time left before the item expires, NoExpiration if it never does.
+	TTL time.Duration
+	// Hits is how many times the item was read.
+	Hits int64
+	// LastAccess is when the item was last read, or stored if it was never read.
+	LastAccess time.Time
+	// Size is an estimate of the item's value size in bytes.
+	Size int64
+	// Cost is what the item counts against the driver's max cost.
+	Cost int64
+	// Priority is how readily the item is evicted.
+	Priority Priority
+}
+
+type memoryEntry struct {
+	value      any
+	created    time.Time
+	expires    time.Time
+	stale      time.Time
+	softTTL    time.Duration
+	size       int64
+	cost       int64
+	priority   Priority
+	validate   func(value any) bool
+	namespace  *memoryNamespace
+	timer      atomic.Pointer[time.Timer]
+	hits       atomic.Int64
+	accessed   atomic.Int64
+	refreshing atomic.Bool
+}
+
+func newMemoryEntry(value any, o putOptions, now time.Time) *memoryEntry {
+	e := &memoryEntry{
+		value:    value,
+		created:  now,
+		size:     sizeOf(value),
+		cost:     o.cost,
+		priority: o.priority,
+		validate: o.validate,
+	}
+	if o.ttl != NoExpiration {
+		e.expires = now.Add(o.ttl)
+	}
+	if o.softTTL > 0 && (o.ttl == NoExpiration || o.softTTL < o.ttl) {
+		e.stale = now.Add(o.softTTL)
+		e.softTTL = o.softTTL
+	}
+	e.accessed.Store(now.UnixNano())
+
+	return e
+}
+
+func (r *memoryEntry) expired(now time.Time) bool {
+	return !r.expires.IsZero() && !now.Before(r.expires)
+}
+
+// stop cancels the expiration timer of the entry, if any.
+func (r *memoryEntry) stop() {
+	if timer := r.timer.Load(); timer != nil {
+		timer.Stop()
+	}
+}
+
+// invalid reports whether the validator of the entry rejects its value.
+func (r *memoryEntry) invalid() bool {
+	return r.validate != nil && !r.validate(r.value)
+}
+
+func (r *memoryEntry) isStale(now time.Time) bool {
+	return !r.stale.IsZero() && !now.Before(r.stale)
+}
+
+func (r *memoryEntry) touch(now time.Time) {
+	r.hits.Add(1)
+	r.accessed.Store(now.UnixNano())
+}
+
+func (r *memoryEntry) info(now time.Time) EntryInfo {
+	info := EntryInfo{
+		Created:    r.created,
+		Expires:    r.expires,
+		StaleAt:    r.stale,
+		Stale:      r.isStale(now),
+		TTL:        NoExpiration,
+		Hits:       r.hits.Load(),
+		LastAccess: time.Unix(0, r.accessed.Load()),
+		Size:       r.size,
+		Cost:       r.cost,
+		Priority:   r.priority,
+	}
+	if !r.expires.IsZero() {
+		info.TTL = max(r.expires.Sub(now), time.Nanosecond)
+	}
+
+	return info
+}
+
+// sizeOf estimates how many bytes a value occupies, following pointers, slices and maps.
+func sizeOf(value any) int64 {
+	if value == nil {
+		return 0
+	}
+
+	return sizeOfValue(reflect.ValueOf(value), make(map[uintptr]struct{}))
+}
+
+func sizeOfValue(v reflect.Value, seen map[uintptr]struct{}) int64 {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return int64(unsafe.Sizeof(uintptr(0)))
+		}
+		if _, ok := seen[v.Pointer()]; ok {
+			return int64(unsafe.Sizeof(uintptr(0)))
+		}
+		seen[v.Pointer()] = struct{}{}
+		return int64(unsafe.Sizeof(uintptr(0))) + sizeOfValue(v.Elem(), seen)
+	case reflect.Interface:
+		if v.IsNil() {
+			return int64(v.Type().Size())
+		}
+		return int64(v.Type().Size()) + sizeOfValue(v.Elem(), seen)
+	case reflect.String:
+		return int64(v.Type().Size()) + int64(v.Len())
+	case reflect.Slice:
+		size := int64(v.Type().Size())
+		if v.IsNil() {
+			return size
+		}
+		if _, ok := seen[v.Pointer()]; ok {
+			return size
+		}
+		seen[v.Pointer()] = struct{}{}
+		return size + sizeOfElements(v, seen)
+	case reflect.Array:
+		return sizeOfElements(v, seen)
+	case reflect.Map:
+		size := int64(v.Type().Size())
+		if v.IsNil() {
+			return size
+		}
+		if _, ok := seen[v.Pointer()]; ok {
+			return size
+		}
+		seen[v.Pointer()] = struct{}{}
+		iter := v.MapRange()
+		for iter.Next() {
+			size += sizeOfValue(iter.Key(), seen) + sizeOfValue(iter.Value(), seen)
+		}
+		return size
+	case reflect.Struct:
+		size := int64(v.Type().Size())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			// The field itself is already counted in the struct size, only add what it references.
+			size += sizeOfValue(field, seen) - int64(field.Type().Size())
+		}
+		return size
+	default:
+		return int64(v.Type().Size())
+	}
+}
+
+func sizeOfElements(v reflect.Value, seen map[uintptr]struct{}) int64 {
+	elem := v.Type().Elem()
+	switch elem.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return int64(v.Len()) * int64(elem.Size())
+	}
+
+	var size int64
+	for i := 0; i < v.Len(); i++ {
+		size += sizeOfValue(v.Index(i), seen)
+	}
+	return size
+}
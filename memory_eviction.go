@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// EvictionPolicy decides which items the Memory driver evicts first when it's over budget.
+type EvictionPolicy int
+
+const (
+	// EvictLRU evicts the least recently read items.
+	EvictLRU EvictionPolicy = iota
+	// EvictLFU evicts the least frequently read items.
+	EvictLFU
+)
+
+// Priority ranks items for eviction.
+type Priority int
+
+const (
+	// PriorityLow items are evicted before any other.
+	PriorityLow Priority = -1
+	// PriorityNormal is the default priority.
+	PriorityNormal Priority = 0
+	// PriorityPinned items are never evicted.
+	PriorityPinned Priority = 1
+)
+
+// evictionSamples is how many items are compared to pick each victim,
+// approximating the policy without scanning the whole cache.
+const evictionSamples = 5
+
+// evictionWindow bounds how far into the items the sample for a victim may start,
+// so picking one stays cheap in large caches.
+const evictionWindow = 1024
+
+// newEntry creates the entry for storing value under key, rejecting it if it can never fit.
+func (r *memoryState) newEntry(key string, value any, o putOptions) (*memoryEntry, error) {
+	if r.copyValues {
+		var err error
+		if value, err = copyValue(value); err != nil {
+			return nil, err
+		}
+	}
+
+	e := newMemoryEntry(value, o, r.now())
+	if r.maxCost > 0 && e.cost > r.maxCost {
+		return nil, ErrCostExceeded
+	}
+
+	e.namespace = r.namespaceOf(key)
+	if e.namespace != nil && e.namespace.maxBytes > 0 && e.size > e.namespace.maxBytes {
+		return nil, ErrQuotaExceeded
+	}
+
+	return e, nil
+}
+
+// evict removes items other than keep, among those accepted by match if set, while over reports true.
+// PriorityLow items go first; the other victims are picked from a sample taken at a random point.
+func (r *memoryState) evict(items *memoryItems, keep string, over func() bool, match func(e *memoryEntry) bool) {
+	evictable := func(key string, e *memoryEntry) bool {
+		return key != keep && e.priority != PriorityPinned && !r.pins.pinned(key) && (match == nil || match(e))
+	}
+
+	for over() {
+		victimKey, victim := r.lowVictim(items, evictable)
+		if victim == nil {
+			victimKey, victim = r.sampleVictim(items, evictable)
+		}
+		if victim == nil {
+			return
+		}
+		if r.discard(items, victimKey, victim, EventDelete) {
+			r.stats.evictions.Add(1)
+		}
+	}
+}
+
+// lowVictim looks for an evictable PriorityLow item, only scanning when there are some.
+func (r *memoryState) lowVictim(items *memoryItems, evictable func(string, *memoryEntry) bool) (string, *memoryEntry) {
+	var (
+		victimKey string
+		victim    *memoryEntry
+	)
+	if items.low.Load() <= 0 {
+		return victimKey, victim
+	}
+
+	items.m.Range(func(k, v any) bool {
+		key, e := k.(string), v.(*memoryEntry)
+		if e.priority != PriorityLow || !evictable(key, e) {
+			return true
+		}
+		victimKey, victim = key, e
+		return false
+	})
+	return victimKey, victim
+}
+
+// sampleVictim compares evictionSamples evictable items, starting at a random point within
+// the first evictionWindow ones and wrapping around, and returns the one to evict first.
+func (r *memoryState) sampleVictim(items *memoryItems, evictable func(string, *memoryEntry) bool) (string, *memoryEntry) {
+	var (
+		victimKey string
+		victim    *memoryEntry
+		sampled   int
+	)
+	start := 0
+	if n := min(items.len.Load(), evictionWindow); n > 1 {
+		start = rand.IntN(int(n))
+	}
+
+	sample := func(from, to int) {
+		i := 0
+		items.m.Range(func(k, v any) bool {
+			if i++; i <= from {
+				return true
+			}
+			if i > to {
+				return false
+			}
+			key, e := k.(string), v.(*memoryEntry)
+			if !evictable(key, e) {
+				return true
+			}
+			if victim == nil || r.evictsBefore(e, victim) {
+				victimKey, victim = key, e
+			}
+			sampled++
+			return sampled < evictionSamples
+		})
+	}
+	sample(start, math.MaxInt)
+	if sampled < evictionSamples && start > 0 {
+		sample(0, start)
+	}
+	return victimKey, victim
+}
+
+// evictsBefore reports whether a should be evicted before b.
+func (r *memoryState) evictsBefore(a, b *memoryEntry) bool {
+	if a.priority != b.priority {
+		return a.priority < b.priority
+	}
+
+	switch r.policy {
+	case EvictLFU:
+		if a.hits.Load() != b.hits.Load() {
+			return a.hits.Load() < b.hits.Load()
+		}
+	}
+
+	return a.accessed.Load() < b.accessed.Load()
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// maxImportRecord bounds the size of a record read by Import, so a corrupt or hostile
+// length prefix can't make it allocate without limit.
+const maxImportRecord = 64 << 20
+
+// exportRecord is an item as written by Export, TTL being what was left of it, zero for none.
+type exportRecord struct {
+	Key   string
+	Value any
+	TTL   time.Duration
+}
+
+// Export writes up to limit items of the driver to w, the most read first, all of them for a limit
+// of zero. Every item is encoded with codec on its own, prefixed by its length; items codec can't
+// encode are skipped. With GobCodec, values of custom types must be registered with gob.Register.
+// It returns how many items were written.
+func (r *Memory) Export(w io.Writer, codec Codec, limit int) (int, error) {
+	return r.export(w, codec, limit, nil)
+}
+
+// export is Export of the items whose key match reports, all of them for a nil match.
+func (r *Memory) export(w io.Writer, codec Codec, limit int, match func(key string) bool) (int, error) {
+	type exported struct {
+		key string
+		e   *memoryEntry
+	}
+
+	now := r.now()
+	var entries []exported
+	r.items().m.Range(func(key, val any) bool {
+		if e := val.(*memoryEntry); !e.expired(now) && (match == nil || match(key.(string))) {
+			entries = append(entries, exported{key: key.(string), e: e})
+		}
+		return true
+	})
+	slices.SortFunc(entries, func(a, b exported) int {
+		return cmp.Compare(b.e.hits.Load(), a.e.hits.Load())
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	var n int
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, entry := range entries {
+		record := exportRecord{Key: entry.key, Value: r.output(entry.e.value)}
+		if !entry.e.expires.IsZero() {
+			record.TTL = entry.e.expires.Sub(now)
+		}
+		data, err := codec.Marshal(record)
+		if err != nil {
+			continue
+		}
+
+		if _, err = w.Write(buf[:binary.PutUvarint(buf, uint64(len(data)))]); err != nil {
+			return n, err
+		}
+		if _, err = w.Write(data); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// Import adds the items written by Export, decoding them with codec, for what's left of their TTL.
+// Items the driver already holds are kept, and records larger than 64 MiB are refused with
+// ErrRecordTooLarge. It returns how many items were added.
+func (r *Memory) Import(rd io.Reader, codec Codec) (int, error) {
+	br := bufio.NewReader(rd)
+
+	var n int
+	for {
+		size, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		if size > maxImportRecord {
+			return n, fmt.Errorf("%w: %d bytes", ErrRecordTooLarge, size)
+		}
+		data := make([]byte, size)
+		if _, err = io.ReadFull(br, data); err != nil {
+			return n, err
+		}
+		var record exportRecord
+		if err = codec.Unmarshal(data, &record); err != nil {
+			return n, err
+		}
+
+		ttl := record.TTL
+		if ttl == 0 {
+			ttl = NoExpiration
+		}
+		if r.Add(record.Key, record.Value, ttl) {
+			n++
+		}
+	}
+}
+
+// ExportHandler serves the export of up to limit items of memory whose key match reports, all
+// of them for a nil match, for peers to WarmUp from.
+//
+// The export holds the keys and values of the items as they are, secrets and personal data
+// included: requests are only served when authorize, typically checking a token shared with
+// the peers, reports them as allowed, and answered with 403 Forbidden otherwise. A nil authorize
+// refuses every request. Serve it on an internal listener, never next to public routes.
+func ExportHandler(memory *Memory, codec Codec, limit int, authorize func(req *http.Request) bool, match func(key string) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if authorize == nil || !authorize(req) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = memory.export(w, codec, limit, match)
+	})
+}
+
+// WarmUpOption configures WarmUp.
+type WarmUpOption func(*warmUp)
+
+type warmUp struct {
+	client  *http.Client
+	prepare func(req *http.Request)
+}
+
+// WithWarmUpClient makes WarmUp send its request with client instead of http.DefaultClient.
+func WithWarmUpClient(client *http.Client) WarmUpOption {
+	return func(r *warmUp) {
+		r.client = client
+	}
+}
+
+// WithWarmUpRequest makes WarmUp call prepare with its request before sending it, e.g. to set
+// the header carrying the token the ExportHandler of the peer checks.
+func WithWarmUpRequest(prepare func(req *http.Request)) WarmUpOption {
+	return func(r *warmUp) {
+		r.prepare = prepare
+	}
+}
+
+// WarmUp imports into memory the items exported by the ExportHandler of a peer at url,
+// e.g. right after a deploy instead of starting cold. It returns how many items were added.
+// Pass credentials in a header with WithWarmUpRequest rather than in url, which ends up in logs.
+func WarmUp(ctx context.Context, memory *Memory, url string, codec Codec, opts ...WarmUpOption) (int, error) {
+	o := warmUp{client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if o.prepare != nil {
+		o.prepare(req)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("cache: warm-up from %s: %s", url, resp.Status)
+	}
+
+	return memory.Import(resp.Body, codec)
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"sync/atomic"
+)
+
+// rememberFlight tracks writes to a key while Remember computes its value.
+type rememberFlight struct {
+	gen  atomic.Uint64
+	refs int
+}
+
+// beginFlight registers a Remember in progress for key.
+func (r *memoryState) beginFlight(key string) *rememberFlight {
+	r.flightsMu.Lock()
+	defer r.flightsMu.Unlock()
+
+	if r.flights == nil {
+		r.flights = make(map[string]*rememberFlight)
+	}
+	f, ok := r.flights[key]
+	if !ok {
+		f = &rememberFlight{}
+		r.flights[key] = f
+	}
+	f.refs++
+	r.inflight.Add(1)
+
+	return f
+}
+
+func (r *memoryState) endFlight(key string, f *rememberFlight) {
+	r.flightsMu.Lock()
+	defer r.flightsMu.Unlock()
+
+	f.refs--
+	if f.refs == 0 {
+		delete(r.flights, key)
+	}
+	r.inflight.Add(-1)
+}
+
+// invalidate tells the Remembers in progress for key that it was written meanwhile.
+// It must be called before the write lands.
+func (r *memoryState) invalidate(key string) {
+	if r.inflight.Load() == 0 {
+		return
+	}
+
+	r.flightsMu.Lock()
+	if f, ok := r.flights[key]; ok {
+		f.gen.Add(1)
+	}
+	r.flightsMu.Unlock()
+}
+
+func (r *memoryState) invalidateAll() {
+	if r.inflight.Load() == 0 {
+		return
+	}
+
+	r.flightsMu.Lock()
+	for _, f := range r.flights {
+		f.gen.Add(1)
+	}
+	r.flightsMu.Unlock()
+}
+
+// remember runs callback and stores its result, unless key is written while it runs.
+// The result is returned either way, and a panic of callback is returned as a *PanicError.
+func (r *Memory) remember(key string, callback func() (any, error), opts ...PutOption) (any, error) {
+	if r.readOnly.Load() {
+		r.stats.misses.Add(1)
+		return safeCall(callback)
+	}
+
+	if !r.racyRemember {
+		r.remembering.Lock(key)
+		defer r.remembering.Unlock(key)
+
+		// Another caller may have stored the item while we waited.
+		if e, exist := r.load(key); exist {
+			r.hit(key, e)
+			return r.output(e.value), nil
+		}
+	}
+	r.stats.misses.Add(1)
+
+	f := r.beginFlight(key)
+	defer r.endFlight(key, f)
+	gen := f.gen.Load()
+
+	val, err := safeCall(callback)
+	if err != nil {
+		return nil, err
+	}
+
+	// Writes of key take its lock after invalidating, so checking under the lock either sees
+	// them and keeps their item, or stores first and leaves them to overwrite it.
+	r.locks.Lock(key)
+	defer r.locks.Unlock(key)
+	if f.gen.Load() != gen {
+		return val, nil
+	}
+	items, e, err := r.put(key, val, r.putOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+	// Checking again catches flushes, which don't take the lock, in which case only our own
+	// entry is taken back out.
+	if f.gen.Load() != gen {
+		r.discard(items, key, e, EventDelete)
+	}
+
+	return val, nil
+}
+
+// Do runs fn once per key at a time in this process, handing its result to every caller
+// asking for the key meanwhile, as golang.org/x/sync/singleflight does. Nothing is stored,
+// the next call after fn returns runs it again. Keys are apart from those of the items.
+func (r *Memory) Do(key string, fn func() (any, error)) (any, error) {
+	return r.group.do(key, fn)
+}
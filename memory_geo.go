@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"cmp"
+	"slices"
+)
+
+// GeoAdd adds locations to the *SortedSet item of key, creating one that never expires if missing,
+// and returns how many locations were added.
+func (r *Memory) GeoAdd(key string, locations ...GeoLocation) (int, error) {
+	if r.readOnly.Load() {
+		return 0, ErrReadOnly
+	}
+
+	members := make([]ZMember, 0, len(locations))
+	for _, location := range locations {
+		if !geoValid(location.Longitude, location.Latitude) {
+			return 0, errInvalidCoordinates
+		}
+		members = append(members, ZMember{Member: location.Name, Score: geoEncode(location.Longitude, location.Latitude)})
+	}
+
+	set, exist, err := loadStructure(r, key, NewSortedSet)
+	if err != nil || !exist {
+		return 0, err
+	}
+
+	return set.Add(members...), nil
+}
+
+// GeoPos returns the location name in the *SortedSet item of key, reporting whether it's there.
+func (r *Memory) GeoPos(key, name string) (GeoLocation, bool, error) {
+	hash, ok, err := r.ZScore(key, name)
+	if err != nil || !ok {
+		return GeoLocation{}, false, err
+	}
+
+	longitude, latitude := geoDecode(hash)
+	return GeoLocation{Name: name, Longitude: longitude, Latitude: latitude}, true, nil
+}
+
+// GeoDist returns the distance in meters between two locations of the *SortedSet item of key,
+// reporting whether both are there.
+func (r *Memory) GeoDist(key, a, b string) (float64, bool, error) {
+	from, ok, err := r.GeoPos(key, a)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	to, ok, err := r.GeoPos(key, b)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	return geoDistance(from.Longitude, from.Latitude, to.Longitude, to.Latitude), true, nil
+}
+
+// GeoRadius returns the locations of the *SortedSet item of key within radius meters of the given
+// position, nearest first. A positive count keeps the nearest count.
+func (r *Memory) GeoRadius(key string, longitude, latitude, radius float64, count int) ([]GeoLocation, error) {
+	if !geoValid(longitude, latitude) {
+		return nil, errInvalidCoordinates
+	}
+
+	members, err := r.ZRange(key, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []GeoLocation
+	for _, member := range members {
+		lon, lat := geoDecode(member.Score)
+		if dist := geoDistance(longitude, latitude, lon, lat); dist <= radius {
+			res = append(res, GeoLocation{Name: member.Member, Longitude: lon, Latitude: lat, Dist: dist})
+		}
+	}
+	slices.SortFunc(res, func(a, b GeoLocation) int {
+		return cmp.Or(cmp.Compare(a.Dist, b.Dist), cmp.Compare(a.Name, b.Name))
+	})
+	if count > 0 && len(res) > count {
+		res = res[:count]
+	}
+
+	return res, nil
+}
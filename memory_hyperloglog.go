@@ -0,0 +1,37 @@
+package cache
+
+// PFAdd adds elements to the *HyperLogLog item of key, creating one that never expires if missing,
+// and reports whether its estimate changed.
+func (r *Memory) PFAdd(key string, elements ...string) (bool, error) {
+	if r.readOnly.Load() {
+		return false, ErrReadOnly
+	}
+
+	hll, exist, err := loadStructure(r, key, NewHyperLogLog)
+	if err != nil || !exist {
+		return false, err
+	}
+
+	return hll.Add(elements...), nil
+}
+
+// PFCount returns the estimated number of distinct elements added to the *HyperLogLog items of keys
+// together. Missing keys count as empty.
+func (r *Memory) PFCount(keys ...string) (int64, error) {
+	merged := NewHyperLogLog()
+	for _, key := range keys {
+		hll, exist, err := loadStructure[*HyperLogLog](r, key, nil)
+		if err != nil {
+			return 0, err
+		}
+		if !exist {
+			continue
+		}
+		if len(keys) == 1 {
+			return hll.Count(), nil
+		}
+		merged.Merge(hll)
+	}
+
+	return merged.Count(), nil
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type namespaceUsage struct {
+	entries atomic.Int64
+	bytes   atomic.Int64
+}
+
+// memoryItems is one generation of the items held by a Memory driver, together with
+// the accounting for them. Flush swaps in a new generation as a whole.
+type memoryItems struct {
+	m     sync.Map
+	cost  atomic.Int64
+	bytes atomic.Int64
+	len   atomic.Int64
+	low   atomic.Int64
+	usage []namespaceUsage
+}
+
+func (r *memoryState) newItems() *memoryItems {
+	return &memoryItems{usage: make([]namespaceUsage, len(r.namespaces))}
+}
+
+func (r *memoryState) items() *memoryItems {
+	return r.current.Load()
+}
+
+// over reports whether the namespace exceeds its quota in this generation.
+func (r *memoryItems) over(ns *memoryNamespace) bool {
+	usage := &r.usage[ns.index]
+	return (ns.maxEntries > 0 && usage.entries.Load() > ns.maxEntries) ||
+		(ns.maxBytes > 0 && usage.bytes.Load() > ns.maxBytes)
+}
+
+// stored accounts for e replacing prev under key, evicting other items if that puts
+// its namespace or the cache over budget.
+func (r *memoryState) stored(items *memoryItems, key string, e *memoryEntry, prev any) {
+	if prev != nil {
+		r.removed(items, prev.(*memoryEntry))
+	}
+
+	total := items.cost.Add(e.cost)
+	items.bytes.Add(e.size)
+	items.len.Add(1)
+	if e.priority == PriorityLow {
+		items.low.Add(1)
+	}
+	r.stats.writes.Add(1)
+	if ns := e.namespace; ns != nil {
+		items.usage[ns.index].entries.Add(1)
+		items.usage[ns.index].bytes.Add(e.size)
+		if items.over(ns) {
+			r.evict(items, key, func() bool {
+				return items.over(ns)
+			}, func(e *memoryEntry) bool {
+				return e.namespace == ns
+			})
+		}
+	}
+
+	if r.maxCost > 0 && total > r.maxCost {
+		r.evict(items, key, func() bool {
+			return items.cost.Load() > r.maxCost
+		}, nil)
+	}
+
+	if r.profiler != nil {
+		r.profiler.stored(e)
+	}
+	r.notifyStored(key, e)
+}
+
+// removed accounts for e no longer being stored, stopping its expiration timer.
+func (r *memoryState) removed(items *memoryItems, e *memoryEntry) {
+	e.stop()
+	items.cost.Add(-e.cost)
+	items.bytes.Add(-e.size)
+	items.len.Add(-1)
+	if e.priority == PriorityLow {
+		items.low.Add(-1)
+	}
+	if ns := e.namespace; ns != nil {
+		items.usage[ns.index].entries.Add(-1)
+		items.usage[ns.index].bytes.Add(-e.size)
+	}
+}
+
+// expire removes e if it's still the item stored under key, so stale timers are ignored.
+func (r *memoryState) expire(items *memoryItems, key string, e *memoryEntry) bool {
+	if !r.discard(items, key, e, EventExpire) {
+		return false
+	}
+
+	r.stats.expirations.Add(1)
+	if r.onExpired != nil {
+		r.onExpired(key, e.value)
+	}
+	return true
+}
+
+// discard removes e if it's still the item stored under key, reporting it to watchers as event.
+func (r *memoryState) discard(items *memoryItems, key string, e *memoryEntry, event EventType) bool {
+	if !items.m.CompareAndDelete(key, e) {
+		return false
+	}
+
+	r.removed(items, e)
+	r.watchers.notify(Event{Type: event, Key: key})
+	return true
+}
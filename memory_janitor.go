@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"math"
+	"time"
+)
+
+// JanitorConfig configures the janitor of a Memory driver, see WithJanitor.
+type JanitorConfig struct {
+	// Interval is the time between two sweeps.
+	Interval time.Duration
+	// MaxKeys is how many items a sweep checks at most, zero checking them all.
+	MaxKeys int
+	// Pause is asked before every sweep, which is skipped when it returns true,
+	// e.g. while the process is under load. Nil never pauses.
+	Pause func() bool
+}
+
+// WithJanitor makes the driver sweep the items for expired ones in the background, so items
+// nobody reads don't linger when their expiration isn't timed, as with WithClock. The janitor stops on Close.
+// It panics if config.Interval isn't positive.
+func WithJanitor(config JanitorConfig) MemoryOption {
+	if config.Interval <= 0 {
+		panic("cache: janitor interval must be positive")
+	}
+
+	return func(r *Memory) {
+		r.janitor = &config
+	}
+}
+
+func (r *memoryState) runJanitor(config *JanitorConfig) {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	var from int
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+
+		if config.Pause == nil || !config.Pause() {
+			_, from = r.sweepFrom(from, config.MaxKeys)
+		}
+	}
+}
+
+// sweep removes the expired items, returning how many.
+func (r *memoryState) sweep() int {
+	n, _ := r.sweepFrom(0, 0)
+	return n
+}
+
+// sweepFrom removes the expired items among maxKeys visited from the from-th on, wrapping
+// around, or among all of them for a maxKeys of zero. It returns how many it removed, and
+// where the next sweep resumes so successive ones cover every item.
+func (r *memoryState) sweepFrom(from, maxKeys int) (int, int) {
+	items := r.items()
+	now := r.now()
+	if maxKeys <= 0 {
+		from, maxKeys = 0, math.MaxInt
+	}
+
+	var n, visited int
+	// visit checks up to limit items after the first skip ones, returning how many it checked.
+	visit := func(skip, limit int) int {
+		var i, checked int
+		items.m.Range(func(key, val any) bool {
+			if i++; i <= skip {
+				return true
+			}
+			if checked >= limit || visited >= maxKeys {
+				return false
+			}
+			if e := val.(*memoryEntry); e.expired(now) && r.expire(items, key.(string), e) {
+				n++
+			}
+			checked++
+			visited++
+			return true
+		})
+		return checked
+	}
+
+	checked := visit(from, math.MaxInt)
+	if visited >= maxKeys {
+		return n, from + checked
+	}
+	// The end was reached, the sweep goes on with the items before from.
+	return n, visit(0, from)
+}
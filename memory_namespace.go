@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"slices"
+	"strings"
+)
+
+// NamespaceUsage reports what the items of a namespace currently hold.
+type NamespaceUsage struct {
+	Entries int64
+	Bytes   int64
+}
+
+type memoryNamespace struct {
+	index      int
+	prefix     string
+	maxEntries int64
+	maxBytes   int64
+}
+
+// WithNamespaceQuota limits the keys starting with prefix to maxEntries items and maxBytes
+// of estimated value size, zero meaning no limit. A write that goes over the quota evicts
+// items of the same namespace only. When namespaces overlap, the longest prefix applies.
+func WithNamespaceQuota(prefix string, maxEntries, maxBytes int64) MemoryOption {
+	return func(r *Memory) {
+		r.namespaces = append(r.namespaces, &memoryNamespace{
+			prefix:     prefix,
+			maxEntries: maxEntries,
+			maxBytes:   maxBytes,
+		})
+		slices.SortStableFunc(r.namespaces, func(a, b *memoryNamespace) int {
+			return len(b.prefix) - len(a.prefix)
+		})
+	}
+}
+
+// NamespaceUsage returns the usage of the namespace registered with WithNamespaceQuota for prefix.
+func (r *Memory) NamespaceUsage(prefix string) (NamespaceUsage, bool) {
+	for _, ns := range r.namespaces {
+		if ns.prefix == prefix {
+			usage := &r.items().usage[ns.index]
+			return NamespaceUsage{Entries: usage.entries.Load(), Bytes: usage.bytes.Load()}, true
+		}
+	}
+
+	return NamespaceUsage{}, false
+}
+
+func (r *memoryState) namespaceOf(key string) *memoryNamespace {
+	for _, ns := range r.namespaces {
+		if strings.HasPrefix(key, ns.prefix) {
+			return ns
+		}
+	}
+
+	return nil
+}
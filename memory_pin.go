@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Pinner is implemented by stores able to keep items from being evicted or flushed.
+type Pinner interface {
+	// Pin keeps the item of key from being evicted or flushed, until Unpin.
+	Pin(key string)
+	// Unpin lets the item of key be evicted and flushed again.
+	Unpin(key string)
+}
+
+// keyPins holds the keys pinned with Pin.
+type keyPins struct {
+	keys sync.Map
+	n    atomic.Int64
+}
+
+func (r *keyPins) pinned(key string) bool {
+	if r.n.Load() == 0 {
+		return false
+	}
+
+	_, ok := r.keys.Load(key)
+	return ok
+}
+
+// Pin keeps the item of key from being evicted or flushed, until Unpin.
+// The pin belongs to the key, so it also holds for items stored under it later.
+// Items still expire and can be forgotten, ForceFlush and Close drop them too.
+func (r *Memory) Pin(key string) {
+	if _, loaded := r.pins.keys.LoadOrStore(key, struct{}{}); !loaded {
+		r.pins.n.Add(1)
+	}
+}
+
+// Unpin lets the item of key be evicted and flushed again.
+func (r *Memory) Unpin(key string) {
+	if _, loaded := r.pins.keys.LoadAndDelete(key); loaded {
+		r.pins.n.Add(-1)
+	}
+}
+
+// ForceFlush removes all items from the cache, pinned ones included.
+func (r *Memory) ForceFlush() bool {
+	if r.readOnly.Load() {
+		return false
+	}
+
+	r.flush()
+	return true
+}
+
+// flushUnpinned removes the items whose key isn't pinned.
+func (r *Memory) flushUnpinned() {
+	items := r.items()
+	items.m.Range(func(key, val any) bool {
+		if k := key.(string); !r.pins.pinned(k) {
+			r.invalidate(k)
+			r.dependencies.detach(k)
+			r.discard(items, k, val.(*memoryEntry), EventDelete)
+		}
+		return true
+	})
+}
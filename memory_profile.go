@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// profileTTLBuckets are the upper bounds of the TTL histogram, items that never expire go last.
+var profileTTLBuckets = []time.Duration{
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	10 * time.Minute,
+	time.Hour,
+	24 * time.Hour,
+}
+
+// Profile is what the profiler of a Memory driver collected.
+type Profile struct {
+	// HotKeys are the most read keys, most read first.
+	HotKeys []KeyHits
+	// TTLs is the distribution of the TTLs items were stored with.
+	TTLs []TTLBucket
+}
+
+// KeyHits is how often a key was read, estimated from the sampled reads.
+type KeyHits struct {
+	Key  string
+	Hits int64
+}
+
+// TTLBucket counts the items stored with a TTL up to UpTo, and above the previous bucket.
+// The last bucket has an UpTo of NoExpiration and counts the items stored without one.
+type TTLBucket struct {
+	UpTo  time.Duration
+	Count int64
+}
+
+// memoryProfiler samples reads with the space-saving algorithm, keeping the counts of
+// a bounded number of keys, and counts every write in the TTL histogram.
+type memoryProfiler struct {
+	topN  int
+	every int64
+	reads atomic.Int64
+	ttls  [7]atomic.Int64
+
+	mu   sync.Mutex
+	hits map[string]int64
+}
+
+// WithProfiler makes the driver track its topN most read keys, sampling one read out of every,
+// and the TTL distribution of its writes. They're retrieved with Profile.
+func WithProfiler(topN, every int) MemoryOption {
+	return func(r *Memory) {
+		r.profiler = &memoryProfiler{
+			topN:  topN,
+			every: int64(max(every, 1)),
+			hits:  make(map[string]int64),
+		}
+	}
+}
+
+func (r *memoryProfiler) read(key string) {
+	if r.reads.Add(1)%r.every != 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hits[key]; ok || len(r.hits) < r.topN*10 {
+		r.hits[key]++
+		return
+	}
+
+	// The least counted key makes room for this one, which inherits its count as the error bound.
+	var minKey string
+	minHits := int64(-1)
+	for k, n := range r.hits {
+		if minHits < 0 || n < minHits {
+			minKey, minHits = k, n
+		}
+	}
+	delete(r.hits, minKey)
+	r.hits[key] = minHits + 1
+}
+
+func (r *memoryProfiler) stored(e *memoryEntry) {
+	if e.expires.IsZero() {
+		r.ttls[len(profileTTLBuckets)].Add(1)
+		return
+	}
+
+	ttl := e.expires.Sub(e.created)
+	i, _ := slices.BinarySearch(profileTTLBuckets, ttl)
+	r.ttls[min(i, len(profileTTLBuckets))].Add(1)
+}
+
+func (r *memoryProfiler) profile() Profile {
+	r.mu.Lock()
+	hot := make([]KeyHits, 0, len(r.hits))
+	for key, n := range r.hits {
+		hot = append(hot, KeyHits{Key: key, Hits: n * r.every})
+	}
+	r.mu.Unlock()
+
+	slices.SortFunc(hot, func(a, b KeyHits) int {
+		if a.Hits != b.Hits {
+			return cmp.Compare(b.Hits, a.Hits)
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+	if len(hot) > r.topN {
+		hot = hot[:r.topN]
+	}
+
+	ttls := make([]TTLBucket, 0, len(r.ttls))
+	for i, upTo := range profileTTLBuckets {
+		ttls = append(ttls, TTLBucket{UpTo: upTo, Count: r.ttls[i].Load()})
+	}
+	ttls = append(ttls, TTLBucket{UpTo: NoExpiration, Count: r.ttls[len(profileTTLBuckets)].Load()})
+
+	return Profile{HotKeys: hot, TTLs: ttls}
+}
+
+// Profile returns what the profiler enabled by WithProfiler collected, empty if it isn't enabled.
+func (r *Memory) Profile() Profile {
+	if r.profiler == nil {
+		return Profile{}
+	}
+
+	return r.profiler.profile()
+}
+
+// hit records a read of the item stored under key.
+func (r *memoryState) hit(key string, e *memoryEntry) {
+	e.touch(r.now())
+	r.stats.hits.Add(1)
+	if r.profiler != nil {
+		r.profiler.read(key)
+	}
+}
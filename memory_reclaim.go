@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+// reclaimWatermark is the share of the soft memory limit above which reclaimable values are evicted.
+const reclaimWatermark = 0.9
+
+// WithReclaimableValues lets the garbage collector reclaim values of at least minSize
+// estimated bytes under memory pressure. After every garbage collection leaving the memory
+// used by the process above 90% of the soft memory limit set with debug.SetMemoryLimit or
+// GOMEMLIMIT, such values are evicted, in eviction order, until the estimated bytes freed cover
+// the overshoot. Nothing is reclaimed without a limit, nor once the driver is closed.
+func WithReclaimableValues(minSize int64) MemoryOption {
+	return func(r *Memory) {
+		r.reclaimMin = max(minSize, 1)
+	}
+}
+
+// gcSentinel is finalized on every garbage collection, and arms a new one.
+type gcSentinel struct {
+	state *memoryState
+}
+
+func (r *memoryState) watchGC() {
+	runtime.SetFinalizer(&gcSentinel{state: r}, func(s *gcSentinel) {
+		if s.state.closed.Load() {
+			return
+		}
+		if s.state.reclaiming.CompareAndSwap(false, true) {
+			go func() {
+				defer s.state.reclaiming.Store(false)
+				s.state.reclaim()
+			}()
+		}
+		s.state.watchGC()
+	})
+}
+
+// reclaim evicts reclaimable values while the process is above the watermark of its memory limit.
+func (r *memoryState) reclaim() {
+	limit := debug.SetMemoryLimit(-1)
+	if limit == math.MaxInt64 {
+		return
+	}
+
+	used := memoryUsed()
+	target := int64(float64(limit) * reclaimWatermark)
+	if used <= target {
+		return
+	}
+
+	r.shed(used-target, func(e *memoryEntry) bool {
+		return e.size >= r.reclaimMin
+	})
+}
+
+// shed evicts the items matching match until their estimated size adds up to bytes.
+func (r *memoryState) shed(bytes int64, match func(e *memoryEntry) bool) {
+	items := r.items()
+	floor := items.bytes.Load() - bytes
+	r.evict(items, "", func() bool {
+		return items.bytes.Load() > floor
+	}, match)
+}
+
+// memoryUsed returns the memory the runtime counts against the soft memory limit.
+func memoryUsed() int64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
+
+type memoryPressure struct {
+	watermark int64
+	interval  time.Duration
+}
+
+// WithMemoryPressure makes the driver check the heap usage of the process on every interval,
+// and when it's above watermark bytes evict the share of its items, by estimated size,
+// that the heap is over by. Pinned items are kept.
+func WithMemoryPressure(watermark int64, interval time.Duration) MemoryOption {
+	return func(r *Memory) {
+		r.pressure = &memoryPressure{watermark: watermark, interval: interval}
+	}
+}
+
+func (r *memoryState) watchPressure(pressure *memoryPressure) {
+	ticker := time.NewTicker(pressure.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+
+		heap := heapUsed()
+		if heap <= pressure.watermark {
+			continue
+		}
+		share := float64(heap-pressure.watermark) / float64(heap)
+		r.shed(int64(math.Ceil(float64(r.items().bytes.Load())*share)), nil)
+	}
+}
+
+// heapUsed returns the bytes occupied by heap objects.
+func heapUsed() int64 {
+	samples := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(samples)
+
+	return int64(samples[0].Value.Uint64())
+}
@@ -0,0 +1,72 @@
+package cache
+
+// ZAdd adds members to the *SortedSet item of key, creating one that never expires if missing,
+// and returns how many members were added.
+func (r *Memory) ZAdd(key string, members ...ZMember) (int, error) {
+	if r.readOnly.Load() {
+		return 0, ErrReadOnly
+	}
+
+	set, exist, err := loadStructure(r, key, NewSortedSet)
+	if err != nil || !exist {
+		return 0, err
+	}
+
+	return set.Add(members...), nil
+}
+
+// ZRem removes members from the *SortedSet item of key, returning how many were there.
+func (r *Memory) ZRem(key string, members ...string) (int, error) {
+	if r.readOnly.Load() {
+		return 0, ErrReadOnly
+	}
+
+	set, exist, err := loadStructure[*SortedSet](r, key, nil)
+	if err != nil || !exist {
+		return 0, err
+	}
+
+	return set.Remove(members...), nil
+}
+
+// ZScore returns the score of member in the *SortedSet item of key, reporting whether it's there.
+func (r *Memory) ZScore(key, member string) (float64, bool, error) {
+	set, exist, err := loadStructure[*SortedSet](r, key, nil)
+	if err != nil || !exist {
+		return 0, false, err
+	}
+
+	score, ok := set.Score(member)
+	return score, ok, nil
+}
+
+// ZRange returns the members of the *SortedSet item of key ranked from start to stop included,
+// lowest score first. Negative ranks count from the end, -1 being the last member.
+func (r *Memory) ZRange(key string, start, stop int64) ([]ZMember, error) {
+	set, exist, err := loadStructure[*SortedSet](r, key, nil)
+	if err != nil || !exist {
+		return nil, err
+	}
+
+	return set.Range(start, stop), nil
+}
+
+// ZRevRange is ZRange with the highest score first.
+func (r *Memory) ZRevRange(key string, start, stop int64) ([]ZMember, error) {
+	set, exist, err := loadStructure[*SortedSet](r, key, nil)
+	if err != nil || !exist {
+		return nil, err
+	}
+
+	return set.RevRange(start, stop), nil
+}
+
+// ZCard returns the number of members in the *SortedSet item of key.
+func (r *Memory) ZCard(key string) (int64, error) {
+	set, exist, err := loadStructure[*SortedSet](r, key, nil)
+	if err != nil || !exist {
+		return 0, err
+	}
+
+	return int64(set.Len()), nil
+}
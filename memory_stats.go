@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the counters of a Memory driver. The counters only ever grow,
+// use Delta to get what happened between two snapshots.
+type Stats struct {
+	// At is when the snapshot was taken.
+	At time.Time
+	// Interval is the time between the two snapshots given to Delta, zero otherwise.
+	Interval time.Duration
+
+	// Hits and Misses count the reads that found an item and those that didn't.
+	Hits, Misses uint64
+	// Writes counts the items stored.
+	Writes uint64
+	// Deletes counts the items forgotten.
+	Deletes uint64
+	// Evictions counts the items evicted to stay within limits.
+	Evictions uint64
+	// Expirations counts the items that expired.
+	Expirations uint64
+
+	// Items, Cost and Bytes are the items held when the snapshot was taken,
+	// their total cost and estimated size.
+	Items, Cost, Bytes int64
+	// Patterns is the number of items held by key pattern, most first, WithKeyAnalytics only.
+	Patterns []PatternCount
+}
+
+type memoryStats struct {
+	hits, misses, writes, deletes, evictions, expirations atomic.Uint64
+}
+
+// Stats returns a snapshot of the counters of the driver. Nothing is ever reset,
+// so any number of consumers can take their own snapshots.
+func (r *Memory) Stats() Stats {
+	items := r.items()
+
+	var patterns []PatternCount
+	if r.keyAnalytics {
+		patterns = r.patterns()
+		if r.alarm != nil {
+			r.alarm.check(patterns)
+		}
+	}
+
+	return Stats{
+		At:          r.now(),
+		Hits:        r.stats.hits.Load(),
+		Misses:      r.stats.misses.Load(),
+		Writes:      r.stats.writes.Load(),
+		Deletes:     r.stats.deletes.Load(),
+		Evictions:   r.stats.evictions.Load(),
+		Expirations: r.stats.expirations.Load(),
+		Items:       items.len.Load(),
+		Cost:        items.cost.Load(),
+		Bytes:       items.bytes.Load(),
+		Patterns:    patterns,
+	}
+}
+
+// Delta returns the counters accumulated since prev, an earlier snapshot of the same driver.
+// The items held are those of the current snapshot.
+func (r Stats) Delta(prev Stats) Stats {
+	sub := func(a, b uint64) uint64 {
+		if a < b {
+			return 0
+		}
+		return a - b
+	}
+
+	return Stats{
+		At:          r.At,
+		Interval:    r.At.Sub(prev.At),
+		Hits:        sub(r.Hits, prev.Hits),
+		Misses:      sub(r.Misses, prev.Misses),
+		Writes:      sub(r.Writes, prev.Writes),
+		Deletes:     sub(r.Deletes, prev.Deletes),
+		Evictions:   sub(r.Evictions, prev.Evictions),
+		Expirations: sub(r.Expirations, prev.Expirations),
+		Items:       r.Items,
+		Cost:        r.Cost,
+		Bytes:       r.Bytes,
+		Patterns:    r.Patterns,
+	}
+}
+
+// HitRatio returns the share of reads that found an item, 0 without reads.
+func (r Stats) HitRatio() float64 {
+	if r.Hits+r.Misses == 0 {
+		return 0
+	}
+
+	return float64(r.Hits) / float64(r.Hits+r.Misses)
+}
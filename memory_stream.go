@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+)
+
+// PutReader stores what rd yields under key as a single []byte item, the payload being held
+// in memory anyway.
+func (r *Memory) PutReader(key string, rd io.Reader, t time.Duration) error {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+
+	return r.Put(key, data, WithTTL(t))
+}
+
+// GetReader returns a reader of the string or []byte item of key, copied only WithCopyValues.
+func (r *Memory) GetReader(key string) (io.ReadCloser, bool) {
+	switch val := r.Get(key).(type) {
+	case []byte:
+		return io.NopCloser(bytes.NewReader(val)), true
+	case string:
+		return io.NopCloser(strings.NewReader(val)), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"fmt"
+)
+
+// loadStructure returns the item of key as a T, such as a *HyperLogLog updated in place, reporting
+// whether it exists. When create is set a missing item is created with it, to never expire.
+func loadStructure[T any](r *Memory, key string, create func() T) (T, bool, error) {
+	var zero T
+	e, exist := r.load(key)
+	if !exist && create != nil {
+		if r.readOnly.Load() {
+			return zero, false, ErrReadOnly
+		}
+		r.Add(key, create(), NoExpiration)
+		e, exist = r.load(key)
+	}
+	if !exist {
+		return zero, false, nil
+	}
+
+	res, ok := e.value.(T)
+	if !ok {
+		return zero, false, fmt.Errorf("cache: value of key %s is %T, not %T", key, e.value, zero)
+	}
+
+	return res, true, nil
+}
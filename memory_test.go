@@ -1,8 +1,25 @@
 package cache
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -19,11 +36,11 @@ func TestMemoryTestSuite(t *testing.T) {
 }
 
 func (s *MemoryTestSuite) SetupTest() {
-	s.memory = &Memory{}
+	s.memory = NewMemory()
 }
 
 func (s *MemoryTestSuite) TestAdd() {
-	s.Nil(s.memory.Put("name", "Rat", 1*time.Second))
+	s.Nil(s.memory.Put("name", "Rat", WithTTL(1*time.Second)))
 	s.False(s.memory.Add("name", "World", 1*time.Second))
 	s.True(s.memory.Add("name1", "World", 1*time.Second))
 	s.True(s.memory.Has("name1"))
@@ -32,6 +49,141 @@ func (s *MemoryTestSuite) TestAdd() {
 	s.True(s.memory.Flush())
 }
 
+func (s *MemoryTestSuite) TestAddWithExistingKey() {
+	s.Nil(s.memory.Put("add-existing", "Rat", WithTTL(2*time.Second)))
+	s.False(s.memory.Add("add-existing", "World", 100*time.Millisecond))
+	time.Sleep(200 * time.Millisecond)
+	s.Equal("Rat", s.memory.Get("add-existing"))
+
+	s.Nil(s.memory.Put("add-existing", "Rat"))
+	s.False(s.memory.Add("add-existing", "World", 100*time.Millisecond))
+	time.Sleep(200 * time.Millisecond)
+	s.Equal("Rat", s.memory.Get("add-existing"))
+	info, _ := s.memory.Inspect("add-existing")
+	s.Equal(NoExpiration, info.TTL)
+
+	s.True(s.memory.Add("add-expired", "Rat", 100*time.Millisecond))
+	e, _ := s.memory.load("add-expired")
+	e.stop()
+	time.Sleep(200 * time.Millisecond)
+	s.True(s.memory.Add("add-expired", "World", NoExpiration))
+	s.Equal("World", s.memory.Get("add-expired"))
+}
+
+func (s *MemoryTestSuite) TestBloomShield() {
+	counter := &countingCache{Cache: s.memory}
+	shield := NewBloomShield(counter, 100, 0.01)
+
+	s.Nil(s.memory.Put("bloom-unseen", "Rat"))
+	s.False(shield.Has("bloom-unseen"))
+	s.Equal("World", shield.Get("bloom-unseen", "World"))
+	s.Equal(2, shield.GetInt("bloom-unseen", 2))
+
+	s.Nil(shield.Put("bloom", "Rat"))
+	s.Equal(1, counter.puts)
+	s.True(shield.Has("bloom"))
+	s.Equal("Rat", shield.GetString("bloom"))
+
+	s.Nil(shield.Transaction(context.Background(), func(tx Cache) error {
+		return tx.Put("bloom-tx", "Rat")
+	}))
+	s.True(shield.Has("bloom-tx"))
+
+	shield.Rebuild([]string{"bloom-unseen"})
+	s.True(shield.Has("bloom-unseen"))
+	s.False(shield.Has("bloom"))
+
+	stop := shield.RebuildEvery(10*time.Millisecond, func() []string {
+		return []string{"bloom"}
+	})
+	s.Eventually(func() bool {
+		return shield.Has("bloom")
+	}, time.Second, 10*time.Millisecond)
+	stop()
+	stop()
+
+	// Keys written while source lists the keys are kept.
+	listed := make(chan struct{})
+	var once sync.Once
+	stop = shield.RebuildEvery(10*time.Millisecond, func() []string {
+		s.Nil(shield.Put("bloom-listing", "Rat"))
+		once.Do(func() { close(listed) })
+		return []string{"bloom"}
+	})
+	<-listed
+	stop()
+	s.True(shield.Has("bloom-listing"))
+}
+
+func (s *MemoryTestSuite) TestCoalesce() {
+	counter := &countingCache{Cache: s.memory}
+	coalesced := Coalesce(counter, 1*time.Second)
+
+	s.Nil(coalesced.Put("coalesce", "Rat"))
+	s.Nil(coalesced.Put("coalesce", "Rat"))
+	s.True(coalesced.Forever("coalesce", "Rat"))
+	s.Equal(1, counter.puts)
+
+	s.Nil(coalesced.Put("coalesce", "World"))
+	s.Equal(2, counter.puts)
+	s.Equal("World", coalesced.Get("coalesce"))
+
+	s.True(coalesced.Forget("coalesce"))
+	s.Nil(coalesced.Put("coalesce", "World"))
+	s.Equal(3, counter.puts)
+	s.True(coalesced.Has("coalesce"))
+
+	time.Sleep(1100 * time.Millisecond)
+	s.Nil(coalesced.Put("coalesce", "World"))
+	s.Equal(4, counter.puts)
+
+	// A TTL extension is written, as is a refresh of an item gone meanwhile.
+	s.Nil(coalesced.Put("coalesce", "World", WithTTL(50*time.Millisecond)))
+	s.Equal(5, counter.puts)
+	time.Sleep(100 * time.Millisecond)
+	s.Nil(coalesced.Put("coalesce", "World", WithTTL(50*time.Millisecond)))
+	s.Equal(6, counter.puts)
+	s.True(coalesced.Has("coalesce"))
+	s.True(s.memory.Forget("coalesce"))
+	s.Nil(coalesced.Put("coalesce", "World", WithTTL(50*time.Millisecond)))
+	s.Equal(7, counter.puts)
+}
+
+func (s *MemoryTestSuite) TestCopyValues() {
+	type user struct {
+		Name string
+		Tags []string
+	}
+
+	memory := NewMemory(WithCopyValues())
+	u := &user{Name: "Rat", Tags: []string{"a"}}
+	s.Nil(memory.Put("copy", u))
+	u.Tags[0] = "changed"
+
+	res := memory.Get("copy").(*user)
+	s.Equal([]string{"a"}, res.Tags)
+	res.Name = "changed"
+	s.Equal("Rat", memory.Get("copy").(*user).Name)
+
+	data := []byte("Rat")
+	s.True(memory.Forever("copy-bytes", data))
+	data[0] = 'r'
+	s.Equal([]byte("Rat"), memory.Get("copy-bytes"))
+
+	s.Nil(memory.Put("copy-cloner", clonerValue{n: 1}))
+	// Cloned once when stored and once when read.
+	s.Equal(clonerValue{n: 3}, memory.Get("copy-cloner"))
+
+	res2, err := memory.Increment("copy-counter")
+	s.Nil(err)
+	s.Equal(int64(1), res2)
+	res2, err = memory.Increment("copy-counter")
+	s.Nil(err)
+	s.Equal(int64(2), res2)
+
+	s.Error(memory.Put("copy-func", func() {}))
+}
+
 func (s *MemoryTestSuite) TestDecrement() {
 	res, err := s.memory.Decrement("decrement")
 	s.Equal(int64(-1), res)
@@ -92,21 +244,170 @@ func (s *MemoryTestSuite) TestForget() {
 	val := s.memory.Forget("test-forget")
 	s.True(val)
 
-	err := s.memory.Put("test-forget", "goravel", 5*time.Second)
+	err := s.memory.Put("test-forget", "goravel", WithTTL(5*time.Second))
 	s.Nil(err)
 	s.True(s.memory.Forget("test-forget"))
 }
 
+func (s *MemoryTestSuite) TestEviction() {
+	memory := NewMemory(WithMaxCost(3))
+	s.Nil(memory.Put("a", 1))
+	time.Sleep(time.Millisecond)
+	s.Nil(memory.Put("b", 2))
+	time.Sleep(time.Millisecond)
+	s.Nil(memory.Put("c", 3))
+	time.Sleep(time.Millisecond)
+	s.Equal(1, memory.Get("a"))
+	s.Nil(memory.Put("d", 4))
+	s.True(memory.Has("a"))
+	s.False(memory.Has("b"))
+	s.True(memory.Has("c"))
+	s.True(memory.Has("d"))
+
+	s.Nil(memory.Put("e", 5, WithCost(3)))
+	s.True(memory.Has("e"))
+	s.False(memory.Has("a"))
+	s.False(memory.Has("c"))
+	s.False(memory.Has("d"))
+	info, _ := memory.Inspect("e")
+	s.Equal(int64(3), info.Cost)
+
+	s.ErrorIs(memory.Put("f", 6, WithCost(4)), ErrCostExceeded)
+	s.False(memory.Has("f"))
+	s.True(memory.Add("f", 6, NoExpiration))
+	s.False(memory.Has("e"))
+
+	memory = NewMemory(WithMaxCost(2), WithEvictionPolicy(EvictLFU))
+	s.Nil(memory.Put("a", 1))
+	s.Nil(memory.Put("b", 2))
+	s.Equal(1, memory.Get("a"))
+	s.Equal(1, memory.Get("a"))
+	s.Equal(2, memory.Get("b"))
+	s.Nil(memory.Put("c", 3))
+	s.True(memory.Has("a"))
+	s.False(memory.Has("b"))
+}
+
+func (s *MemoryTestSuite) TestEvictionWithPriority() {
+	memory := NewMemory(WithMaxCost(3))
+	s.Nil(memory.Put("pinned", 1, WithPriority(PriorityPinned)))
+	s.Nil(memory.Put("normal", 2))
+	s.Nil(memory.Put("low", 3, WithPriority(PriorityLow)))
+	s.Equal(3, memory.Get("low"))
+
+	s.Nil(memory.Put("new", 4))
+	s.True(memory.Has("pinned"))
+	s.True(memory.Has("normal"))
+	s.False(memory.Has("low"))
+
+	s.Nil(memory.Put("large", 5, WithCost(3)))
+	s.True(memory.Has("pinned"))
+	s.True(memory.Has("large"))
+	s.False(memory.Has("normal"))
+	s.False(memory.Has("new"))
+
+	info, _ := memory.Inspect("pinned")
+	s.Equal(PriorityPinned, info.Priority)
+}
+
+func (s *MemoryTestSuite) TestEvictionWithManyItems() {
+	memory := NewMemory(WithMaxCost(50))
+	for i := 0; i < 49; i++ {
+		s.Nil(memory.Put(fmt.Sprintf("normal%d", i), i))
+	}
+	s.Nil(memory.Put("low", 1, WithPriority(PriorityLow)))
+
+	s.Nil(memory.Put("new", 2))
+	s.False(memory.Has("low"))
+	for i := 0; i < 49; i++ {
+		s.True(memory.Has(fmt.Sprintf("normal%d", i)))
+	}
+}
+
+func (s *MemoryTestSuite) TestNamespaceQuota() {
+	memory := NewMemory(WithNamespaceQuota("users:", 2, 0), WithNamespaceQuota("users:avatars:", 0, 64))
+	s.Nil(memory.Put("other", 1))
+	s.Nil(memory.Put("users:1", 1))
+	s.Nil(memory.Put("users:2", 2))
+	s.Nil(memory.Put("users:3", 3))
+	s.True(memory.Has("other"))
+	s.False(memory.Has("users:1"))
+	s.True(memory.Has("users:2"))
+	s.True(memory.Has("users:3"))
+
+	usage, ok := memory.NamespaceUsage("users:")
+	s.True(ok)
+	s.Equal(int64(2), usage.Entries)
+
+	s.Nil(memory.Put("users:avatars:1", strings.Repeat("a", 32)))
+	s.Nil(memory.Put("users:avatars:2", strings.Repeat("b", 32)))
+	s.False(memory.Has("users:avatars:1"))
+	s.True(memory.Has("users:avatars:2"))
+	s.ErrorIs(memory.Put("users:avatars:3", strings.Repeat("c", 64)), ErrQuotaExceeded)
+
+	usage, _ = memory.NamespaceUsage("users:avatars:")
+	s.Equal(NamespaceUsage{Entries: 1, Bytes: 48}, usage)
+	s.True(memory.Forget("users:avatars:2"))
+	usage, _ = memory.NamespaceUsage("users:avatars:")
+	s.Equal(NamespaceUsage{}, usage)
+
+	_, ok = memory.NamespaceUsage("missing:")
+	s.False(ok)
+}
+
 func (s *MemoryTestSuite) TestFlush() {
-	s.Nil(s.memory.Put("test-flush", "goravel", 5*time.Second))
+	s.Nil(s.memory.Put("test-flush", "goravel", WithTTL(5*time.Second)))
 	s.Equal("goravel", s.memory.Get("test-flush", nil).(string))
 
 	s.True(s.memory.Flush())
 	s.False(s.memory.Has("test-flush"))
 }
 
+func (s *MemoryTestSuite) TestFlushExpired() {
+	s.Nil(s.memory.Put("flush-expired", "Rat", WithTTL(10*time.Millisecond)))
+	s.Nil(s.memory.Put("flush-alive", "Rat"))
+	e, ok := s.memory.load("flush-expired")
+	s.True(ok)
+	e.stop()
+
+	time.Sleep(20 * time.Millisecond)
+	_, ok = s.memory.items().m.Load("flush-expired")
+	s.True(ok)
+	s.Equal(1, s.memory.FlushExpired())
+	_, ok = s.memory.items().m.Load("flush-expired")
+	s.False(ok)
+	s.True(s.memory.Has("flush-alive"))
+	s.Equal(0, s.memory.FlushExpired())
+}
+
+func (s *MemoryTestSuite) TestFlushWithConcurrent() {
+	memory := NewMemory(WithMaxCost(1000))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.Nil(memory.Put(fmt.Sprintf("flush-concurrent-%d", i), i, WithTTL(time.Second)))
+		}()
+		go func() {
+			defer wg.Done()
+			s.True(memory.Flush())
+		}()
+	}
+	wg.Wait()
+
+	s.True(memory.Flush())
+	s.Equal(int64(0), memory.items().cost.Load())
+	s.Nil(memory.Put("flush-concurrent", 1, WithTTL(10*time.Millisecond)))
+	s.True(memory.Flush())
+	s.Nil(memory.Put("flush-concurrent", 2))
+	time.Sleep(20 * time.Millisecond)
+	s.Equal(2, memory.Get("flush-concurrent"))
+}
+
 func (s *MemoryTestSuite) TestGet() {
-	s.Nil(s.memory.Put("name", "Rat", 1*time.Second))
+	s.Nil(s.memory.Put("name", "Rat", WithTTL(1*time.Second)))
 	s.Equal("Rat", s.memory.Get("name", "").(string))
 	s.Equal("World", s.memory.Get("name1", "World").(string))
 	s.Equal("World1", s.memory.Get("name2", func() any {
@@ -118,25 +419,25 @@ func (s *MemoryTestSuite) TestGet() {
 
 func (s *MemoryTestSuite) TestGetBool() {
 	s.Equal(true, s.memory.GetBool("test-get-bool", true))
-	s.Nil(s.memory.Put("test-get-bool", true, 2*time.Second))
+	s.Nil(s.memory.Put("test-get-bool", true, WithTTL(2*time.Second)))
 	s.Equal(true, s.memory.GetBool("test-get-bool", false))
 }
 
 func (s *MemoryTestSuite) TestGetInt() {
 	s.Equal(2, s.memory.GetInt("test-get-int", 2))
-	s.Nil(s.memory.Put("test-get-int", 3, 2*time.Second))
+	s.Nil(s.memory.Put("test-get-int", 3, WithTTL(2*time.Second)))
 	s.Equal(3, s.memory.GetInt("test-get-int", 2))
 }
 
 func (s *MemoryTestSuite) TestGetString() {
 	s.Equal("2", s.memory.GetString("test-get-string", "2"))
-	s.Nil(s.memory.Put("test-get-string", "3", 2*time.Second))
+	s.Nil(s.memory.Put("test-get-string", "3", WithTTL(2*time.Second)))
 	s.Equal("3", s.memory.GetString("test-get-string", "2"))
 }
 
 func (s *MemoryTestSuite) TestHas() {
 	s.False(s.memory.Has("test-has"))
-	s.Nil(s.memory.Put("test-has", "goravel", 5*time.Second))
+	s.Nil(s.memory.Put("test-has", "goravel", WithTTL(5*time.Second)))
 	s.True(s.memory.Has("test-has"))
 }
 
@@ -190,6 +491,34 @@ func (s *MemoryTestSuite) TestIncrementWithConcurrent() {
 	s.Nil(err)
 }
 
+func (s *MemoryTestSuite) TestInspect() {
+	_, ok := s.memory.Inspect("inspect")
+	s.False(ok)
+
+	s.Nil(s.memory.Put("inspect", "Rat", WithTTL(2*time.Second)))
+	info, ok := s.memory.Inspect("inspect")
+	s.True(ok)
+	s.Equal(int64(0), info.Hits)
+	s.True(info.Created.Equal(info.LastAccess))
+	s.WithinDuration(info.Created.Add(2*time.Second), info.Expires, 0)
+	s.InDelta(2*time.Second, info.TTL, float64(100*time.Millisecond))
+	s.Equal(int64(19), info.Size)
+
+	s.Equal("Rat", s.memory.Get("inspect"))
+	s.Equal("Rat", s.memory.GetString("inspect"))
+	info, ok = s.memory.Inspect("inspect")
+	s.True(ok)
+	s.Equal(int64(2), info.Hits)
+	s.False(info.LastAccess.Before(info.Created))
+
+	s.True(s.memory.Forever("inspect-forever", []int64{1, 2}))
+	info, ok = s.memory.Inspect("inspect-forever")
+	s.True(ok)
+	s.True(info.Expires.IsZero())
+	s.Equal(NoExpiration, info.TTL)
+	s.Equal(int64(40), info.Size)
+}
+
 func (s *MemoryTestSuite) TestLock() {
 	tests := []struct {
 		name  string
@@ -357,23 +686,112 @@ func (s *MemoryTestSuite) TestLock() {
 	}
 }
 
+func (s *MemoryTestSuite) TestPipeline() {
+	s.Nil(s.memory.Put("pipeline-forget", "Rat"))
+
+	pipeline := s.memory.Pipeline().
+		Put("pipeline-put", "Rat", WithTTL(1*time.Second)).
+		Forget("pipeline-forget").
+		Increment("pipeline-counter", 3).
+		Decrement("pipeline-counter")
+	s.Equal(4, pipeline.Len())
+
+	res, err := pipeline.Exec(context.Background())
+	s.Nil(err)
+	s.Equal([]any{nil, true, int64(3), int64(2)}, res)
+	s.Equal(0, pipeline.Len())
+	s.Equal("Rat", s.memory.GetString("pipeline-put"))
+	s.False(s.memory.Has("pipeline-forget"))
+	s.Equal(int64(2), s.memory.GetInt64("pipeline-counter"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err = s.memory.Pipeline().Put("pipeline-canceled", "Rat").Exec(ctx)
+	s.ErrorIs(err, context.Canceled)
+	s.Empty(res)
+	s.False(s.memory.Has("pipeline-canceled"))
+
+	// Atomic pipelines apply all of their operations, or none.
+	res, err = s.memory.Pipeline().
+		Put("pipeline-atomic", "Rat").
+		Increment("pipeline-counter").
+		ExecAtomic(context.Background())
+	s.Nil(err)
+	s.Equal([]any{nil, int64(3)}, res)
+	s.Equal("Rat", s.memory.Get("pipeline-atomic"))
+	s.Equal(int64(3), s.memory.GetInt64("pipeline-counter"))
+
+	res, err = s.memory.Pipeline().
+		Forget("pipeline-atomic").
+		Increment("pipeline-put").
+		ExecAtomic(context.Background())
+	s.ErrorIs(err, ErrInvalidValueType)
+	s.Nil(res)
+	s.Equal("Rat", s.memory.Get("pipeline-atomic"))
+}
+
 func (s *MemoryTestSuite) TestPull() {
-	s.Nil(s.memory.Put("name", "Rat", 1*time.Second))
+	s.Nil(s.memory.Put("name", "Rat", WithTTL(1*time.Second)))
 	s.True(s.memory.Has("name"))
 	s.Equal("Rat", s.memory.Pull("name", "").(string))
 	s.False(s.memory.Has("name"))
 }
 
 func (s *MemoryTestSuite) TestPut() {
-	s.Nil(s.memory.Put("name", "Rat", 1*time.Second))
+	s.Nil(s.memory.Put("name", "Rat", WithTTL(1*time.Second)))
 	s.True(s.memory.Has("name"))
 	s.Equal("Rat", s.memory.Get("name", "").(string))
 	time.Sleep(2 * time.Second)
 	s.False(s.memory.Has("name"))
 }
 
+func (s *MemoryTestSuite) TestPutWithJitter() {
+	s.Nil(s.memory.Put("put-jitter", "Rat", WithTTL(1*time.Second), WithJitter(1*time.Second)))
+	info, ok := s.memory.Inspect("put-jitter")
+	s.True(ok)
+	s.GreaterOrEqual(info.Expires.Sub(info.Created), 1*time.Second)
+	s.Less(info.Expires.Sub(info.Created), 2*time.Second)
+
+	s.Nil(s.memory.Put("put-jitter-forever", "Rat", WithJitter(1*time.Second)))
+	info, ok = s.memory.Inspect("put-jitter-forever")
+	s.True(ok)
+	s.Equal(NoExpiration, info.TTL)
+}
+
+func (s *MemoryTestSuite) TestPutAsync() {
+	writer := NewAsyncWriter(s.memory, 2, 10, OverflowBlock)
+	for i := 0; i < 100; i++ {
+		s.Nil(writer.PutAsync(fmt.Sprintf("put-async-%d", i), i))
+	}
+	writer.Close()
+	s.Equal(99, s.memory.GetInt("put-async-99"))
+	s.ErrorIs(writer.PutAsync("put-async-closed", 1), ErrAsyncClosed)
+
+	release := make(chan struct{})
+	blocked := &blockingCache{Cache: s.memory, release: release}
+
+	writer = NewAsyncWriter(blocked, 1, 0, OverflowError)
+	s.Eventually(func() bool {
+		return writer.PutAsync("put-async-error", 1) == nil
+	}, time.Second, time.Millisecond)
+	s.ErrorIs(writer.PutAsync("put-async-error", 2), ErrAsyncQueueFull)
+	s.Equal(int64(1), writer.Dropped())
+	close(release)
+	writer.Close()
+	s.Equal(1, s.memory.GetInt("put-async-error"))
+
+	var failed []string
+	writer = NewAsyncWriter(&blockingCache{Cache: s.memory, err: errors.New("error")}, 1, 1, OverflowDrop).
+		OnError(func(key string, err error) {
+			failed = append(failed, key)
+		})
+	s.Nil(writer.PutAsync("put-async-failed", 1))
+	writer.Close()
+	s.Equal([]string{"put-async-failed"}, failed)
+}
+
 func (s *MemoryTestSuite) TestRemember() {
-	s.Nil(s.memory.Put("name", "Rat", 1*time.Second))
+	s.Nil(s.memory.Put("name", "Rat", WithTTL(1*time.Second)))
 	value, err := s.memory.Remember("name", 1*time.Second, func() (any, error) {
 		return "World", nil
 	})
@@ -396,8 +814,95 @@ func (s *MemoryTestSuite) TestRemember() {
 	s.Nil(value)
 }
 
+func (s *MemoryTestSuite) TestRememberStale() {
+	s.Nil(s.memory.Put("remember-stale", "Rat", WithTTL(2*time.Second), WithSoftTTL(100*time.Millisecond)))
+	info, ok := s.memory.Inspect("remember-stale")
+	s.True(ok)
+	s.False(info.Stale)
+
+	time.Sleep(150 * time.Millisecond)
+	info, _ = s.memory.Inspect("remember-stale")
+	s.True(info.Stale)
+	s.Equal("Rat", s.memory.Get("remember-stale"))
+
+	var calls atomic.Int32
+	callback := func() (any, error) {
+		calls.Add(1)
+		return "World", nil
+	}
+	value, err := s.memory.Remember("remember-stale", 2*time.Second, callback)
+	s.Nil(err)
+	s.Equal("Rat", value)
+	s.Eventually(func() bool {
+		return s.memory.Get("remember-stale") == "World"
+	}, time.Second, 10*time.Millisecond)
+	s.Equal(int32(1), calls.Load())
+
+	info, _ = s.memory.Inspect("remember-stale")
+	s.False(info.Stale)
+	s.WithinDuration(info.Created.Add(100*time.Millisecond), info.StaleAt, 0)
+
+	s.Nil(s.memory.Put("remember-stale-soft-only", "Rat", WithTTL(1*time.Second), WithSoftTTL(2*time.Second)))
+	info, _ = s.memory.Inspect("remember-stale-soft-only")
+	s.True(info.StaleAt.IsZero())
+}
+
+func (s *MemoryTestSuite) TestRememberWithInvalidation() {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		value, err := s.memory.Remember("remember-invalidated", 1*time.Second, func() (any, error) {
+			close(started)
+			<-release
+			return "stale", nil
+		})
+		s.Nil(err)
+		s.Equal("stale", value)
+	}()
+
+	<-started
+	s.True(s.memory.Forget("remember-invalidated"))
+	close(release)
+	<-done
+	s.False(s.memory.Has("remember-invalidated"))
+
+	value, err := s.memory.RememberForever("remember-invalidated", func() (any, error) {
+		return "fresh", nil
+	})
+	s.Nil(err)
+	s.Equal("fresh", value)
+	s.Equal("fresh", s.memory.Get("remember-invalidated"))
+	s.Equal(int32(0), s.memory.inflight.Load())
+}
+
+func (s *MemoryTestSuite) TestRememberWithPut() {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		value, err := s.memory.Remember("remember-put", 1*time.Second, func() (any, error) {
+			close(started)
+			<-release
+			return "stale", nil
+		})
+		s.Nil(err)
+		s.Equal("stale", value)
+	}()
+
+	<-started
+	s.Nil(s.memory.Put("remember-put", "fresh"))
+	close(release)
+	<-done
+	value, exists := s.memory.GetExists("remember-put")
+	s.True(exists)
+	s.Equal("fresh", value)
+}
+
 func (s *MemoryTestSuite) TestRememberForever() {
-	s.Nil(s.memory.Put("name", "Rat", 1*time.Second))
+	s.Nil(s.memory.Put("name", "Rat", WithTTL(1*time.Second)))
 	value, err := s.memory.RememberForever("name", func() (any, error) {
 		return "World", nil
 	})
@@ -417,3 +922,2895 @@ func (s *MemoryTestSuite) TestRememberForever() {
 	s.EqualError(err, "error")
 	s.Nil(value)
 }
+
+func (s *MemoryTestSuite) TestDebounce() {
+	var calls int
+	fn := func() error {
+		calls++
+		return nil
+	}
+
+	ran, err := Debounce(s.memory, "debounce", 100*time.Millisecond, fn)
+	s.True(ran)
+	s.Nil(err)
+	ran, err = Debounce(s.memory, "debounce", 100*time.Millisecond, fn)
+	s.False(ran)
+	s.Nil(err)
+	s.Equal(1, calls)
+
+	time.Sleep(150 * time.Millisecond)
+	ran, err = Debounce(s.memory, "debounce", 100*time.Millisecond, fn)
+	s.True(ran)
+	s.Nil(err)
+	s.Equal(2, calls)
+
+	ran, err = Debounce(s.memory, "debounce-error", 100*time.Millisecond, func() error {
+		return errors.New("error")
+	})
+	s.True(ran)
+	s.EqualError(err, "error")
+	ran, err = Debounce(s.memory, "debounce-error", 100*time.Millisecond, fn)
+	s.True(ran)
+	s.Nil(err)
+
+	var panicErr *PanicError
+	ran, err = Debounce(s.memory, "debounce-panic", 100*time.Millisecond, func() error {
+		panic("Rat")
+	})
+	s.True(ran)
+	s.ErrorAs(err, &panicErr)
+	s.False(s.memory.Has("debounce-panic"))
+}
+
+func (s *MemoryTestSuite) TestThrottle() {
+	for i := 0; i < 3; i++ {
+		allowed, err := Throttle(s.memory, "throttle", 3, 100*time.Millisecond)
+		s.Nil(err)
+		s.True(allowed)
+	}
+	allowed, err := Throttle(s.memory, "throttle", 3, 100*time.Millisecond)
+	s.Nil(err)
+	s.False(allowed)
+	info, _ := s.memory.Inspect("throttle")
+	s.Greater(info.TTL, time.Duration(0))
+	s.LessOrEqual(info.TTL, 100*time.Millisecond)
+
+	time.Sleep(150 * time.Millisecond)
+	allowed, err = Throttle(s.memory, "throttle", 3, 100*time.Millisecond)
+	s.Nil(err)
+	s.True(allowed)
+
+	s.Nil(s.memory.Put("throttle-invalid", "Rat"))
+	_, err = Throttle(s.memory, "throttle-invalid", 3, 100*time.Millisecond)
+	s.EqualError(err, "cache: invalid int value type")
+}
+
+func (s *MemoryTestSuite) TestLeaderElector() {
+	first := NewLeaderElector(s.memory, "leader", 150*time.Millisecond)
+	second := NewLeaderElector(s.memory, "leader", 150*time.Millisecond)
+	s.NotEqual(first.ID(), second.ID())
+
+	s.Nil(first.Campaign(context.Background()))
+	s.True(first.Leader())
+	s.True(<-first.IsLeader())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(second.Campaign(ctx), context.DeadlineExceeded)
+	s.False(second.Leader())
+	s.Equal(first.ID(), s.memory.Get("leader"))
+
+	s.ErrorIs(second.Renew(), ErrNotLeader)
+	s.Nil(second.Resign())
+	s.Equal(first.ID(), s.memory.Get("leader"))
+
+	s.Nil(first.Resign())
+	s.False(first.Leader())
+	s.False(<-first.IsLeader())
+	s.False(s.memory.Has("leader"))
+
+	s.Nil(second.Campaign(context.Background()))
+	s.True(second.Leader())
+
+	// Losing the key is noticed on the next renewal.
+	s.memory.Forget("leader")
+	s.Nil(s.memory.Put("leader", first.ID()))
+	s.True(<-second.IsLeader())
+	s.False(<-second.IsLeader())
+	s.False(second.Leader())
+}
+
+func (s *MemoryTestSuite) TestForgetPattern() {
+	s.Nil(s.memory.Put("report:daily:1", 1))
+	s.Nil(s.memory.Put("report:daily:2", 2))
+	s.Nil(s.memory.Put("report:weekly:1", 3))
+
+	s.Equal(2, s.memory.ForgetPattern("report:daily:*"))
+	s.False(s.memory.Has("report:daily:1"))
+	s.False(s.memory.Has("report:daily:2"))
+	s.True(s.memory.Has("report:weekly:1"))
+	s.Equal(0, s.memory.ForgetPattern("report:[invalid"))
+}
+
+func (s *MemoryTestSuite) TestSchedule() {
+	at := func(value string) time.Time {
+		t, err := time.Parse(time.DateTime, value)
+		s.Nil(err)
+		return t
+	}
+	next := func(spec, from string) string {
+		sched, err := parseSchedule(spec)
+		s.Nil(err)
+		return sched.next(at(from)).Format(time.DateTime)
+	}
+
+	s.Equal("2024-03-02 00:00:00", next("@daily", "2024-03-01 12:30:00"))
+	s.Equal("2024-03-01 12:31:00", next("* * * * *", "2024-03-01 12:30:15"))
+	s.Equal("2024-03-01 12:45:00", next("*/15 * * * *", "2024-03-01 12:30:00"))
+	s.Equal("2024-03-04 09:00:00", next("0 9 * * 1-5", "2024-03-01 09:00:00"))
+	s.Equal("2024-03-03 00:00:00", next("0 0 * * 7", "2024-03-01 09:00:00"))
+	s.Equal("2024-03-03 00:00:00", next("0 0 15 * 0", "2024-03-01 09:00:00"))
+	s.Equal("2028-02-29 00:00:00", next("0 0 29 2 *", "2024-03-01 00:00:00"))
+	s.Equal("2025-01-01 00:00:00", next("@yearly", "2024-03-01 00:00:00"))
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := parseSchedule(spec)
+		s.ErrorIs(err, ErrInvalidSchedule, spec)
+	}
+	sched, err := parseSchedule("0 0 31 2 *")
+	s.Nil(err)
+	s.True(sched.next(time.Now()).IsZero())
+}
+
+func (s *MemoryTestSuite) TestScheduler() {
+	scheduler := NewScheduler(s.memory)
+	s.ErrorIs(scheduler.ForgetKeys("invalid", "report"), ErrInvalidSchedule)
+	s.Nil(scheduler.ForgetKeys("@daily", "report"))
+	s.Nil(scheduler.ForgetPattern("@hourly", "report:*"))
+	s.ErrorIs(NewScheduler(Coalesce(s.memory, time.Second)).ForgetPattern("@hourly", "report:*"), ErrPatternUnsupported)
+
+	s.Nil(s.memory.Put("report", 1))
+	s.Nil(s.memory.Put("report:1", 1))
+
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	hourly := scheduler.next()
+	s.True(hourly.After(time.Now()))
+	scheduler.run(hourly)
+	s.True(s.memory.Has("report"))
+	s.False(s.memory.Has("report:1"))
+	s.True(scheduler.next().After(hourly))
+
+	scheduler.run(time.Now().AddDate(0, 0, 1))
+	s.False(s.memory.Has("report"))
+}
+
+func (s *MemoryTestSuite) TestPutWithDependencies() {
+	s.Nil(s.memory.Put("user:1", "Rat"))
+	s.Nil(s.memory.PutWithDependencies("profile:1", "profile", 1*time.Second, "user:1"))
+	s.Nil(s.memory.PutWithDependencies("page:1", "page", 1*time.Second, "profile:1", "settings:1"))
+	s.Nil(s.memory.PutWithDependencies("stats", "stats", 1*time.Second, "user:1", "user:2"))
+
+	s.True(s.memory.Forget("user:1"))
+	s.False(s.memory.Has("profile:1"))
+	s.False(s.memory.Has("page:1"))
+	s.False(s.memory.Has("stats"))
+
+	s.Nil(s.memory.PutWithDependencies("profile:1", "profile", 1*time.Second, "user:1"))
+	s.Nil(s.memory.Put("user:1", "Go"))
+	s.False(s.memory.Has("profile:1"))
+
+	// Storing a key again without dependencies drops its old ones.
+	s.Nil(s.memory.PutWithDependencies("profile:1", "profile", 1*time.Second, "user:1"))
+	s.Nil(s.memory.Put("profile:1", "profile"))
+	s.Nil(s.memory.Put("user:1", "Rat"))
+	s.True(s.memory.Has("profile:1"))
+
+	s.Nil(s.memory.PutWithDependencies("a", 1, 1*time.Second, "b"))
+	s.Nil(s.memory.PutWithDependencies("b", 2, 1*time.Second, "a"))
+	s.False(s.memory.Has("a"))
+	s.Nil(s.memory.PutWithDependencies("a", 1, 1*time.Second, "b"))
+	s.True(s.memory.Forget("a"))
+	s.False(s.memory.Has("b"))
+
+	s.Nil(s.memory.PutWithDependencies("profile:2", "profile", 1*time.Second, "user:2"))
+	s.True(s.memory.Flush())
+	s.Nil(s.memory.Put("profile:2", "profile"))
+	s.Nil(s.memory.Put("user:2", "Rat"))
+	s.True(s.memory.Has("profile:2"))
+}
+
+func (s *MemoryTestSuite) TestGetOrLock() {
+	value, hit, lock := GetOrLock(s.memory, "get-or-lock", 1*time.Second)
+	s.Nil(value)
+	s.False(hit)
+	s.NotNil(lock)
+
+	s.Equal(int64(0), lock.Token())
+	s.False(s.memory.Has("get-or-lock:lock:fence"))
+
+	value, hit, other := GetOrLock(s.memory, "get-or-lock", 1*time.Second)
+	s.Nil(value)
+	s.False(hit)
+	s.Nil(other)
+
+	s.Nil(s.memory.Put("get-or-lock", "Rat"))
+	s.True(lock.Release())
+
+	value, hit, lock = GetOrLock(s.memory, "get-or-lock", 1*time.Second)
+	s.Equal("Rat", value)
+	s.True(hit)
+	s.Nil(lock)
+	s.False(s.memory.Has("get-or-lock:lock"))
+}
+
+func (s *MemoryTestSuite) TestSemaphore() {
+	sem := NewSemaphore(s.memory, "semaphore", 2, 200*time.Millisecond)
+	first, ok := sem.Acquire()
+	s.True(ok)
+	second, ok := sem.Acquire()
+	s.True(ok)
+	_, ok = sem.Acquire()
+	s.False(ok)
+	s.Equal(2, sem.Held())
+
+	s.True(first.Release())
+	s.False(first.Release())
+	s.Equal(1, sem.Held())
+
+	third, ok := sem.Block(1 * time.Second)
+	s.True(ok)
+	_, ok = sem.Block(50 * time.Millisecond)
+	s.False(ok)
+
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		third.Release()
+	}()
+	_, ok = sem.Block(1 * time.Second)
+	s.True(ok)
+
+	// Slots of holders that never release free up after the ttl.
+	time.Sleep(250 * time.Millisecond)
+	s.Equal(0, sem.Held())
+	s.False(second.Release())
+}
+
+func (s *MemoryTestSuite) TestRWLock() {
+	first := NewRWLock(s.memory, "rwlock", 200*time.Millisecond)
+	second := NewRWLock(s.memory, "rwlock", 200*time.Millisecond)
+	writer := NewRWLock(s.memory, "rwlock", 200*time.Millisecond)
+
+	s.True(first.RLock())
+	s.True(second.RLock())
+	s.False(writer.WLock())
+	s.False(writer.RUnlock())
+
+	s.True(first.RUnlock())
+	s.False(first.RUnlock())
+	s.False(writer.WLock())
+	s.True(second.RUnlock())
+	s.False(s.memory.Has("rwlock"))
+
+	s.True(writer.WLock())
+	s.False(first.RLock())
+	s.False(second.WLock())
+	s.False(first.WUnlock())
+
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		writer.WUnlock()
+	}()
+	s.True(first.BlockRLock(1 * time.Second))
+	s.False(writer.BlockWLock(50 * time.Millisecond))
+
+	// Holds that are never released expire after the ttl.
+	s.True(writer.BlockWLock(1 * time.Second))
+
+	var wg sync.WaitGroup
+	var held atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reader := NewRWLock(s.memory, "rwlock-concurrent", 1*time.Second)
+			if reader.RLock() {
+				held.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	s.Equal(int32(10), held.Load())
+
+	// A short hold doesn't cut a longer one short.
+	long := NewRWLock(s.memory, "rwlock-ttl", 1*time.Second)
+	short := NewRWLock(s.memory, "rwlock-ttl", 50*time.Millisecond)
+	s.True(long.RLock())
+	s.True(short.RLock())
+	time.Sleep(100 * time.Millisecond)
+	s.False(NewRWLock(s.memory, "rwlock-ttl", 1*time.Second).WLock())
+	s.True(long.RUnlock())
+}
+
+func (s *MemoryTestSuite) TestLockToken() {
+	lock := s.memory.Lock("fence", 100*time.Millisecond)
+	s.Equal(int64(0), lock.Token())
+	token, ok := lock.GetToken()
+	s.True(ok)
+	s.Equal(int64(1), token)
+	s.Equal(int64(1), lock.Token())
+
+	other := s.memory.Lock("fence", 100*time.Millisecond)
+	_, ok = other.GetToken()
+	s.False(ok)
+
+	time.Sleep(150 * time.Millisecond)
+	token, ok = other.GetToken()
+	s.True(ok)
+	s.Equal(int64(2), token)
+	s.True(other.Release())
+
+	s.True(lock.Get(func() {
+		s.Equal(int64(3), lock.Token())
+	}))
+
+	// The counter survives flushes, and a lock without a token isn't acquired.
+	s.True(s.memory.Flush())
+	token, ok = lock.GetToken()
+	s.True(ok)
+	s.Equal(int64(4), token)
+	s.True(lock.Release())
+	s.True(s.memory.ForceFlush())
+	s.Nil(s.memory.Put("fence:fence", "Rat"))
+	_, ok = lock.GetToken()
+	s.False(ok)
+	s.False(s.memory.Has("fence"))
+	s.ErrorIs(lock.BlockContext(context.Background()), ErrInvalidValueType)
+
+	// Counters are pinned through wrappers too.
+	for _, wrapped := range []Cache{Use(s.memory), ObserveLocks(s.memory, NewLockMetrics())} {
+		s.True(s.memory.ForceFlush())
+		lock = wrapped.Lock("fence-wrapped", 100*time.Millisecond)
+		s.True(lock.Get())
+		s.True(lock.Release())
+		s.True(wrapped.Flush())
+		s.True(lock.Get())
+		s.Equal(int64(2), lock.Token())
+		s.True(lock.Release())
+	}
+}
+
+func (s *MemoryTestSuite) TestWaitFor() {
+	s.Nil(s.memory.Put("wait-for", "Rat"))
+	value, err := s.memory.WaitFor(context.Background(), "wait-for", 1*time.Second)
+	s.Nil(err)
+	s.Equal("Rat", value)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.True(s.memory.Add("wait-for-add", "Go", 1*time.Second))
+	}()
+	value, err = s.memory.WaitFor(context.Background(), "wait-for-add", 1*time.Second)
+	s.Nil(err)
+	s.Equal("Go", value)
+
+	_, err = s.memory.WaitFor(context.Background(), "wait-for-missing", 50*time.Millisecond)
+	s.ErrorIs(err, ErrWaitTimeout)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.memory.WaitFor(ctx, "wait-for-missing", 1*time.Second)
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(int32(0), s.memory.watchers.n.Load())
+
+	// Stores that can't notify are polled.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.Nil(s.memory.Put("wait-for-poll", "Rat"))
+	}()
+	value, err = WaitFor(context.Background(), Coalesce(s.memory, time.Second), "wait-for-poll", 1*time.Second)
+	s.Nil(err)
+	s.Equal("Rat", value)
+	_, err = WaitFor(context.Background(), Coalesce(s.memory, time.Second), "wait-for-missing", 100*time.Millisecond)
+	s.ErrorIs(err, ErrWaitTimeout)
+}
+
+func (s *MemoryTestSuite) TestWatch() {
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := s.memory.Watch(ctx, "watch")
+	s.Nil(err)
+
+	s.Nil(s.memory.Put("watch", "Rat"))
+	s.Nil(s.memory.Put("watch-other", "Go"))
+	s.True(s.memory.Forget("watch"))
+	s.True(s.memory.Add("watch", "Go", 50*time.Millisecond))
+	s.Equal(Event{Type: EventSet, Key: "watch", Value: "Rat"}, <-events)
+	s.Equal(Event{Type: EventDelete, Key: "watch"}, <-events)
+	s.Equal(Event{Type: EventSet, Key: "watch", Value: "Go"}, <-events)
+	s.Equal(Event{Type: EventExpire, Key: "watch"}, <-events)
+
+	s.Nil(s.memory.Put("watch", "Rat"))
+	s.True(s.memory.Flush())
+	s.Equal(EventSet, (<-events).Type)
+	s.Equal(Event{Type: EventDelete, Key: "watch"}, <-events)
+
+	cancel()
+	_, ok := <-events
+	s.False(ok)
+	s.Eventually(func() bool {
+		return s.memory.watchers.n.Load() == 0
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = s.memory.Watch(ctx, "watch")
+	s.ErrorIs(err, context.Canceled)
+}
+
+func (s *MemoryTestSuite) TestUse() {
+	var ops []string
+	logger := func(next Handler) Handler {
+		return func(ctx context.Context, op *Op) (any, error) {
+			ops = append(ops, op.Name+" "+op.Key)
+			return next(ctx, op)
+		}
+	}
+	upper := func(next Handler) Handler {
+		return func(ctx context.Context, op *Op) (any, error) {
+			if value, ok := op.Value.(string); ok {
+				op.Value = strings.ToUpper(value)
+			}
+			return next(ctx, op)
+		}
+	}
+
+	store := Use(s.memory, logger, upper)
+	s.Nil(store.Put("use", "rat"))
+	s.Equal("RAT", s.memory.Get("use"))
+	s.Equal("RAT", store.GetString("use"))
+	s.Equal("default", store.Get("use-missing", "default"))
+	s.True(store.Add("use-add", "go", 1*time.Second))
+	s.True(store.Has("use-add"))
+	value, err := store.Increment("use-counter", 2)
+	s.Nil(err)
+	s.Equal(int64(2), value)
+	res, err := store.Remember("use-remember", 1*time.Second, func() (any, error) {
+		return "remembered", nil
+	})
+	s.Nil(err)
+	s.Equal("remembered", res)
+	s.Equal("RAT", store.Pull("use"))
+	s.True(store.Forget("use-add"))
+
+	s.Nil(store.Transaction(context.Background(), func(tx Cache) error {
+		return tx.Put("use-tx", "tx")
+	}))
+	s.Equal("TX", s.memory.Get("use-tx"))
+
+	s.True(store.Lock("use-lock").Get())
+
+	s.Equal([]string{
+		"Put use", "Get use", "Get use-missing", "Add use-add", "Has use-add", "Increment use-counter",
+		"Remember use-remember", "Pull use", "Forget use-add", "Put use-tx", "Add use-lock", "Increment use-lock:fence",
+	}, ops)
+}
+
+func (s *MemoryTestSuite) TestRememberWithPanic() {
+	_, err := s.memory.Remember("remember-panic", 1*time.Second, func() (any, error) {
+		panic("boom")
+	})
+	var panicErr *PanicError
+	s.ErrorAs(err, &panicErr)
+	s.Equal("boom", panicErr.Value)
+	s.Contains(string(panicErr.Stack), "TestRememberWithPanic")
+	s.False(s.memory.Has("remember-panic"))
+
+	_, err = s.memory.RememberForever("remember-panic", func() (any, error) {
+		panic(ErrCostExceeded)
+	})
+	s.ErrorIs(err, ErrCostExceeded)
+
+	err = s.memory.Transaction(context.Background(), func(tx Cache) error {
+		_, err := tx.Remember("remember-panic", 1*time.Second, func() (any, error) {
+			panic("boom")
+		})
+		return err
+	})
+	s.ErrorAs(err, &panicErr)
+
+	// The key isn't left marked as being computed.
+	s.Len(s.memory.flights, 0)
+	value, err := s.memory.Remember("remember-panic", 1*time.Second, func() (any, error) {
+		return "Rat", nil
+	})
+	s.Nil(err)
+	s.Equal("Rat", value)
+
+	var calls atomic.Int32
+	fn := Memoize(s.memory, "memoize-panic", 1*time.Second, func(n int) (int, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		panic("boom")
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := fn(1)
+			var panicErr *PanicError
+			s.ErrorAs(err, &panicErr)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *MemoryTestSuite) TestRememberWithLock() {
+	for _, tt := range []struct {
+		memory *Memory
+		calls  int32
+	}{
+		{NewMemory(), 1},
+		{NewMemory(WithoutRememberLock()), 5},
+	} {
+		var calls atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, err := tt.memory.Remember("remember-lock", 1*time.Second, func() (any, error) {
+					calls.Add(1)
+					time.Sleep(50 * time.Millisecond)
+					return "Rat", nil
+				})
+				s.Nil(err)
+				s.Equal("Rat", value)
+			}()
+		}
+		wg.Wait()
+		s.Equal(tt.calls, calls.Load())
+	}
+}
+
+func (s *MemoryTestSuite) TestGetExists() {
+	s.Nil(s.memory.Put("get-exists", nil))
+	value, exist := s.memory.GetExists("get-exists")
+	s.True(exist)
+	s.Nil(value)
+	_, exist = s.memory.GetExists("get-exists-missing")
+	s.False(exist)
+
+	var calls int
+	for i := 0; i < 2; i++ {
+		value, err := s.memory.Remember("get-exists-remember", 1*time.Second, func() (any, error) {
+			calls++
+			return nil, nil
+		})
+		s.Nil(err)
+		s.Nil(value)
+	}
+	s.Equal(1, calls)
+
+	store := Use(s.memory)
+	_, exist = store.GetExists("get-exists")
+	s.True(exist)
+	_, exist = store.GetExists("get-exists-missing")
+	s.False(exist)
+
+	s.Nil(s.memory.Transaction(context.Background(), func(tx Cache) error {
+		_, exist := tx.GetExists("get-exists")
+		s.True(exist)
+		s.True(tx.Forget("get-exists"))
+		_, exist = tx.GetExists("get-exists")
+		s.False(exist)
+		return nil
+	}))
+}
+
+func (s *MemoryTestSuite) TestChaos() {
+	store := Chaos(s.memory, ChaosConfig{ErrorRate: 1})
+	s.ErrorIs(store.Put("chaos", "Rat"), ErrDriverUnavailable)
+	s.False(s.memory.Has("chaos"))
+	s.False(store.Add("chaos", "Rat", 1*time.Second))
+	_, err := store.Increment("chaos-counter")
+	s.ErrorIs(err, ErrDriverUnavailable)
+
+	s.Nil(s.memory.Put("chaos", "Rat"))
+	s.Nil(store.Get("chaos"))
+	s.Equal("default", store.Get("chaos", "default"))
+	_, err = store.Remember("chaos", 1*time.Second, func() (any, error) {
+		return "Go", nil
+	})
+	s.ErrorIs(err, ErrDriverUnavailable)
+
+	store = Chaos(s.memory, ChaosConfig{DropRate: 1})
+	s.Nil(store.Put("chaos-drop", "Rat"))
+	s.True(store.Add("chaos-drop", "Rat", 1*time.Second))
+	s.True(store.Forever("chaos-drop", "Rat"))
+	s.False(s.memory.Has("chaos-drop"))
+	s.Equal("Rat", store.Get("chaos"))
+
+	store = Chaos(s.memory, ChaosConfig{Latency: 50 * time.Millisecond})
+	start := time.Now()
+	s.Equal("Rat", store.Get("chaos"))
+	s.GreaterOrEqual(time.Since(start), 50*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Nil(store.WithContext(ctx).Get("chaos"))
+
+	// The same seed injects the same faults.
+	run := func() []bool {
+		store := Chaos(s.memory, ChaosConfig{ErrorRate: 0.5, Rand: rand.New(rand.NewPCG(1, 2)), Err: errors.New("error")})
+		var res []bool
+		for i := 0; i < 20; i++ {
+			res = append(res, store.Has("chaos"))
+		}
+		return res
+	}
+	first := run()
+	s.Equal(first, run())
+	s.Contains(first, true)
+	s.Contains(first, false)
+}
+
+func (s *MemoryTestSuite) TestDeterministic() {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	memory := NewMemory(WithDeterministic(clock, 42))
+
+	events, err := memory.Watch(context.Background(), "deterministic")
+	s.Nil(err)
+	s.Nil(memory.Put("deterministic", "Rat", WithTTL(1*time.Minute)))
+	s.Equal(EventSet, (<-events).Type)
+
+	info, ok := memory.Inspect("deterministic")
+	s.True(ok)
+	s.Equal(start, info.Created)
+	s.Equal(1*time.Minute, info.TTL)
+
+	clock.Advance(59 * time.Second)
+	s.True(memory.Has("deterministic"))
+	clock.Advance(1 * time.Second)
+	s.Equal(Event{Type: EventExpire, Key: "deterministic"}, <-events)
+	s.False(memory.Has("deterministic"))
+
+	ttls := func() []time.Duration {
+		memory := NewMemory(WithDeterministic(NewFakeClock(start), 42))
+		var res []time.Duration
+		for i := 0; i < 5; i++ {
+			key := fmt.Sprintf("deterministic-%d", i)
+			s.Nil(memory.Put(key, i, WithTTL(1*time.Minute), WithJitter(1*time.Minute)))
+			info, _ := memory.Inspect(key)
+			res = append(res, info.TTL)
+		}
+		return res
+	}
+	s.Equal(ttls(), ttls())
+
+	memory = NewMemory(WithClock(clock))
+	s.True(memory.Add("deterministic", "Rat", 1*time.Second))
+	clock.Advance(1 * time.Second)
+	s.True(memory.Add("deterministic", "Go", 1*time.Second))
+	s.Equal("Go", memory.Get("deterministic"))
+}
+
+func (s *MemoryTestSuite) TestProfile() {
+	s.Equal(Profile{}, s.memory.Profile())
+
+	memory := NewMemory(WithProfiler(2, 1))
+	s.Nil(memory.Put("profile-1", 1, WithTTL(500*time.Millisecond)))
+	s.Nil(memory.Put("profile-2", 2, WithTTL(5*time.Minute)))
+	s.Nil(memory.Put("profile-3", 3))
+	for i := 0; i < 3; i++ {
+		memory.Get("profile-1")
+	}
+	memory.Get("profile-2")
+	for i := 0; i < 2; i++ {
+		_, _ = memory.Remember("profile-3", 1*time.Second, func() (any, error) {
+			return 3, nil
+		})
+	}
+	memory.Get("profile-missing")
+
+	profile := memory.Profile()
+	s.Equal([]KeyHits{{Key: "profile-1", Hits: 3}, {Key: "profile-3", Hits: 2}}, profile.HotKeys)
+	s.Equal([]TTLBucket{
+		{UpTo: time.Second, Count: 1},
+		{UpTo: 10 * time.Second, Count: 0},
+		{UpTo: time.Minute, Count: 0},
+		{UpTo: 10 * time.Minute, Count: 1},
+		{UpTo: time.Hour, Count: 0},
+		{UpTo: 24 * time.Hour, Count: 0},
+		{UpTo: NoExpiration, Count: 1},
+	}, profile.TTLs)
+
+	memory = NewMemory(WithProfiler(1, 10))
+	s.Nil(memory.Put("profile", 1))
+	for i := 0; i < 100; i++ {
+		memory.Get("profile")
+	}
+	s.Equal([]KeyHits{{Key: "profile", Hits: 100}}, memory.Profile().HotKeys)
+}
+
+func (s *MemoryTestSuite) TestWithSlowThreshold() {
+	var slow []string
+	store := Use(s.memory, WithSlowThreshold(50*time.Millisecond, func(op, key string, dur time.Duration) {
+		s.GreaterOrEqual(dur, 50*time.Millisecond)
+		slow = append(slow, op+" "+key)
+	}))
+
+	s.Nil(store.Put("slow", "Rat"))
+	s.Equal("Rat", store.Get("slow"))
+	_, err := store.Remember("slow-remember", 1*time.Second, func() (any, error) {
+		time.Sleep(60 * time.Millisecond)
+		return "Go", nil
+	})
+	s.Nil(err)
+	s.Equal([]string{"Remember slow-remember"}, slow)
+}
+
+func (s *MemoryTestSuite) TestRememberDistributed() {
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each caller sees the cache through its own wrapper, as separate processes would.
+			value, err := RememberDistributed(Coalesce(s.memory, time.Second), "remember-distributed", 1*time.Second, 1*time.Second, func() (any, error) {
+				calls.Add(1)
+				time.Sleep(100 * time.Millisecond)
+				return "Rat", nil
+			})
+			s.Nil(err)
+			s.Equal("Rat", value)
+		}()
+	}
+	wg.Wait()
+	s.Equal(int32(1), calls.Load())
+	s.False(s.memory.Has("remember-distributed:lock"))
+
+	// A holder that never stores the item is taken over after lockTTL.
+	s.True(s.memory.Lock("remember-distributed-stuck:lock", 100*time.Millisecond).Get())
+	value, err := RememberDistributed(s.memory, "remember-distributed-stuck", 1*time.Second, 100*time.Millisecond, func() (any, error) {
+		return "Go", nil
+	})
+	s.Nil(err)
+	s.Equal("Go", value)
+
+	_, err = RememberDistributed(s.memory, "remember-distributed-error", 1*time.Second, 1*time.Second, func() (any, error) {
+		return nil, errors.New("error")
+	})
+	s.EqualError(err, "error")
+	s.False(s.memory.Has("remember-distributed-error:lock"))
+}
+
+func (s *MemoryTestSuite) TestClose() {
+	memory := NewMemory()
+	s.Nil(memory.Put("close", "Rat", WithTTL(1*time.Second)))
+	events, err := memory.Watch(context.Background(), "close")
+	s.Nil(err)
+	done := make(chan error)
+	go func() {
+		_, err := memory.WaitFor(context.Background(), "close-wait", 1*time.Second)
+		done <- err
+	}()
+	s.Eventually(func() bool {
+		return memory.watchers.n.Load() == 2
+	}, time.Second, 10*time.Millisecond)
+
+	s.Nil(memory.Close(context.Background()))
+	s.False(memory.Has("close"))
+	s.ErrorIs(<-done, ErrClosed)
+	for range events {
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.ErrorIs(memory.Close(ctx), context.Canceled)
+
+	shield := NewBloomShield(NewMemory(), 100, 0.01)
+	var rebuilds atomic.Int32
+	shield.RebuildEvery(10*time.Millisecond, func() []string {
+		rebuilds.Add(1)
+		return nil
+	})
+	s.Eventually(func() bool {
+		return rebuilds.Load() > 0
+	}, time.Second, 10*time.Millisecond)
+	s.Nil(shield.Close(context.Background()))
+	n := rebuilds.Load()
+	time.Sleep(50 * time.Millisecond)
+	s.Equal(n, rebuilds.Load())
+
+	tenants := NewTenants()
+	s.Nil(tenants.Tenant("a").Put("close", "Rat"))
+	s.Nil(tenants.Close(context.Background()))
+	s.Empty(tenants.Tenants())
+	s.False(tenants.Tenant("a").Has("close"))
+}
+
+func (s *MemoryTestSuite) TestCodec() {
+	type user struct {
+		Name string
+		Age  int
+	}
+
+	for _, codec := range []Codec{JSONCodec, GobCodec} {
+		s.Nil(PutEncoded(s.memory, codec, "codec", user{Name: "Rat", Age: 3}))
+		s.IsType([]byte{}, s.memory.Get("codec"))
+		value, ok, err := GetDecoded[user](s.memory, codec, "codec")
+		s.Nil(err)
+		s.True(ok)
+		s.Equal(user{Name: "Rat", Age: 3}, value)
+	}
+
+	_, ok, err := GetDecoded[user](s.memory, JSONCodec, "codec-missing")
+	s.Nil(err)
+	s.False(ok)
+	s.Nil(s.memory.Put("codec-raw", "Rat"))
+	_, _, err = GetDecoded[user](s.memory, JSONCodec, "codec-raw")
+	s.EqualError(err, "cache: value of key codec-raw is string, not encoded")
+
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	s.Nil(err)
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, loc)
+	hook := TypeHook(func(t time.Time) ([]byte, error) {
+		return []byte(t.Format(time.RFC3339Nano) + "|" + t.Location().String()), nil
+	}, func(data []byte) (time.Time, error) {
+		value, name, _ := strings.Cut(string(data), "|")
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.ParseInLocation(time.RFC3339Nano, value, loc)
+	})
+	codec := WithHooks(JSONCodec, hook)
+
+	s.Nil(PutEncoded(s.memory, codec, "codec-time", at))
+	decoded, ok, err := GetDecoded[time.Time](s.memory, codec, "codec-time")
+	s.Nil(err)
+	s.True(ok)
+	s.True(at.Equal(decoded))
+	s.Equal("Asia/Shanghai", decoded.Location().String())
+
+	s.Nil(PutEncoded(s.memory, codec, "codec", user{Name: "Go"}))
+	value, _, err := GetDecoded[user](s.memory, codec, "codec")
+	s.Nil(err)
+	s.Equal("Go", value.Name)
+	s.Error(codec.Unmarshal(nil, user{}))
+
+	// Hooks apply to the fields of structs too.
+	type event struct {
+		Name   string
+		At     time.Time
+		Seen   []*time.Time
+		ByUser map[string]time.Time
+	}
+	for _, base := range []Codec{JSONCodec, GobCodec} {
+		codec := WithHooks(base, hook)
+		data, err := codec.Marshal(event{Name: "deploy", At: at, Seen: []*time.Time{&at}, ByUser: map[string]time.Time{"rat": at}})
+		s.Nil(err)
+		var decoded event
+		s.Nil(codec.Unmarshal(data, &decoded))
+		s.Equal("deploy", decoded.Name)
+		s.Equal("Asia/Shanghai", decoded.At.Location().String())
+		s.Equal("Asia/Shanghai", decoded.Seen[0].Location().String())
+		s.Equal("Asia/Shanghai", decoded.ByUser["rat"].Location().String())
+		s.True(at.Equal(decoded.ByUser["rat"]))
+	}
+}
+
+func (s *MemoryTestSuite) TestEnvelope() {
+	type user struct {
+		Name string
+	}
+
+	v1 := Envelope(JSONCodec, WithSchemaVersion(1))
+	v2 := Envelope(JSONCodec, WithSchemaVersion(2))
+	s.Nil(PutEncoded(s.memory, v1, "envelope", user{Name: "Rat"}))
+	value, ok, err := GetDecoded[user](s.memory, v1, "envelope")
+	s.Nil(err)
+	s.True(ok)
+	s.Equal("Rat", value.Name)
+
+	_, ok, err = GetDecoded[user](s.memory, v2, "envelope")
+	s.Nil(err)
+	s.False(ok)
+	s.False(s.memory.Has("envelope"))
+
+	s.Nil(PutEncoded(s.memory, JSONCodec, "envelope", user{Name: "Rat"}))
+	_, ok, err = GetDecoded[user](s.memory, v1, "envelope")
+	s.Nil(err)
+	s.False(ok)
+
+	var res user
+	s.ErrorIs(v1.Unmarshal([]byte{envelopeMagic}, &res), ErrSchemaMismatch)
+	data, err := Envelope(GobCodec).Marshal(user{Name: "Go"})
+	s.Nil(err)
+	s.Nil(Envelope(GobCodec).Unmarshal(data, &res))
+	s.Equal("Go", res.Name)
+}
+
+func (s *MemoryTestSuite) TestEnvelopeWithChecksum() {
+	type user struct {
+		Name string
+	}
+
+	codec := Envelope(JSONCodec, WithSchemaVersion(1), WithChecksum())
+	s.Nil(PutEncoded(s.memory, codec, "checksum", user{Name: "Rat"}))
+	value, ok, err := GetDecoded[user](s.memory, codec, "checksum")
+	s.Nil(err)
+	s.True(ok)
+	s.Equal("Rat", value.Name)
+
+	data := s.memory.Get("checksum").([]byte)
+	var res user
+	s.Nil(Envelope(JSONCodec, WithSchemaVersion(1)).Unmarshal(data, &res))
+
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)-2] ^= 0x01
+	s.ErrorIs(codec.Unmarshal(flipped, &res), ErrChecksumMismatch)
+	s.ErrorIs(codec.Unmarshal(data[:4], &res), ErrChecksumMismatch)
+
+	s.Nil(s.memory.Put("checksum", flipped))
+	_, ok, err = GetDecoded[user](s.memory, codec, "checksum")
+	s.Nil(err)
+	s.False(ok)
+	s.False(s.memory.Has("checksum"))
+}
+
+func (s *MemoryTestSuite) TestReadOnly() {
+	s.Nil(s.memory.Put("read-only", "Rat"))
+	s.memory.SetReadOnly(true)
+
+	s.ErrorIs(s.memory.Put("read-only", "Go"), ErrReadOnly)
+	s.False(s.memory.Add("read-only-add", "Go", 1*time.Second))
+	s.False(s.memory.Forever("read-only", "Go"))
+	s.False(s.memory.Forget("read-only"))
+	s.False(s.memory.Flush())
+	_, err := s.memory.Increment("read-only-counter")
+	s.ErrorIs(err, ErrReadOnly)
+	s.ErrorIs(s.memory.Transaction(context.Background(), func(tx Cache) error {
+		return tx.Put("read-only", "Go")
+	}), ErrReadOnly)
+	s.Equal("Rat", s.memory.Pull("read-only"))
+	s.Equal("Rat", s.memory.Get("read-only"))
+
+	value, err := s.memory.Remember("read-only-remember", 1*time.Second, func() (any, error) {
+		return "Go", nil
+	})
+	s.Nil(err)
+	s.Equal("Go", value)
+	s.False(s.memory.Has("read-only-remember"))
+
+	s.memory.SetReadOnly(false)
+	s.Nil(s.memory.Put("read-only", "Go"))
+	s.Equal("Go", s.memory.Get("read-only"))
+
+	memory := NewMemory(WithReadOnly())
+	s.ErrorIs(memory.Put("read-only", "Rat"), ErrReadOnly)
+}
+
+func (s *MemoryTestSuite) TestShadow() {
+	candidate := NewMemory()
+	store := Shadow(s.memory, candidate)
+
+	s.Nil(store.Put("shadow", "Rat"))
+	s.Equal("Rat", candidate.Get("shadow"))
+	s.Equal("Rat", store.Get("shadow"))
+	s.Equal(ShadowStats{Reads: 1, Writes: 1}, store.Stats())
+
+	// The candidate diverging never changes what callers see.
+	s.Nil(candidate.Put("shadow", "Go"))
+	s.Equal("Rat", store.Get("shadow"))
+	s.True(candidate.Forget("shadow"))
+	s.Equal("Rat", store.Get("shadow"))
+	s.Equal(ShadowStats{Reads: 3, Misses: 1, Mismatches: 1, Writes: 1}, store.Stats())
+
+	calls := 0
+	value, err := store.Remember("shadow-remember", 1*time.Second, func() (any, error) {
+		calls++
+		return "Rat", nil
+	})
+	s.Nil(err)
+	s.Equal("Rat", value)
+	s.Equal(1, calls)
+	s.Equal("Rat", candidate.Get("shadow-remember"))
+
+	_, err = store.Increment("shadow-counter", 2)
+	s.Nil(err)
+	s.Equal(int64(2), candidate.GetInt64("shadow-counter"))
+	s.Equal(ShadowStats{Reads: 4, Misses: 2, Mismatches: 1, Writes: 3}, store.Stats())
+
+	s.True(store.WithContext(context.Background()).Forget("shadow"))
+	s.Equal(uint64(4), store.Stats().Writes)
+	s.Nil(store.Close(context.Background()))
+	s.False(candidate.Has("shadow-counter"))
+}
+
+func (s *MemoryTestSuite) TestSplit() {
+	next := NewMemory()
+	store := Split(s.memory, next, 0)
+
+	for i := 0; i < 100; i++ {
+		s.Nil(store.Put(fmt.Sprintf("split-%d", i), i))
+	}
+	s.Equal(99, next.GetInt("split-99"))
+	s.False(store.Routed("split-1"))
+
+	// Reads of routed keys are served from the new backend only.
+	store.SetPercent(50)
+	s.Equal(float64(50), store.Percent())
+	routed := 0
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("split-%d", i)
+		s.True(next.Forget(key))
+		if store.Routed(key) {
+			routed++
+			s.Nil(store.Get(key))
+		} else {
+			s.Equal(i, store.Get(key))
+		}
+	}
+	s.Greater(routed, 25)
+	s.Less(routed, 75)
+
+	// Rolling back serves everything from the old backend again.
+	store.SetPercent(0)
+	s.Equal(1, store.Get("split-1"))
+
+	store.SetPercent(100)
+	calls := 0
+	value, err := store.Remember("split-remember", 1*time.Second, func() (any, error) {
+		calls++
+		return "Rat", nil
+	})
+	s.Nil(err)
+	s.Equal("Rat", value)
+	s.Equal(1, calls)
+	s.Equal("Rat", s.memory.Get("split-remember"))
+	s.Equal("Rat", next.Get("split-remember"))
+
+	s.True(store.WithContext(context.Background()).Forget("split-remember"))
+	s.False(s.memory.Has("split-remember"))
+	s.False(next.Has("split-remember"))
+}
+
+func (s *MemoryTestSuite) TestAdaptiveTTL() {
+	store := AdaptiveTTL(s.memory, AdaptiveTTLConfig{Window: 10, Apply: true})
+
+	// Half of the reads miss, so a longer TTL is suggested and applied.
+	s.Nil(store.Put("users:1", "Rat", WithTTL(1*time.Minute)))
+	for i := 0; i < 5; i++ {
+		s.Equal("Rat", store.Get("users:1"))
+		s.Nil(store.Get("users:2"))
+	}
+	s.Equal([]TTLRecommendation{{Prefix: "users", TTL: 1 * time.Minute, Suggested: 2 * time.Minute, HitRatio: 0.5}}, store.Recommendations())
+	s.Nil(store.Put("users:2", "Go", WithTTL(1*time.Minute)))
+	info, _ := s.memory.Inspect("users:2")
+	s.InDelta(float64(2*time.Minute), float64(info.TTL), float64(time.Second))
+
+	// Add keeps its TTL, holding locks for as long as they were asked for.
+	s.True(store.Lock("users:lock", 1*time.Minute).Get())
+	info, _ = s.memory.Inspect("users:lock")
+	s.InDelta(float64(1*time.Minute), float64(info.TTL), float64(time.Second))
+
+	// Items written more often than they're read get a shorter one.
+	for i := 0; i < 20; i++ {
+		s.Nil(store.Put("posts:1", "Rat", WithTTL(1*time.Minute)))
+	}
+	for i := 0; i < 10; i++ {
+		s.True(store.Has("posts:1"))
+	}
+	s.Equal(30*time.Second, store.Recommendations()[0].Suggested)
+
+	// Remember counts calls of its callback as misses.
+	for i := 0; i < 10; i++ {
+		_, err := store.Remember(fmt.Sprintf("items:%d", i%2), 1*time.Minute, func() (any, error) {
+			return "Rat", nil
+		})
+		s.Nil(err)
+	}
+	s.Equal(TTLRecommendation{Prefix: "items", TTL: 1 * time.Minute, Suggested: 2 * time.Minute, HitRatio: 0.8}, store.Recommendations()[0])
+
+	// Without Apply, writes keep the TTL they're given.
+	store = AdaptiveTTL(s.memory, AdaptiveTTLConfig{Window: 1})
+	s.Nil(store.WithContext(context.Background()).Put("users:3", "Rat", WithTTL(1*time.Minute)))
+	s.Nil(store.Get("users:4"))
+	s.Nil(store.Put("users:3", "Rat", WithTTL(1*time.Minute)))
+	s.Equal(2*time.Minute, store.Recommendations()[0].Suggested)
+	info, _ = s.memory.Inspect("users:3")
+	s.InDelta(float64(1*time.Minute), float64(info.TTL), float64(time.Second))
+}
+
+func (s *MemoryTestSuite) TestKeyedMutex() {
+	var mu KeyedMutex
+	var count atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mu.Lock("keyed-mutex")
+			defer mu.Unlock("keyed-mutex")
+			s.Equal(int64(1), count.Add(1))
+			time.Sleep(time.Millisecond)
+			count.Add(-1)
+		}()
+	}
+
+	// Other keys aren't blocked.
+	mu.Lock("keyed-mutex-other")
+	mu.Unlock("keyed-mutex-other")
+	wg.Wait()
+	s.Empty(mu.locks)
+	s.Panics(func() {
+		mu.Unlock("keyed-mutex")
+	})
+}
+
+func (s *MemoryTestSuite) TestLockContext() {
+	lock := s.memory.Lock("lock-context", 1*time.Second)
+	s.True(lock.GetContext(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.False(s.memory.Lock("lock-context-canceled").GetContext(ctx))
+	s.False(s.memory.Has("lock-context-canceled"))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := s.memory.Lock("lock-context").BlockContext(ctx)
+	s.ErrorIs(err, ErrLockTimeout)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	s.Less(time.Since(start), 1*time.Second)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		lock.Release()
+	}()
+	called := false
+	s.Nil(s.memory.Lock("lock-context").BlockContext(context.Background(), func() {
+		called = true
+	}))
+	s.True(called)
+	s.False(s.memory.Has("lock-context"))
+}
+
+func (s *MemoryTestSuite) TestObserveLocks() {
+	metrics := NewLockMetrics()
+	store := ObserveLocks(s.memory, metrics)
+
+	lock := store.Lock("observe-lock", 1*time.Second)
+	s.True(lock.Get())
+	s.False(store.Lock("observe-lock").Get())
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		s.True(lock.Release())
+	}()
+	other := store.WithContext(context.Background()).Lock("observe-lock")
+	s.Nil(other.BlockContext(context.Background()))
+	s.True(other.Release())
+
+	stats := metrics.Stats()["observe-lock"]
+	s.Equal(int64(2), stats.Acquisitions)
+	s.Equal(int64(2), stats.Contentions)
+	s.Len(stats.Waits, 7)
+	s.Equal(DurationBucket{UpTo: time.Millisecond, Count: 1}, stats.Waits[0])
+	s.Equal(DurationBucket{UpTo: time.Second, Count: 1}, stats.Waits[3])
+	s.Equal(int64(1), stats.Holds[0].Count+stats.Holds[1].Count)
+	s.Equal(int64(1), stats.Holds[3].Count)
+	s.Equal(time.Duration(math.MaxInt64), stats.Holds[6].UpTo)
+}
+
+func (s *MemoryTestSuite) TestRedlock() {
+	a, b, c := NewMemory(), NewMemory(), NewMemory()
+
+	lock := NewRedlock("redlock", 1*time.Second, a, b, c)
+	s.True(lock.Get())
+	s.True(a.Has("redlock") && b.Has("redlock") && c.Has("redlock"))
+	s.Equal(int64(1), lock.Token())
+	s.False(NewRedlock("redlock", 1*time.Second, a, b, c).Get())
+	s.True(lock.Release())
+	s.False(a.Has("redlock") || b.Has("redlock") || c.Has("redlock"))
+
+	// A minority held by someone else doesn't prevent the lock, nor is it released by it.
+	s.True(c.Add("redlock", "other", 1*time.Second))
+	lock = NewRedlock("redlock", 1*time.Second, a, b, c)
+	s.True(lock.Get())
+	s.True(lock.Release())
+	s.Equal("other", c.Get("redlock"))
+
+	// Without a majority, the instances already locked are rolled back.
+	s.True(b.Add("redlock", "other", 1*time.Second))
+	s.False(NewRedlock("redlock", 1*time.Second, a, b, c).GetContext(context.Background()))
+	s.False(a.Has("redlock"))
+}
+
+func (s *MemoryTestSuite) TestOnExpired() {
+	var mu sync.Mutex
+	expired := make(map[string]any)
+	clock := NewFakeClock(time.Now())
+	memory := NewMemory(WithClock(clock), OnExpired(func(key string, value any) {
+		mu.Lock()
+		defer mu.Unlock()
+		expired[key] = value
+	}))
+
+	s.Nil(memory.Put("on-expired", "Rat", WithTTL(1*time.Second)))
+	s.Nil(memory.Put("on-expired-forgotten", "Rat", WithTTL(1*time.Second)))
+	s.True(memory.Forget("on-expired-forgotten"))
+	s.Nil(memory.Put("on-expired-read", "Go", WithTTL(1*time.Second)))
+
+	clock.Advance(2 * time.Second)
+	s.Nil(memory.Get("on-expired-read"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	s.Equal(map[string]any{"on-expired": "Rat", "on-expired-read": "Go"}, expired)
+}
+
+func (s *MemoryTestSuite) TestReclaimableValues() {
+	memory := NewMemory(WithReclaimableValues(1024))
+	s.Nil(memory.Put("reclaimable", make([]byte, 4096)))
+	s.Nil(memory.Put("reclaimable-pinned", make([]byte, 4096), WithPriority(PriorityPinned)))
+	s.Nil(memory.Put("reclaimable-small", "Rat"))
+
+	// Nothing is reclaimed without a memory limit.
+	runtime.GC()
+	time.Sleep(50 * time.Millisecond)
+	s.True(memory.Has("reclaimable"))
+
+	limit := debug.SetMemoryLimit(memoryUsed() / 2)
+	defer debug.SetMemoryLimit(limit)
+	runtime.GC()
+	s.Eventually(func() bool {
+		return !memory.Has("reclaimable")
+	}, 1*time.Second, 10*time.Millisecond)
+	debug.SetMemoryLimit(limit)
+	s.True(memory.Has("reclaimable-pinned"))
+	s.True(memory.Has("reclaimable-small"))
+	s.Nil(memory.Close(context.Background()))
+}
+
+func (s *MemoryTestSuite) TestMemoryPressure() {
+	memory := NewMemory(WithMemoryPressure(1, 10*time.Millisecond))
+	for i := 0; i < 10; i++ {
+		s.Nil(memory.Put(fmt.Sprintf("pressure-%d", i), make([]byte, 1024)))
+	}
+	s.Nil(memory.Put("pressure-pinned", make([]byte, 1024), WithPriority(PriorityPinned)))
+
+	s.Eventually(func() bool {
+		return !memory.Has("pressure-0") && !memory.Has("pressure-9")
+	}, 1*time.Second, 10*time.Millisecond)
+	s.True(memory.Has("pressure-pinned"))
+	s.Nil(memory.Close(context.Background()))
+	s.Nil(memory.Close(context.Background()))
+
+	memory = NewMemory(WithMemoryPressure(math.MaxInt64, 10*time.Millisecond))
+	s.Nil(memory.Put("pressure", make([]byte, 1024)))
+	time.Sleep(50 * time.Millisecond)
+	s.True(memory.Has("pressure"))
+	s.Nil(memory.Close(context.Background()))
+}
+
+func (s *MemoryTestSuite) TestPin() {
+	memory := NewMemory(WithMaxCost(2))
+	memory.Pin("pin")
+	s.Nil(memory.Put("pin", "Rat"))
+	s.Nil(memory.Put("pin-other", "Rat"))
+	s.Nil(memory.Put("pin-another", "Rat"))
+	s.True(memory.Has("pin"))
+	s.False(memory.Has("pin-other"))
+
+	s.True(memory.Flush())
+	s.True(memory.Has("pin"))
+	s.False(memory.Has("pin-another"))
+
+	// The pin outlives the item.
+	s.True(memory.Forget("pin"))
+	s.Nil(memory.Put("pin", "Go"))
+	s.True(memory.Flush())
+	s.Equal("Go", memory.Get("pin"))
+
+	memory.Unpin("pin")
+	s.True(memory.Flush())
+	s.False(memory.Has("pin"))
+
+	memory.Pin("pin")
+	s.Nil(memory.Put("pin", "Rat"))
+	s.True(memory.ForceFlush())
+	s.False(memory.Has("pin"))
+}
+
+func (s *MemoryTestSuite) TestJanitor() {
+	// Wrapping the clock keeps it from flushing the items itself when advanced.
+	clock := NewFakeClock(time.Now())
+	var paused atomic.Bool
+	memory := NewMemory(WithClock(struct{ Clock }{clock}), WithJanitor(JanitorConfig{
+		Interval: 10 * time.Millisecond,
+		MaxKeys:  1,
+		Pause:    paused.Load,
+	}))
+	count := func() int {
+		var n int
+		memory.items().m.Range(func(any, any) bool {
+			n++
+			return true
+		})
+		return n
+	}
+	s.Nil(memory.Put("janitor-1", "Rat", WithTTL(1*time.Second)))
+	s.Nil(memory.Put("janitor-2", "Rat", WithTTL(1*time.Second)))
+
+	paused.Store(true)
+	clock.Advance(2 * time.Second)
+	time.Sleep(50 * time.Millisecond)
+	s.Equal(2, count())
+
+	paused.Store(false)
+	s.Eventually(func() bool {
+		return count() == 0
+	}, 1*time.Second, 10*time.Millisecond)
+
+	// Sweeps resume where the last one stopped, live items don't hide those behind them.
+	for i := 0; i < 5; i++ {
+		s.Nil(memory.Put(fmt.Sprintf("janitor-live-%d", i), "Rat"))
+	}
+	s.Nil(memory.Put("janitor-3", "Rat", WithTTL(1*time.Second)))
+	clock.Advance(2 * time.Second)
+	s.Eventually(func() bool {
+		return count() == 5
+	}, 1*time.Second, 10*time.Millisecond)
+	s.Nil(memory.Close(context.Background()))
+
+	s.Panics(func() {
+		WithJanitor(JanitorConfig{})
+	})
+}
+
+func (s *MemoryTestSuite) TestStats() {
+	clock := NewFakeClock(time.Now())
+	memory := NewMemory(WithClock(clock), WithMaxCost(2))
+	s.Nil(memory.Put("stats-1", "Rat", WithTTL(1*time.Second)))
+	s.Equal("Rat", memory.Get("stats-1"))
+	s.Nil(memory.Get("stats-2"))
+	_, err := memory.Remember("stats-2", 1*time.Second, func() (any, error) {
+		return "Go", nil
+	})
+	s.Nil(err)
+
+	prev := memory.Stats()
+	s.Equal(Stats{At: clock.Now(), Hits: 1, Misses: 2, Writes: 2, Items: 2, Cost: 2, Bytes: prev.Bytes}, prev)
+	s.Equal(float64(1)/3, prev.HitRatio())
+
+	clock.Advance(2 * time.Second)
+	s.Nil(memory.Put("stats-3", "Rat"))
+	s.Nil(memory.Put("stats-4", "Rat"))
+	s.Nil(memory.Put("stats-5", "Rat"))
+	s.True(memory.Forget("stats-5"))
+
+	delta := memory.Stats().Delta(prev)
+	s.Equal(Stats{
+		At:          clock.Now(),
+		Interval:    2 * time.Second,
+		Writes:      3,
+		Deletes:     1,
+		Evictions:   1,
+		Expirations: 2,
+		Items:       1,
+		Cost:        1,
+		Bytes:       delta.Bytes,
+	}, delta)
+	s.Zero(delta.HitRatio())
+
+	// Snapshots don't reset anything.
+	s.Equal(uint64(1), memory.Stats().Hits)
+}
+
+func (s *MemoryTestSuite) TestExport() {
+	s.Nil(s.memory.Put("export-hot", "Rat", WithTTL(1*time.Minute)))
+	s.Nil(s.memory.Put("export-cold", 1))
+	s.Nil(s.memory.Put("export-unencodable", func() {}))
+	for i := 0; i < 3; i++ {
+		s.memory.Get("export-hot")
+	}
+
+	var buf bytes.Buffer
+	n, err := s.memory.Export(&buf, GobCodec, 0)
+	s.Nil(err)
+	s.Equal(2, n)
+
+	memory := NewMemory()
+	s.Nil(memory.Put("export-cold", 2))
+	n, err = memory.Import(&buf, GobCodec)
+	s.Nil(err)
+	s.Equal(1, n)
+	s.Equal("Rat", memory.Get("export-hot"))
+	s.Equal(2, memory.Get("export-cold"))
+	info, _ := memory.Inspect("export-hot")
+	s.InDelta(float64(1*time.Minute), float64(info.TTL), float64(time.Second))
+
+	// Peers warm up over HTTP from the most read items.
+	authorize := func(req *http.Request) bool {
+		return req.Header.Get("Authorization") == "Bearer secret"
+	}
+	token := WithWarmUpRequest(func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer secret")
+	})
+	server := httptest.NewServer(ExportHandler(s.memory, GobCodec, 1, authorize, nil))
+	defer server.Close()
+	memory = NewMemory()
+	n, err = WarmUp(context.Background(), memory, server.URL, GobCodec, token, WithWarmUpClient(server.Client()))
+	s.Nil(err)
+	s.Equal(1, n)
+	s.Equal("Rat", memory.Get("export-hot"))
+	s.False(memory.Has("export-cold"))
+	_, err = WarmUp(context.Background(), NewMemory(), server.URL, GobCodec)
+	s.ErrorContains(err, "403 Forbidden")
+
+	// Only the items matching are served, and nothing without an authorizer.
+	filtered := httptest.NewServer(ExportHandler(s.memory, GobCodec, 0, authorize, func(key string) bool {
+		return key == "export-cold"
+	}))
+	defer filtered.Close()
+	memory = NewMemory()
+	n, err = WarmUp(context.Background(), memory, filtered.URL, GobCodec, token)
+	s.Nil(err)
+	s.Equal(1, n)
+	s.True(memory.Has("export-cold"))
+	open := httptest.NewServer(ExportHandler(s.memory, GobCodec, 0, nil, nil))
+	defer open.Close()
+	_, err = WarmUp(context.Background(), NewMemory(), open.URL, GobCodec)
+	s.ErrorContains(err, "403 Forbidden")
+
+	_, err = memory.Import(strings.NewReader("\x05Rat"), GobCodec)
+	s.ErrorIs(err, io.ErrUnexpectedEOF)
+	_, err = memory.Import(strings.NewReader("\xff\xff\xff\xff\x0f"), GobCodec)
+	s.ErrorIs(err, ErrRecordTooLarge)
+}
+
+func (s *MemoryTestSuite) TestScope() {
+	s.Nil(s.memory.Put("scope-parent", "Rat"))
+	scope := s.memory.Scope("job")
+	s.Nil(scope.Put("item", "Rat"))
+	_, err := scope.Increment("counter")
+	s.Nil(err)
+	s.True(scope.Lock("lock").Get())
+	s.Equal("Rat", scope.Get("item"))
+	s.Equal("Rat", s.memory.Get("job:item"))
+	s.Nil(scope.Get("scope-parent"))
+	s.Equal(4, scope.Keys())
+
+	s.Equal("Rat", scope.Pull("item"))
+	s.Equal(3, scope.Keys())
+
+	s.Nil(scope.WithContext(context.Background()).Put("other", "Go"))
+	s.True(scope.Flush())
+	s.False(s.memory.Has("job:other"))
+	s.Equal("Rat", s.memory.Get("scope-parent"))
+
+	s.Nil(scope.Put("item", "Rat"))
+	s.Nil(scope.Close(context.Background()))
+	s.False(s.memory.Has("job:item"))
+	s.Zero(scope.Keys())
+	s.True(s.memory.Has("scope-parent"))
+}
+
+func (s *MemoryTestSuite) TestTransform() {
+	type user struct {
+		Name     string
+		Password string
+		Greeting string
+	}
+	store := Use(s.memory, Transform(func(key string, value any) (any, error) {
+		if u, ok := value.(user); ok {
+			u.Password = ""
+			return u, nil
+		}
+		if _, ok := value.(func()); ok {
+			return nil, errors.New("unsupported")
+		}
+		return value, nil
+	}, func(key string, value any) (any, error) {
+		if u, ok := value.(user); ok {
+			u.Greeting = "Hello " + u.Name
+			return u, nil
+		}
+		return value, nil
+	}))
+
+	s.Nil(store.Put("transform", user{Name: "Rat", Password: "secret"}))
+	s.Equal(user{Name: "Rat"}, s.memory.Get("transform"))
+	s.Equal(user{Name: "Rat", Greeting: "Hello Rat"}, store.Get("transform"))
+	val, exist := store.GetExists("transform")
+	s.True(exist)
+	s.Equal(user{Name: "Rat", Greeting: "Hello Rat"}, val)
+
+	val, err := store.Remember("transform-remember", 1*time.Second, func() (any, error) {
+		return user{Name: "Go", Password: "secret"}, nil
+	})
+	s.Nil(err)
+	s.Equal(user{Name: "Go", Greeting: "Hello Go"}, val)
+	s.Equal(user{Name: "Go"}, s.memory.Get("transform-remember"))
+
+	s.EqualError(store.Put("transform-func", func() {}), "unsupported")
+	s.False(store.Add("transform-func", func() {}, 1*time.Second))
+	s.False(s.memory.Has("transform-func"))
+}
+
+func (s *MemoryTestSuite) TestRememberWithTTL() {
+	val, err := RememberWithTTL(s.memory, "remember-ttl", func() (any, time.Duration, error) {
+		return "Rat", 1 * time.Minute, nil
+	})
+	s.Nil(err)
+	s.Equal("Rat", val)
+	info, _ := s.memory.Inspect("remember-ttl")
+	s.InDelta(float64(1*time.Minute), float64(info.TTL), float64(time.Second))
+
+	val, err = RememberWithTTL(s.memory, "remember-ttl", func() (any, time.Duration, error) {
+		return "Go", 1 * time.Minute, nil
+	})
+	s.Nil(err)
+	s.Equal("Rat", val)
+
+	val, err = RememberWithTTL(s.memory, "remember-ttl-no-store", func() (any, time.Duration, error) {
+		return "Rat", -1, nil
+	})
+	s.Nil(err)
+	s.Equal("Rat", val)
+	s.False(s.memory.Has("remember-ttl-no-store"))
+	_, err = RememberWithTTL(s.memory, "remember-ttl-no-store", func() (any, time.Duration, error) {
+		return "Rat", 0, nil
+	})
+	s.Nil(err)
+	s.False(s.memory.Has("remember-ttl-no-store"))
+	_, err = RememberWithTTL(s.memory, "remember-ttl-forever", func() (any, time.Duration, error) {
+		return "Rat", KeepForever, nil
+	})
+	s.Nil(err)
+	info, _ = s.memory.Inspect("remember-ttl-forever")
+	s.Equal(NoExpiration, info.TTL)
+
+	_, err = RememberWithTTL(s.memory, "remember-ttl-error", func() (any, time.Duration, error) {
+		return nil, 0, errors.New("error")
+	})
+	s.EqualError(err, "error")
+	_, err = RememberWithTTL(s.memory, "remember-ttl-error", func() (any, time.Duration, error) {
+		panic("panic")
+	})
+	var panicErr *PanicError
+	s.ErrorAs(err, &panicErr)
+	s.False(s.memory.Has("remember-ttl-error"))
+}
+
+func (s *MemoryTestSuite) TestUpdate() {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Nil(Update(s.memory, "update", func(old any, exists bool) (any, bool) {
+				if !exists {
+					return []int{1}, true
+				}
+				return append(slices.Clone(old.([]int)), len(old.([]int))+1), true
+			}))
+		}()
+	}
+	wg.Wait()
+	s.Len(s.memory.Get("update"), 20)
+
+	s.Nil(Update(s.memory, "update", func(old any, exists bool) (any, bool) {
+		return nil, false
+	}))
+	s.Len(s.memory.Get("update"), 20)
+
+	s.Nil(Update(s.memory, "update-ttl", func(old any, exists bool) (any, bool) {
+		s.False(exists)
+		return "Rat", true
+	}, WithTTL(1*time.Minute)))
+	info, _ := s.memory.Inspect("update-ttl")
+	s.InDelta(float64(1*time.Minute), float64(info.TTL), float64(time.Second))
+}
+
+func (s *MemoryTestSuite) TestAppend() {
+	var appender Appender = s.memory
+	n, err := appender.Append("append", "Go")
+	s.Nil(err)
+	s.Equal(2, n)
+	n, err = appender.Prepend("append", "Rat ")
+	s.Nil(err)
+	s.Equal(6, n)
+	n, err = appender.Append("append", "!")
+	s.Nil(err)
+	s.Equal(7, n)
+	s.Equal("Rat Go!", s.memory.Get("append"))
+
+	s.Nil(s.memory.Put("append-bytes", []byte("b"), WithTTL(1*time.Minute)))
+	_, err = s.memory.Append("append-bytes", "c")
+	s.Nil(err)
+	n, err = s.memory.Prepend("append-bytes", "a")
+	s.Nil(err)
+	s.Equal(3, n)
+	s.Equal([]byte("abc"), s.memory.Get("append-bytes"))
+	info, _ := s.memory.Inspect("append-bytes")
+	s.InDelta(float64(1*time.Minute), float64(info.TTL), float64(time.Second))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.memory.Append("append-bytes", "x")
+			s.Nil(err)
+		}()
+	}
+	wg.Wait()
+	s.Len(s.memory.Get("append-bytes"), 103)
+
+	s.Nil(s.memory.Put("append-int", 1))
+	_, err = s.memory.Append("append-int", "1")
+	s.EqualError(err, "cache: value of key append-int is int, not a string")
+}
+
+func (s *MemoryTestSuite) TestBitmap() {
+	var bitmap Bitmapper = s.memory
+	prev, err := bitmap.SetBit("bitmap", 0, true)
+	s.Nil(err)
+	s.False(prev)
+	_, err = bitmap.SetBit("bitmap", 10, true)
+	s.Nil(err)
+	s.Equal([]byte{0x80, 0x20}, s.memory.Get("bitmap"))
+
+	before := s.memory.Get("bitmap").([]byte)
+	prev, err = bitmap.SetBit("bitmap", 10, false)
+	s.Nil(err)
+	s.True(prev)
+	s.Equal([]byte{0x80, 0x20}, before)
+
+	bit, err := bitmap.GetBit("bitmap", 0)
+	s.Nil(err)
+	s.True(bit)
+	bit, err = bitmap.GetBit("bitmap", 100)
+	s.Nil(err)
+	s.False(bit)
+	n, err := bitmap.BitCount("bitmap")
+	s.Nil(err)
+	s.Equal(int64(1), n)
+	n, err = bitmap.BitCount("bitmap-missing")
+	s.Nil(err)
+	s.Zero(n)
+
+	_, err = bitmap.SetBit("bitmap", -1, true)
+	s.Error(err)
+	s.Nil(s.memory.Put("bitmap-int", 1))
+	_, err = bitmap.GetBit("bitmap-int", 0)
+	s.EqualError(err, "cache: value of key bitmap-int is int, not a bitmap")
+}
+
+func (s *MemoryTestSuite) TestHyperLogLog() {
+	var counter DistinctCounter = s.memory
+	for i := 0; i < 10000; i++ {
+		_, err := counter.PFAdd("hll-1", fmt.Sprintf("user-%d", i))
+		s.Nil(err)
+	}
+	changed, err := counter.PFAdd("hll-1", "user-1")
+	s.Nil(err)
+	s.False(changed)
+	for i := 5000; i < 20000; i++ {
+		_, err := counter.PFAdd("hll-2", fmt.Sprintf("user-%d", i))
+		s.Nil(err)
+	}
+
+	n, err := counter.PFCount("hll-1")
+	s.Nil(err)
+	s.InEpsilon(10000, n, 0.03)
+	n, err = counter.PFCount("hll-1", "hll-2", "hll-missing")
+	s.Nil(err)
+	s.InEpsilon(20000, n, 0.03)
+	n, err = counter.PFCount("hll-missing")
+	s.Nil(err)
+	s.Zero(n)
+
+	// Counters survive a codec round trip.
+	data, err := GobCodec.Marshal(s.memory.Get("hll-1"))
+	s.Nil(err)
+	decoded := NewHyperLogLog()
+	s.Nil(GobCodec.Unmarshal(data, decoded))
+	s.Equal(s.memory.Get("hll-1").(*HyperLogLog).Count(), decoded.Count())
+
+	s.Nil(s.memory.Put("hll-int", 1))
+	_, err = counter.PFAdd("hll-int", "user")
+	s.EqualError(err, "cache: value of key hll-int is int, not *cache.HyperLogLog")
+}
+
+func (s *MemoryTestSuite) TestSortedSet() {
+	var sets SortedSetter = s.memory
+	n, err := sets.ZAdd("board", ZMember{"alice", 30}, ZMember{"bob", 10}, ZMember{"carol", 20}, ZMember{"dave", 20})
+	s.Nil(err)
+	s.Equal(4, n)
+	n, err = sets.ZAdd("board", ZMember{"bob", 40}, ZMember{"erin", 5})
+	s.Nil(err)
+	s.Equal(1, n)
+
+	members, err := sets.ZRange("board", 0, -1)
+	s.Nil(err)
+	s.Equal([]ZMember{{"erin", 5}, {"carol", 20}, {"dave", 20}, {"alice", 30}, {"bob", 40}}, members)
+	members, err = sets.ZRevRange("board", 0, 2)
+	s.Nil(err)
+	s.Equal([]ZMember{{"bob", 40}, {"alice", 30}, {"dave", 20}}, members)
+	members, err = sets.ZRange("board", -2, 10)
+	s.Nil(err)
+	s.Equal([]ZMember{{"alice", 30}, {"bob", 40}}, members)
+	members, err = sets.ZRange("board", 3, 1)
+	s.Nil(err)
+	s.Empty(members)
+
+	score, ok, err := sets.ZScore("board", "bob")
+	s.Nil(err)
+	s.True(ok)
+	s.Equal(40.0, score)
+	_, ok, err = sets.ZScore("board", "frank")
+	s.Nil(err)
+	s.False(ok)
+
+	n, err = sets.ZRem("board", "bob", "frank")
+	s.Nil(err)
+	s.Equal(1, n)
+	card, err := sets.ZCard("board")
+	s.Nil(err)
+	s.Equal(int64(4), card)
+	card, err = sets.ZCard("board-missing")
+	s.Nil(err)
+	s.Zero(card)
+
+	// Sets survive a codec round trip.
+	data, err := GobCodec.Marshal(s.memory.Get("board"))
+	s.Nil(err)
+	decoded := NewSortedSet()
+	s.Nil(GobCodec.Unmarshal(data, decoded))
+	s.Equal(s.memory.Get("board").(*SortedSet).Range(0, -1), decoded.Range(0, -1))
+
+	s.Nil(s.memory.Put("board-int", 1))
+	_, err = sets.ZAdd("board-int", ZMember{"alice", 1})
+	s.EqualError(err, "cache: value of key board-int is int, not *cache.SortedSet")
+}
+
+func (s *MemoryTestSuite) TestSortedSetMany() {
+	set := NewSortedSet()
+	scores := make(map[string]float64)
+	for i := 0; i < 2000; i++ {
+		member := fmt.Sprintf("m-%d", rand.IntN(500))
+		score := float64(rand.IntN(100))
+		set.Add(ZMember{member, score})
+		scores[member] = score
+		if i%3 == 0 {
+			victim := fmt.Sprintf("m-%d", rand.IntN(500))
+			set.Remove(victim)
+			delete(scores, victim)
+		}
+	}
+
+	members := set.Range(0, -1)
+	s.Len(members, len(scores))
+	s.True(slices.IsSortedFunc(members, func(a, b ZMember) int {
+		if a.Score != b.Score {
+			return int(a.Score - b.Score)
+		}
+		return strings.Compare(a.Member, b.Member)
+	}))
+	for _, member := range members {
+		s.Equal(scores[member.Member], member.Score)
+	}
+	reversed := set.RevRange(0, -1)
+	slices.Reverse(reversed)
+	s.Equal(members, reversed)
+}
+
+func (s *MemoryTestSuite) TestGeo() {
+	var geo Geoer = s.memory
+	n, err := geo.GeoAdd("stores",
+		GeoLocation{Name: "palermo", Longitude: 13.361389, Latitude: 38.115556},
+		GeoLocation{Name: "catania", Longitude: 15.087269, Latitude: 37.502669},
+		GeoLocation{Name: "rome", Longitude: 12.496366, Latitude: 41.902782},
+	)
+	s.Nil(err)
+	s.Equal(3, n)
+
+	pos, ok, err := geo.GeoPos("stores", "palermo")
+	s.Nil(err)
+	s.True(ok)
+	s.InDelta(13.361389, pos.Longitude, 0.00001)
+	s.InDelta(38.115556, pos.Latitude, 0.00001)
+	_, ok, err = geo.GeoPos("stores", "milan")
+	s.Nil(err)
+	s.False(ok)
+
+	dist, ok, err := geo.GeoDist("stores", "palermo", "catania")
+	s.Nil(err)
+	s.True(ok)
+	s.InDelta(166274.15, dist, 1)
+
+	found, err := geo.GeoRadius("stores", 15, 37, 200000, 0)
+	s.Nil(err)
+	s.Len(found, 2)
+	s.Equal("catania", found[0].Name)
+	s.InDelta(56441, found[0].Dist, 1)
+	s.Equal("palermo", found[1].Name)
+	found, err = geo.GeoRadius("stores", 15, 37, 1000000, 1)
+	s.Nil(err)
+	s.Len(found, 1)
+	s.Equal("catania", found[0].Name)
+	found, err = geo.GeoRadius("stores-missing", 15, 37, 1000000, 0)
+	s.Nil(err)
+	s.Empty(found)
+
+	_, err = geo.GeoAdd("stores", GeoLocation{Name: "pole", Longitude: 0, Latitude: 90})
+	s.EqualError(err, "cache: invalid coordinates")
+}
+
+func (s *MemoryTestSuite) TestStream() {
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 50000)
+
+	var streamer Streamer = s.memory
+	s.Nil(streamer.PutReader("pdf", bytes.NewReader(payload), 1*time.Second))
+	rd, ok := GetReader(s.memory, "pdf")
+	s.True(ok)
+	data, err := io.ReadAll(rd)
+	s.Nil(err)
+	s.Equal(payload, data)
+	s.Nil(rd.Close())
+
+	// Caches that aren't a Streamer get chunks.
+	wrapped := Use(s.memory)
+	s.Nil(PutReader(wrapped, "image", bytes.NewReader(payload), 1*time.Second))
+	manifest, ok := s.memory.Get("image").(streamManifest)
+	s.True(ok)
+	s.Equal(4, manifest.Chunks)
+	s.Equal(int64(len(payload)), manifest.Size)
+	rd, ok = GetReader(wrapped, "image")
+	s.True(ok)
+	data, err = io.ReadAll(rd)
+	s.Nil(err)
+	s.Equal(payload, data)
+
+	// Rewriting drops the old chunks.
+	s.Nil(PutReader(wrapped, "image", strings.NewReader("small"), 1*time.Second))
+	s.False(s.memory.Has(manifest.chunk("image", 0)))
+	rd, ok = GetReader(wrapped, "image")
+	s.True(ok)
+	data, err = io.ReadAll(rd)
+	s.Nil(err)
+	s.Equal("small", string(data))
+
+	s.Nil(PutReader(wrapped, "image", bytes.NewReader(payload), 1*time.Second))
+	manifest = s.memory.Get("image").(streamManifest)
+	rd, _ = GetReader(wrapped, "image")
+	s.True(s.memory.Forget(manifest.chunk("image", 2)))
+	_, err = io.ReadAll(rd)
+	s.ErrorIs(err, ErrChunkMissing)
+
+	_, ok = GetReader(wrapped, "image-missing")
+	s.False(ok)
+}
+
+func (s *MemoryTestSuite) TestChunking() {
+	chunked := Use(s.memory, Chunking(1000))
+	payload := bytes.Repeat([]byte("0123456789"), 350)
+
+	s.Nil(chunked.Put("blob", payload, WithTTL(1*time.Second)))
+	manifest, ok := s.memory.Get("blob").(streamManifest)
+	s.True(ok)
+	s.Equal(4, manifest.Chunks)
+	s.Len(s.memory.Get(manifest.chunk("blob", 0)), 1000)
+	s.Equal(payload, chunked.Get("blob"))
+
+	// Strings come back as strings, small values are stored as is.
+	s.True(chunked.Add("text", strings.Repeat("a", 2500), 1*time.Second))
+	s.Equal(strings.Repeat("a", 2500), chunked.Get("text"))
+	s.False(chunked.Add("text", strings.Repeat("b", 2500), 1*time.Second))
+	s.True(chunked.Forever("small", "a"))
+	s.Equal("a", s.memory.Get("small"))
+
+	// Overwriting and forgetting drop the chunks.
+	s.Nil(chunked.Put("blob", []byte("small"), WithTTL(1*time.Second)))
+	s.False(s.memory.Has(manifest.chunk("blob", 0)))
+	s.Equal([]byte("small"), chunked.Get("blob"))
+	manifest = s.memory.Get("text").(streamManifest)
+	s.True(chunked.Forget("text"))
+	s.False(s.memory.Has(manifest.chunk("text", 2)))
+
+	// A missing or overwritten chunk makes the value a miss.
+	s.Nil(chunked.Put("blob", payload))
+	manifest = s.memory.Get("blob").(streamManifest)
+	s.Nil(s.memory.Put(manifest.chunk("blob", 1), bytes.Repeat([]byte("x"), 1000)))
+	s.Nil(chunked.Get("blob"))
+	s.True(s.memory.Forget(manifest.chunk("blob", 3)))
+	_, exist := chunked.GetExists("blob")
+	s.False(exist)
+
+	// Remember chunks what the callback returns.
+	var calls int
+	callback := func() (any, error) {
+		calls++
+		return payload, nil
+	}
+	val, err := chunked.Remember("remembered", 1*time.Second, callback)
+	s.Nil(err)
+	s.Equal(payload, val)
+	_, ok = s.memory.Get("remembered").(streamManifest)
+	s.True(ok)
+	val, err = chunked.Remember("remembered", 1*time.Second, callback)
+	s.Nil(err)
+	s.Equal(payload, val)
+	s.Equal(1, calls)
+	manifest = s.memory.Get("remembered").(streamManifest)
+	s.True(s.memory.Forget(manifest.chunk("remembered", 0)))
+	val, err = chunked.Remember("remembered", 1*time.Second, callback)
+	s.Nil(err)
+	s.Equal(payload, val)
+	s.Equal(2, calls)
+	s.Equal(payload, chunked.Pull("remembered"))
+	s.False(s.memory.Has("remembered"))
+
+	// Values written by PutReader are read back too.
+	s.Nil(PutReader(Use(s.memory), "stream", bytes.NewReader(payload), 1*time.Second))
+	s.Equal(payload, chunked.Get("stream"))
+}
+
+func (s *MemoryTestSuite) TestRememberFile() {
+	path := filepath.Join(s.T().TempDir(), "config.json")
+	s.Nil(os.WriteFile(path, []byte(`{"a":1}`), 0o600))
+
+	var calls int
+	parse := func() (any, error) {
+		calls++
+		data, err := os.ReadFile(path)
+		return string(data), err
+	}
+
+	val, err := RememberFile(s.memory, path, parse)
+	s.Nil(err)
+	s.Equal(`{"a":1}`, val)
+	val, err = RememberFile(s.memory, path, parse)
+	s.Nil(err)
+	s.Equal(`{"a":1}`, val)
+	s.Equal(1, calls)
+
+	// Changing the file computes the result again.
+	s.Nil(os.WriteFile(path, []byte(`{"a":2}`), 0o600))
+	s.Nil(os.Chtimes(path, time.Now(), time.Now().Add(1*time.Second)))
+	val, err = RememberFile(s.memory, path, parse)
+	s.Nil(err)
+	s.Equal(`{"a":2}`, val)
+	s.Equal(2, calls)
+
+	s.Nil(os.Remove(path))
+	_, err = RememberFile(s.memory, path, parse)
+	s.ErrorIs(err, os.ErrNotExist)
+}
+
+func (s *MemoryTestSuite) TestFetchConditional() {
+	var downloads, version atomic.Int32
+	version.Store(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		etag := fmt.Sprintf(`"v%d"`, version.Load())
+		if req.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads.Add(1)
+		w.Header().Set("ETag", etag)
+		_, _ = fmt.Fprintf(w, "rates v%d", version.Load())
+	}))
+	defer server.Close()
+
+	res, err := FetchConditional(context.Background(), s.memory, server.URL)
+	s.Nil(err)
+	s.Equal("rates v1", string(res.Body))
+	s.Equal(`"v1"`, res.ETag)
+	s.True(res.Changed)
+	res, err = FetchConditional(context.Background(), s.memory, server.URL)
+	s.Nil(err)
+	s.Equal("rates v1", string(res.Body))
+	s.False(res.Changed)
+	s.Equal(int32(1), downloads.Load())
+
+	version.Store(2)
+	res, err = FetchConditional(context.Background(), s.memory, server.URL)
+	s.Nil(err)
+	s.Equal("rates v2", string(res.Body))
+	s.True(res.Changed)
+	s.Equal(int32(2), downloads.Load())
+
+	_, err = FetchConditional(context.Background(), s.memory, server.URL+"/%zz")
+	s.Error(err)
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	_, err = FetchConditional(context.Background(), s.memory, missing.URL)
+	s.ErrorContains(err, "404 Not Found")
+}
+
+func (s *MemoryTestSuite) TestAsMap() {
+	m := AsMap(s.memory)
+	m.Store("map-1", "Rat")
+	val, ok := m.Load("map-1")
+	s.True(ok)
+	s.Equal("Rat", val)
+
+	actual, loaded := m.LoadOrStore("map-1", "Go")
+	s.True(loaded)
+	s.Equal("Rat", actual)
+	actual, loaded = m.LoadOrStore("map-2", "Go")
+	s.False(loaded)
+	s.Equal("Go", actual)
+
+	val, ok = m.LoadAndDelete("map-2")
+	s.True(ok)
+	s.Equal("Go", val)
+	_, ok = m.LoadAndDelete("map-2")
+	s.False(ok)
+	m.Delete("map-1")
+	_, ok = m.Load("map-1")
+	s.False(ok)
+
+	s.memory.SetReadOnly(true)
+	defer s.memory.SetReadOnly(false)
+	actual, loaded = m.LoadOrStore("map-3", "Go")
+	s.False(loaded)
+	s.Equal("Go", actual)
+	_, ok = m.Load("map-3")
+	s.False(ok)
+}
+
+func (s *MemoryTestSuite) TestRoundTripper() {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		switch req.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private")
+		case "/no-cache":
+			w.Header().Set("Cache-Control", "no-cache")
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=0")
+		case "/expires":
+			w.Header().Set("Expires", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+		case "/shared":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/vary":
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		_, _ = fmt.Fprintf(w, "response %d", requests.Load())
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: RoundTripper(s.memory, nil, 1*time.Second)}
+	get := func(path string, header ...string) string {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		s.Require().Nil(err)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := client.Do(req)
+		s.Require().Nil(err)
+		defer resp.Body.Close()
+		s.Equal(http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		s.Nil(err)
+		return string(body)
+	}
+
+	s.Equal("response 1", get("/public"))
+	s.Equal("response 1", get("/public"))
+	s.Equal(int32(1), requests.Load())
+	s.Equal("response 2", get("/private"))
+	s.Equal("response 3", get("/private"))
+
+	resp, err := client.Post(server.URL+"/public", "text/plain", strings.NewReader("Rat"))
+	s.Nil(err)
+	s.Nil(resp.Body.Close())
+	s.Equal(int32(4), requests.Load())
+
+	// Responses to requests with credentials aren't shared unless public.
+	s.Equal("response 5", get("/public", "Authorization", "Bearer rat"))
+	s.Equal("response 6", get("/user", "Cookie", "session=rat"))
+	s.Equal("response 7", get("/user"))
+	s.Equal("response 8", get("/user", "Cookie", "session=go"))
+	s.Equal("response 9", get("/shared", "Authorization", "Bearer rat"))
+	s.Equal("response 9", get("/shared", "Authorization", "Bearer go"))
+
+	s.Equal("response 10", get("/no-cache"))
+	s.Equal("response 11", get("/no-cache"))
+	s.Equal("response 12", get("/max-age"))
+	s.Equal("response 13", get("/max-age"))
+	s.Equal("response 14", get("/expires"))
+	s.Equal("response 15", get("/expires"))
+	s.Equal("response 16", get("/public", "Cache-Control", "no-cache"))
+	s.Equal("response 16", get("/public"))
+
+	s.Equal("response 17", get("/vary", "Accept-Language", "en"))
+	s.Equal("response 18", get("/vary", "Accept-Language", "fr"))
+	s.Equal("response 17", get("/vary", "Accept-Language", "en"))
+}
+
+func (s *MemoryTestSuite) TestDo() {
+	var calls atomic.Int32
+	fn := func() (any, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return "Rat", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := s.memory.Do("do-1", fn)
+			s.Nil(err)
+			s.Equal("Rat", val)
+		}()
+	}
+	wg.Wait()
+	s.Equal(int32(1), calls.Load())
+	s.False(s.memory.Has("do-1"))
+
+	_, err := s.memory.Do("do-1", fn)
+	s.Nil(err)
+	s.Equal(int32(2), calls.Load())
+}
+
+func (s *MemoryTestSuite) TestDoDistributed() {
+	var calls atomic.Int32
+	fn := func() (any, error) {
+		calls.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		return "Rat", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := DoDistributed(s.memory.WithContext(context.Background()), "do-2", 1*time.Second, fn)
+			s.Nil(err)
+			s.Equal("Rat", val)
+		}()
+	}
+	wg.Wait()
+	s.Equal(int32(1), calls.Load())
+
+	_, err := DoDistributed(s.memory, "do-2", 1*time.Second, fn)
+	s.Nil(err)
+	s.Equal(int32(2), calls.Load())
+
+	_, err = DoDistributed(s.memory, "do-3", 1*time.Second, func() (any, error) {
+		return nil, errors.New("upstream down")
+	})
+	s.EqualError(err, "upstream down")
+	s.False(s.memory.Has("do-3:do"))
+
+	// Callers arriving after an earlier call wait for the running one, not the earlier result.
+	_, err = DoDistributed(s.memory, "do-4", 1*time.Second, func() (any, error) {
+		return "old", nil
+	})
+	s.Nil(err)
+	started := make(chan struct{})
+	go func() {
+		_, _ = DoDistributed(s.memory, "do-4", 1*time.Second, func() (any, error) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			return "new", nil
+		})
+	}()
+	<-started
+	val, err := DoDistributed(s.memory, "do-4", 1*time.Second, fn)
+	s.Nil(err)
+	s.Equal("new", val)
+
+	s.memory.SetReadOnly(true)
+	defer s.memory.SetReadOnly(false)
+	_, err = DoDistributed(s.memory, "do-5", 1*time.Second, fn)
+	s.ErrorContains(err, "can't be stored")
+}
+
+func (s *MemoryTestSuite) TestFromContext() {
+	_, ok := FromContext(context.Background())
+	s.False(ok)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(IntoContext(context.Background(), s.memory), ctxKey{}, "request")
+	store, ok := FromContext(ctx)
+	s.True(ok)
+	s.Nil(store.Put("ctx-1", "Rat"))
+	s.Equal("Rat", s.memory.Get("ctx-1"))
+
+	// The store is bound to the context it came from.
+	s.Equal("request", store.(*Memory).ctx.Value(ctxKey{}))
+}
+
+func (s *MemoryTestSuite) TestDefault() {
+	defer SetDefault(nil)
+
+	_, err := Default()
+	s.ErrorIs(err, ErrNoDefault)
+
+	SetDefault(s.memory)
+	store, err := Default()
+	s.Nil(err)
+	s.Same(s.memory, store)
+
+	SetDefault(nil)
+	_, err = Default()
+	s.ErrorIs(err, ErrNoDefault)
+}
+
+func (s *MemoryTestSuite) TestServeStale() {
+	var failures []string
+	stale := Use(s.memory, ServeStale(NoExpiration, func(key string, err error) {
+		failures = append(failures, key+": "+err.Error())
+	}))
+
+	val, err := stale.Remember("rates", 50*time.Millisecond, func() (any, error) {
+		return "rates v1", nil
+	})
+	s.Nil(err)
+	s.Equal("rates v1", val)
+
+	time.Sleep(100 * time.Millisecond)
+	s.False(s.memory.Has("rates"))
+	down := func() (any, error) {
+		return nil, errors.New("upstream down")
+	}
+	val, err = stale.Remember("rates", 50*time.Millisecond, down)
+	s.Nil(err)
+	s.Equal("rates v1", val)
+	s.Equal([]string{"rates: upstream down"}, failures)
+	s.False(s.memory.Has("rates"))
+
+	// Without a copy the error goes through.
+	_, err = stale.RememberForever("other", down)
+	s.EqualError(err, "upstream down")
+	s.Len(failures, 1)
+}
+
+func (s *MemoryTestSuite) TestPutWithValidator() {
+	var revoked atomic.Bool
+	valid := func(value any) bool {
+		return !revoked.Load() && value.(string) == "token"
+	}
+
+	s.Nil(s.memory.PutWithValidator("token-1", "token", 1*time.Second, valid))
+	s.Equal("token", s.memory.Get("token-1"))
+	s.True(s.memory.Has("token-1"))
+	s.False(s.memory.Add("token-1", "other", 1*time.Second))
+
+	revoked.Store(true)
+	s.Nil(s.memory.Get("token-1"))
+	_, exist := s.memory.GetExists("token-1")
+	s.False(exist)
+
+	// Invalid items don't block Add, which stores an item without validator.
+	s.Nil(s.memory.PutWithValidator("token-2", "token", 1*time.Second, valid))
+	s.True(s.memory.Add("token-2", "other", 1*time.Second))
+	s.Equal("other", s.memory.Get("token-2"))
+}
+
+func (s *MemoryTestSuite) TestKeyOf() {
+	type search struct {
+		Query   string
+		Page    int
+		Filters map[string]string
+	}
+	type other struct {
+		Query   string
+		Page    int
+		Filters map[string]string
+	}
+
+	a := search{Query: "rat", Page: 1, Filters: map[string]string{"lang": "go", "sort": "stars"}}
+	b := search{Query: "rat", Page: 1, Filters: map[string]string{"sort": "stars", "lang": "go"}}
+	s.Equal(KeyOf(a), KeyOf(b))
+	s.Len(KeyOf(a), 64)
+	s.Equal("f70b1d58e4a3abd7ee7e35ebbd9209e7886d50bb197e58c3e97dee01385092c8", KeyOf(1))
+
+	b.Page = 2
+	s.NotEqual(KeyOf(a), KeyOf(b))
+	s.NotEqual(KeyOf(a), KeyOf(other(a)))
+	s.NotEqual(KeyOf(1), KeyOf("1"))
+	s.Len(KeyOf(func() {}), 64)
+}
+
+func (s *MemoryTestSuite) TestKeyPolicy() {
+	var violations []string
+	guarded := Use(s.memory, WithKeyPolicy(KeyPolicy{
+		Prefix:  "billing:",
+		Allow:   regexp.MustCompile(`^[a-z:0-9]+$`),
+		MaxKeys: 100,
+		OnViolation: func(key string, err error) {
+			violations = append(violations, err.Error())
+		},
+	}))
+
+	s.Nil(guarded.Put("billing:invoice:1", "Rat"))
+	s.ErrorIs(guarded.Put("orders:1", "Rat"), ErrKeyPolicy)
+	s.False(guarded.Add("billing:Invoice", "Rat", 1*time.Second))
+	_, err := guarded.Increment("orders:count")
+	s.ErrorIs(err, ErrKeyPolicy)
+	_, err = guarded.Remember("orders:2", 1*time.Second, func() (any, error) {
+		return "Rat", nil
+	})
+	s.ErrorIs(err, ErrKeyPolicy)
+	s.False(s.memory.Has("orders:1"))
+	s.False(s.memory.Has("orders:2"))
+	s.Equal("Rat", guarded.Get("billing:invoice:1"))
+	s.Equal([]string{
+		"cache: key violates the key policy: orders:1 doesn't start with billing:",
+		"cache: key violates the key policy: billing:Invoice doesn't match ^[a-z:0-9]+$",
+		"cache: key violates the key policy: orders:count doesn't start with billing:",
+		"cache: key violates the key policy: orders:2 doesn't start with billing:",
+	}, violations)
+
+	// Going over the max keys raises the alarm once.
+	violations = nil
+	for i := 0; i < 200; i++ {
+		s.Nil(guarded.Put(fmt.Sprintf("billing:request:%d", i), i))
+	}
+	s.Equal([]string{"cache: key violates the key policy: more than 100 keys written"}, violations)
+
+	// Warnings let the writes through.
+	warned := Use(s.memory, WithKeyPolicy(KeyPolicy{Prefix: "billing:", Warn: true}))
+	s.Nil(warned.Put("orders:1", "Rat"))
+	s.True(s.memory.Has("orders:1"))
+}
+
+func (s *MemoryTestSuite) TestKeyAnalytics() {
+	s.Equal("user:*:session:*", KeyPattern("user:42:session:9f86d081884c7d65"))
+	s.Equal("order:*", KeyPattern("order:123e4567-e89b-12d3-a456-426614174000"))
+	s.Equal("config", KeyPattern("config"))
+
+	memory := NewMemory(WithKeyAnalytics())
+	for i := 0; i < 5; i++ {
+		s.Nil(memory.Put(fmt.Sprintf("user:%d", i), "Rat"))
+	}
+	s.Nil(memory.Put("config", "Rat"))
+	s.Nil(memory.Put("request:123e4567-e89b-12d3-a456-426614174000", "Rat"))
+	s.Nil(memory.Put("request:223e4567-e89b-12d3-a456-426614174000", "Rat"))
+
+	s.Equal([]PatternCount{{"user:*", 5}, {"request:*", 2}, {"config", 1}}, memory.Stats().Patterns)
+	s.Nil(s.memory.Put("user:1", "Rat"))
+	s.Nil(s.memory.Stats().Patterns)
+
+	// The alarm is raised once per pattern going over the threshold.
+	var alarms []PatternCount
+	memory = NewMemory(WithCardinalityAlarm(2, func(count PatternCount) {
+		alarms = append(alarms, count)
+	}))
+	for i := 0; i < 3; i++ {
+		s.Nil(memory.Put(fmt.Sprintf("user:%d", i), "Rat"))
+	}
+	s.Nil(memory.Put("config", "Rat"))
+	s.Len(memory.Stats().Patterns, 2)
+	memory.Stats()
+	s.Equal([]PatternCount{{"user:*", 3}}, alarms)
+	s.True(memory.Forget("user:0"))
+	memory.Stats()
+	s.Nil(memory.Put("user:0", "Rat"))
+	memory.Stats()
+	s.Equal([]PatternCount{{"user:*", 3}, {"user:*", 3}}, alarms)
+}
+
+func (s *MemoryTestSuite) TestCounterSeries() {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	series := CounterSeries(s.memory, "requests", time.Minute, time.Hour)
+	series.clock = clock
+
+	n, err := series.Add(1)
+	s.Nil(err)
+	s.Equal(int64(1), n)
+	n, err = series.Add(2)
+	s.Nil(err)
+	s.Equal(int64(3), n)
+
+	clock.Advance(time.Minute)
+	_, err = series.Add(5)
+	s.Nil(err)
+	clock.Advance(2 * time.Minute)
+	_, err = series.Add(1)
+	s.Nil(err)
+
+	s.Equal([]int64{3, 5, 0, 1}, series.Counts(4*time.Minute))
+	s.Equal(int64(9), series.Sum(time.Hour))
+	s.Equal(int64(1), series.Sum(time.Minute))
+	s.Equal(int64(1), series.Sum(0))
+
+	// Buckets keep the expiration they were created with.
+	_, err = series.Add(1)
+	s.Nil(err)
+	info, ok := s.memory.Inspect(fmt.Sprintf("requests:%d", clock.Now().Unix()/60))
+	s.True(ok)
+	s.InDelta(float64(time.Hour), float64(info.TTL), float64(time.Second))
+	s.Equal(int64(2), s.memory.GetInt64(fmt.Sprintf("requests:%d", clock.Now().Unix()/60)))
+}
+
+func (s *MemoryTestSuite) TestUniqueCounter() {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	for _, instance := range []Cache{s.memory, Use(s.memory)} {
+		active := UniqueCounter(instance, fmt.Sprintf("active-%T", instance), time.Minute, 15*time.Minute)
+		active.series.clock = clock
+
+		for i := 0; i < 1000; i++ {
+			s.Nil(active.Add(fmt.Sprintf("user-%d", i)))
+		}
+		clock.Advance(10 * time.Minute)
+		for i := 500; i < 1500; i++ {
+			s.Nil(active.Add(fmt.Sprintf("user-%d", i)))
+		}
+		n, err := active.Count()
+		s.Nil(err)
+		s.InEpsilon(1500, n, 0.03)
+
+		// The first minute slides out of the window.
+		clock.Advance(6 * time.Minute)
+		n, err = active.Count()
+		s.Nil(err)
+		s.InEpsilon(1000, n, 0.03)
+	}
+
+	info, ok := s.memory.Inspect(fmt.Sprintf("active-*cache.Memory:%d", int64(1700000000+10*60)/60))
+	s.True(ok)
+	s.InDelta(float64(16*time.Minute-20*time.Second), float64(info.TTL), float64(time.Second))
+}
+
+// fakeDNS answers A queries for found.test. with 192.0.2.1, and any other name with NXDOMAIN,
+// over the stream connections the Go resolver uses for conns that aren't a net.PacketConn.
+func fakeDNS(queries *atomic.Int32) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go func() {
+				defer server.Close()
+				var size [2]byte
+				for {
+					if _, err := io.ReadFull(server, size[:]); err != nil {
+						return
+					}
+					query := make([]byte, int(size[0])<<8|int(size[1]))
+					if _, err := io.ReadFull(server, query); err != nil {
+						return
+					}
+					queries.Add(1)
+
+					end := 12
+					var name []string
+					for query[end] != 0 {
+						name = append(name, string(query[end+1:end+1+int(query[end])]))
+						end += 1 + int(query[end])
+					}
+					end += 5
+					qtype := int(query[end-4])<<8 | int(query[end-3])
+
+					resp := append([]byte{}, query[:end]...)
+					resp[2], resp[3] = 0x81, 0x80
+					resp[6], resp[7], resp[8], resp[9], resp[10], resp[11] = 0, 0, 0, 0, 0, 0
+					switch {
+					case strings.Join(name, ".") != "found.test":
+						resp[3] |= 3
+					case qtype == 1:
+						resp[7] = 1
+						resp = append(resp, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 192, 0, 2, 1)
+					}
+					if _, err := server.Write(append([]byte{byte(len(resp) >> 8), byte(len(resp))}, resp...)); err != nil {
+						return
+					}
+				}
+			}()
+			return client, nil
+		},
+	}
+}
+
+func (s *MemoryTestSuite) TestResolver() {
+	var queries atomic.Int32
+	resolver := NewResolver(s.memory, fakeDNS(&queries), 1*time.Second, 1*time.Second)
+	ctx := context.Background()
+
+	addrs, err := resolver.LookupHost(ctx, "found.test.")
+	s.Nil(err)
+	s.Equal([]string{"192.0.2.1"}, addrs)
+	sent := queries.Load()
+	addrs, err = resolver.LookupHost(ctx, "found.test.")
+	s.Nil(err)
+	s.Equal([]string{"192.0.2.1"}, addrs)
+	s.Equal(sent, queries.Load())
+
+	ips, err := resolver.LookupIP(ctx, "ip4", "found.test.")
+	s.Nil(err)
+	s.Len(ips, 1)
+	s.True(ips[0].Equal(net.IPv4(192, 0, 2, 1)))
+	s.True(s.memory.Has("dns:ip4:found.test."))
+
+	// Names that aren't found are cached too.
+	_, err = resolver.LookupIPAddr(ctx, "missing.test.")
+	var dnsErr *net.DNSError
+	s.ErrorAs(err, &dnsErr)
+	s.True(dnsErr.IsNotFound)
+	sent = queries.Load()
+	_, err = resolver.LookupIPAddr(ctx, "missing.test.")
+	s.ErrorAs(err, &dnsErr)
+	s.True(dnsErr.IsNotFound)
+	s.Equal("missing.test.", dnsErr.Name)
+	s.Equal(sent, queries.Load())
+
+	dial := resolver.DialContext(nil)
+	_, err = dial(ctx, "tcp", "missing.test.:80")
+	s.ErrorAs(err, &dnsErr)
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	conn, err := dial(ctx, "tcp", server.Listener.Addr().String())
+	s.Nil(err)
+	s.Nil(conn.Close())
+
+	// A TTL of 0 doesn't cache the lookups.
+	uncached := NewResolver(s.memory.WithContext(ctx), fakeDNS(&queries), 0, 0)
+	s.memory.Flush()
+	_, err = uncached.LookupHost(ctx, "found.test.")
+	s.Nil(err)
+	sent = queries.Load()
+	_, err = uncached.LookupHost(ctx, "found.test.")
+	s.Nil(err)
+	s.Greater(queries.Load(), sent)
+}
+
+func (s *MemoryTestSuite) TestMemoize() {
+	var calls atomic.Int32
+	square := Memoize(s.memory, "square", 1*time.Second, func(n int) (int, error) {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		if n < 0 {
+			return 0, errors.New("negative")
+		}
+		return n * n, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := square(3)
+			s.Nil(err)
+			s.Equal(9, value)
+		}()
+	}
+	wg.Wait()
+	s.Equal(int32(1), calls.Load())
+
+	value, err := square(4)
+	s.Nil(err)
+	s.Equal(16, value)
+	s.Equal(int32(2), calls.Load())
+
+	_, err = square(-1)
+	s.EqualError(err, "negative")
+	_, err = square(-1)
+	s.EqualError(err, "negative")
+	s.Equal(int32(4), calls.Load())
+
+	join := Memoize2(s.memory, "join", 1*time.Second, func(a, b string) (string, error) {
+		calls.Add(1)
+		return a + b, nil
+	})
+	res, err := join("Go", "Rat")
+	s.Nil(err)
+	s.Equal("GoRat", res)
+	res, err = join("Go", "Rat")
+	s.Nil(err)
+	s.Equal("GoRat", res)
+	s.Equal(int32(5), calls.Load())
+
+	// Closures of the same function are told apart by their name.
+	add := func(n int) func(int) (int, error) {
+		return func(m int) (int, error) {
+			return n + m, nil
+		}
+	}
+	one, two := Memoize(s.memory, "add-1", 1*time.Second, add(1)), Memoize(s.memory, "add-2", 1*time.Second, add(2))
+	value, err = one(1)
+	s.Nil(err)
+	s.Equal(2, value)
+	value, err = two(1)
+	s.Nil(err)
+	s.Equal(3, value)
+}
+
+func (s *MemoryTestSuite) TestRememberMany() {
+	s.Nil(s.memory.Put("remember-many-1", "one"))
+
+	var calls [][]string
+	loader := func(missing []string) (map[string]any, error) {
+		calls = append(calls, missing)
+		return map[string]any{"remember-many-2": "two"}, nil
+	}
+
+	res, err := RememberMany(s.memory, []string{"remember-many-1", "remember-many-2", "remember-many-3"}, 1*time.Second, loader)
+	s.Nil(err)
+	s.Equal(map[string]any{"remember-many-1": "one", "remember-many-2": "two"}, res)
+	s.Equal([][]string{{"remember-many-2", "remember-many-3"}}, calls)
+	s.Equal("two", s.memory.Get("remember-many-2"))
+
+	res, err = RememberMany(s.memory, []string{"remember-many-1", "remember-many-2"}, 1*time.Second, loader)
+	s.Nil(err)
+	s.Len(res, 2)
+	s.Len(calls, 1)
+
+	res, err = RememberMany(s.memory, []string{"remember-many-4"}, 1*time.Second, func([]string) (map[string]any, error) {
+		return nil, errors.New("error")
+	})
+	s.EqualError(err, "error")
+	s.Nil(res)
+}
+
+func (s *MemoryTestSuite) TestRememberT() {
+	value, err := RememberT(s.memory, "remember-t", 1*time.Second, func() (int, error) {
+		return 1, nil
+	})
+	s.Nil(err)
+	s.Equal(1, value)
+	s.Equal(1, GetT[int](s.memory, "remember-t"))
+	s.Equal("Rat", GetT(s.memory, "remember-t", "Rat"))
+
+	value, err = RememberForeverT(s.memory, "remember-t", func() (int, error) {
+		return 2, nil
+	})
+	s.Nil(err)
+	s.Equal(1, value)
+
+	str, err := RememberT(s.memory, "remember-t", 1*time.Second, func() (string, error) {
+		return "Rat", nil
+	})
+	s.EqualError(err, "cache: value of key remember-t is int, not string")
+	s.Empty(str)
+
+	str, err = RememberForeverT(s.memory, "remember-t-error", func() (string, error) {
+		return "", errors.New("error")
+	})
+	s.EqualError(err, "error")
+	s.Empty(str)
+}
+
+func (s *MemoryTestSuite) TestTenants() {
+	tenants := NewTenants(WithMaxCost(2))
+	a, b := tenants.Tenant("a"), tenants.Tenant("b")
+	s.Same(a, tenants.Tenant("a"))
+	s.ElementsMatch([]string{"a", "b"}, tenants.Tenants())
+
+	s.Nil(a.Put("name", "Rat"))
+	s.Nil(b.Put("name", "World"))
+	s.Equal("Rat", a.Get("name"))
+	s.Equal("World", b.Get("name"))
+
+	s.Nil(a.Put("1", 1))
+	s.Nil(a.Put("2", 2))
+	s.False(a.Has("name"))
+	s.True(b.Has("name"))
+
+	s.True(a.Flush())
+	s.False(a.Has("1"))
+	s.True(b.Has("name"))
+
+	tenants.Remove("b")
+	s.False(b.Has("name"))
+	s.NotSame(b, tenants.Tenant("b"))
+}
+
+func (s *MemoryTestSuite) TestTransaction() {
+	s.Nil(s.memory.Put("tx-a", 10))
+	s.Nil(s.memory.Put("tx-b", 0))
+
+	err := s.memory.Transaction(context.Background(), func(tx Cache) error {
+		a := tx.GetInt("tx-a")
+		s.Nil(tx.Put("tx-a", a-4))
+		s.Nil(tx.Put("tx-b", tx.GetInt("tx-b")+4))
+		s.Equal(6, tx.GetInt("tx-a"))
+		s.Equal(10, s.memory.GetInt("tx-a"))
+		s.True(tx.Forget("tx-c"))
+
+		res, err := tx.Increment("tx-counter", 2)
+		s.Equal(int64(2), res)
+		s.Nil(err)
+
+		s.Nil(s.memory.Put("tx-existing-counter", new(int64)))
+		s.True(tx.Has("tx-existing-counter"))
+		res, err = tx.Increment("tx-existing-counter")
+		s.Equal(int64(1), res)
+		return err
+	})
+	s.Nil(err)
+	s.Equal(6, s.memory.GetInt("tx-a"))
+	s.Equal(4, s.memory.GetInt("tx-b"))
+	s.Equal(int64(2), s.memory.GetInt64("tx-counter"))
+
+	res, err := s.memory.Increment("tx-counter")
+	s.Nil(err)
+	s.Equal(int64(3), res)
+
+	err = s.memory.Transaction(context.Background(), func(tx Cache) error {
+		s.Nil(tx.Put("tx-a", 0))
+		return errors.New("rollback")
+	})
+	s.EqualError(err, "rollback")
+	s.Equal(6, s.memory.GetInt("tx-a"))
+
+	err = s.memory.Transaction(context.Background(), func(tx Cache) error {
+		s.Nil(tx.Put("tx-a", tx.GetInt("tx-a")+1))
+		_, err := s.memory.Increment("tx-counter")
+		s.Nil(err)
+		_, err = tx.Increment("tx-counter")
+		return err
+	})
+	s.Nil(err)
+
+	err = s.memory.Transaction(context.Background(), func(tx Cache) error {
+		s.Nil(tx.Put("tx-a", tx.GetInt("tx-a")+1))
+		s.Nil(s.memory.Put("tx-a", 100))
+		return nil
+	})
+	s.ErrorIs(err, ErrTransactionConflict)
+	s.Equal(100, s.memory.GetInt("tx-a"))
+
+	// Counters incremented in a transaction keep their expiration.
+	s.Nil(s.memory.Put("tx-expiring-counter", new(int64), WithTTL(1*time.Minute)))
+	s.Nil(s.memory.Transaction(context.Background(), func(tx Cache) error {
+		_, err := tx.Increment("tx-expiring-counter")
+		return err
+	}))
+	s.Equal(int64(1), s.memory.GetInt64("tx-expiring-counter"))
+	info, _ := s.memory.Inspect("tx-expiring-counter")
+	s.InDelta(float64(1*time.Minute), float64(info.TTL), float64(time.Second))
+}
+
+func (s *MemoryTestSuite) TestTransactionWithConcurrent() {
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				err := s.memory.Transaction(context.Background(), func(tx Cache) error {
+					return tx.Put("tx-concurrent", tx.GetInt("tx-concurrent")+1)
+				})
+				if !errors.Is(err, ErrTransactionConflict) {
+					s.Nil(err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	s.Equal(100, s.memory.GetInt("tx-concurrent"))
+
+	// Increments outside transactions aren't lost to the commits of those inside.
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				_, err := s.memory.Increment("tx-concurrent-counter")
+				s.Nil(err)
+				return
+			}
+			for {
+				err := s.memory.Transaction(context.Background(), func(tx Cache) error {
+					_, err := tx.Increment("tx-concurrent-counter")
+					return err
+				})
+				if !errors.Is(err, ErrTransactionConflict) {
+					s.Nil(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(int64(100), s.memory.GetInt64("tx-concurrent-counter"))
+
+	// Adds outside transactions don't land between the checks and the writes of those inside.
+	var added atomic.Int32
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				if s.memory.Add("tx-concurrent-add", i, NoExpiration) {
+					added.Add(1)
+				}
+				return
+			}
+			for {
+				var ok bool
+				err := s.memory.Transaction(context.Background(), func(tx Cache) error {
+					ok = tx.Add("tx-concurrent-add", i, NoExpiration)
+					return nil
+				})
+				if !errors.Is(err, ErrTransactionConflict) {
+					s.Nil(err)
+					if ok {
+						added.Add(1)
+					}
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(int32(1), added.Load())
+}
+
+func (s *MemoryTestSuite) TestWithContext() {
+	type ctxKey struct{}
+	s.Nil(s.memory.Put("with-context", "Rat"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.WithValue(context.Background(), ctxKey{}, i)
+			scoped := s.memory.WithContext(ctx).(*Memory)
+			s.Equal(i, scoped.ctx.Value(ctxKey{}))
+			s.Equal("Rat", scoped.Get("with-context"))
+		}()
+	}
+	wg.Wait()
+
+	s.Nil(s.memory.ctx)
+	scoped := s.memory.WithContext(context.Background())
+	s.Nil(scoped.Put("with-context", "World"))
+	s.Equal("World", s.memory.Get("with-context"))
+}
+
+type blockingCache struct {
+	Cache
+	release chan struct{}
+	err     error
+}
+
+func (r *blockingCache) Put(key string, value any, opts ...PutOption) error {
+	if r.release != nil {
+		<-r.release
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.Cache.Put(key, value, opts...)
+}
+
+type countingCache struct {
+	Cache
+	puts int
+}
+
+func (r *countingCache) Put(key string, value any, opts ...PutOption) error {
+	r.puts++
+
+	return r.Cache.Put(key, value, opts...)
+}
+
+type clonerValue struct {
+	n int
+}
+
+func (r clonerValue) Clone() any {
+	return clonerValue{n: r.n + 1}
+}
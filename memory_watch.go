@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// watchBuffer is how many events a watcher holds before newer ones are dropped.
+const watchBuffer = 64
+
+// keyWatchers holds the channels of callers watching keys.
+type keyWatchers struct {
+	mu    sync.Mutex
+	chans map[string]map[chan Event]struct{}
+	n     atomic.Int32
+}
+
+func (r *keyWatchers) add(key string) chan Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.chans == nil {
+		r.chans = make(map[string]map[chan Event]struct{})
+	}
+	if r.chans[key] == nil {
+		r.chans[key] = make(map[chan Event]struct{})
+	}
+	ch := make(chan Event, watchBuffer)
+	r.chans[key][ch] = struct{}{}
+	r.n.Add(1)
+
+	return ch
+}
+
+func (r *keyWatchers) remove(key string, ch chan Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.chans[key][ch]; !ok {
+		return
+	}
+	delete(r.chans[key], ch)
+	if len(r.chans[key]) == 0 {
+		delete(r.chans, key)
+	}
+	r.n.Add(-1)
+}
+
+// closeAll closes and drops the channels of every watcher.
+func (r *keyWatchers) closeAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, chans := range r.chans {
+		for ch := range chans {
+			close(ch)
+			r.n.Add(-1)
+		}
+	}
+	r.chans = nil
+}
+
+func (r *keyWatchers) watched() bool {
+	return r.n.Load() > 0
+}
+
+// notify sends event to everyone watching its key, dropping it for watchers that fell behind.
+func (r *keyWatchers) notify(event Event) {
+	if !r.watched() {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for ch := range r.chans[event.Key] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// notifyStored sends an EventSet for an item just stored under key.
+func (r *memoryState) notifyStored(key string, e *memoryEntry) {
+	if !r.watchers.watched() {
+		return
+	}
+
+	value := e.value
+	if r.copyValues {
+		if res, err := copyValue(value); err == nil {
+			value = res
+		}
+	}
+	r.watchers.notify(Event{Type: EventSet, Key: key, Value: value})
+}
+
+// Watch returns a channel receiving the changes of key until ctx is done or the driver is closed,
+// after which it's closed.
+// Events are dropped when the channel falls more than 64 events behind.
+func (r *Memory) Watch(ctx context.Context, key string) (<-chan Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ch := r.watchers.add(key)
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		defer r.watchers.remove(key, ch)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// WaitFor blocks until an item is stored under key, returning it right away if it exists.
+// It returns ErrWaitTimeout after timeout, ErrClosed if the driver is closed meanwhile,
+// or the context error once ctx is done.
+func (r *Memory) WaitFor(ctx context.Context, key string, timeout time.Duration) (any, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	// Watch before reading, so an item stored in between isn't missed.
+	ch := r.watchers.add(key)
+	defer r.watchers.remove(key, ch)
+
+	if val, exist := r.GetExists(key); exist {
+		return val, nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, ErrWaitTimeout
+		case event, ok := <-ch:
+			if !ok {
+				return nil, ErrClosed
+			}
+			if event.Type == EventSet {
+				return event.Value, nil
+			}
+		}
+	}
+}
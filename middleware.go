@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// Op is a cache operation passed through middlewares.
+type Op struct {
+	// Name is the Cache method called, such as "Get" or "Put".
+	Name string
+	// Key is the key operated on, empty for Flush.
+	Key string
+	// Value is the item written by Add, Forever and Put.
+	Value any
+	// TTL is the time given to Add and Remember.
+	TTL time.Duration
+	// Delta is the amount given to Increment and Decrement.
+	Delta int64
+	// Opts are the options given to Put.
+	Opts []PutOption
+	// Callback computes the item for Remember and RememberForever.
+	Callback func() (any, error)
+}
+
+// Handler performs an operation, returning what the Cache method returns:
+// the item for Get, GetExists, Pull and Remember, a bool for Add, Forever, Forget, Flush and Has,
+// an int64 for Increment and Decrement, and nil for Put. GetExists reports a missing item
+// with ErrKeyNotFound.
+type Handler func(ctx context.Context, op *Op) (any, error)
+
+// Middleware wraps the handling of every operation, e.g. to log, measure or transform them.
+type Middleware func(next Handler) Handler
+
+type middlewareCache struct {
+	Cache
+	ctx     context.Context
+	mw      []Middleware
+	handler Handler
+}
+
+// Use returns a cache passing every operation through the middlewares, the first one outermost.
+func Use(instance Cache, mw ...Middleware) Cache {
+	return &middlewareCache{
+		Cache:   instance,
+		ctx:     context.Background(),
+		mw:      mw,
+		handler: chain(instance, mw),
+	}
+}
+
+func chain(instance Cache, mw []Middleware) Handler {
+	handler := dispatch(instance)
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+
+	return handler
+}
+
+// dispatch calls the Cache method named by the operation.
+func dispatch(instance Cache) Handler {
+	return func(ctx context.Context, op *Op) (any, error) {
+		store := instance.WithContext(ctx)
+		switch op.Name {
+		case "Add":
+			return store.Add(op.Key, op.Value, op.TTL), nil
+		case "Decrement":
+			return store.Decrement(op.Key, op.Delta)
+		case "Forever":
+			return store.Forever(op.Key, op.Value), nil
+		case "Forget":
+			return store.Forget(op.Key), nil
+		case "Flush":
+			return store.Flush(), nil
+		case "Get":
+			return store.Get(op.Key), nil
+		case "GetExists":
+			if val, exist := store.GetExists(op.Key); exist {
+				return val, nil
+			}
+			return nil, ErrKeyNotFound
+		case "Has":
+			return store.Has(op.Key), nil
+		case "Increment":
+			return store.Increment(op.Key, op.Delta)
+		case "Put":
+			return nil, store.Put(op.Key, op.Value, op.Opts...)
+		case "Pull":
+			return store.Pull(op.Key), nil
+		case "Remember":
+			return store.Remember(op.Key, op.TTL, op.Callback)
+		case "RememberForever":
+			return store.RememberForever(op.Key, op.Callback)
+		default:
+			return nil, nil
+		}
+	}
+}
+
+func (r *middlewareCache) do(op *Op) (any, error) {
+	return r.handler(r.ctx, op)
+}
+
+func (r *middlewareCache) Add(key string, value any, t time.Duration) bool {
+	res, _ := r.do(&Op{Name: "Add", Key: key, Value: value, TTL: t})
+	return cast.ToBool(res)
+}
+
+func (r *middlewareCache) Decrement(key string, value ...int64) (int64, error) {
+	if len(value) == 0 {
+		value = append(value, 1)
+	}
+
+	res, err := r.do(&Op{Name: "Decrement", Key: key, Delta: value[0]})
+	return cast.ToInt64(res), err
+}
+
+func (r *middlewareCache) Forever(key string, value any) bool {
+	res, _ := r.do(&Op{Name: "Forever", Key: key, Value: value})
+	return cast.ToBool(res)
+}
+
+func (r *middlewareCache) Forget(key string) bool {
+	res, _ := r.do(&Op{Name: "Forget", Key: key})
+	return cast.ToBool(res)
+}
+
+func (r *middlewareCache) Flush() bool {
+	res, _ := r.do(&Op{Name: "Flush"})
+	return cast.ToBool(res)
+}
+
+func (r *middlewareCache) Get(key string, def ...any) any {
+	if res, _ := r.do(&Op{Name: "Get", Key: key}); res != nil {
+		return res
+	}
+
+	return defaultValue(def...)
+}
+
+func (r *middlewareCache) GetExists(key string) (any, bool) {
+	res, err := r.do(&Op{Name: "GetExists", Key: key})
+	return res, err == nil
+}
+
+func (r *middlewareCache) GetBool(key string, def ...bool) bool {
+	if len(def) == 0 {
+		def = append(def, false)
+	}
+
+	return cast.ToBool(r.Get(key, def[0]))
+}
+
+func (r *middlewareCache) GetInt(key string, def ...int) int {
+	if len(def) == 0 {
+		def = append(def, 0)
+	}
+
+	return cast.ToInt(r.Get(key, def[0]))
+}
+
+func (r *middlewareCache) GetInt64(key string, def ...int64) int64 {
+	if len(def) == 0 {
+		def = append(def, 0)
+	}
+
+	return cast.ToInt64(r.Get(key, def[0]))
+}
+
+func (r *middlewareCache) GetString(key string, def ...string) string {
+	if len(def) == 0 {
+		def = append(def, "")
+	}
+
+	return cast.ToString(r.Get(key, def[0]))
+}
+
+func (r *middlewareCache) Has(key string) bool {
+	res, _ := r.do(&Op{Name: "Has", Key: key})
+	return cast.ToBool(res)
+}
+
+func (r *middlewareCache) Increment(key string, value ...int64) (int64, error) {
+	if len(value) == 0 {
+		value = append(value, 1)
+	}
+
+	res, err := r.do(&Op{Name: "Increment", Key: key, Delta: value[0]})
+	return cast.ToInt64(res), err
+}
+
+func (r *middlewareCache) Lock(key string, t ...time.Duration) *Lock {
+	return NewLock(r, key, t...)
+}
+
+// Pin pins key on the underlying store if it implements Pinner, for the fencing counters of locks.
+func (r *middlewareCache) Pin(key string) {
+	if pinner, ok := r.Cache.(Pinner); ok {
+		pinner.Pin(key)
+	}
+}
+
+// Unpin unpins key on the underlying store if it implements Pinner.
+func (r *middlewareCache) Unpin(key string) {
+	if pinner, ok := r.Cache.(Pinner); ok {
+		pinner.Unpin(key)
+	}
+}
+
+func (r *middlewareCache) Pipeline() *Pipeline {
+	return NewPipeline(r)
+}
+
+func (r *middlewareCache) Put(key string, value any, opts ...PutOption) error {
+	_, err := r.do(&Op{Name: "Put", Key: key, Value: value, Opts: opts})
+	return err
+}
+
+func (r *middlewareCache) Pull(key string, def ...any) any {
+	if res, _ := r.do(&Op{Name: "Pull", Key: key}); res != nil {
+		return res
+	}
+
+	return defaultValue(def...)
+}
+
+func (r *middlewareCache) Remember(key string, ttl time.Duration, callback func() (any, error)) (any, error) {
+	return r.do(&Op{Name: "Remember", Key: key, TTL: ttl, Callback: callback})
+}
+
+func (r *middlewareCache) RememberForever(key string, callback func() (any, error)) (any, error) {
+	return r.do(&Op{Name: "RememberForever", Key: key, Callback: callback})
+}
+
+// Transaction runs fn with a transaction whose operations also pass through the middlewares.
+func (r *middlewareCache) Transaction(ctx context.Context, fn func(tx Cache) error) error {
+	return r.Cache.Transaction(ctx, func(tx Cache) error {
+		return fn(&middlewareCache{Cache: tx, ctx: ctx, mw: r.mw, handler: chain(tx, r.mw)})
+	})
+}
+
+func (r *middlewareCache) WithContext(ctx context.Context) Cache {
+	return &middlewareCache{Cache: r.Cache, ctx: ctx, mw: r.mw, handler: r.handler}
+}
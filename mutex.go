@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"sync"
+)
+
+type keyedMutexEntry struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// KeyedMutex hands out one mutex per key, dropping it once nobody holds or waits for it,
+// e.g. to serialize populating the same item. The zero value is ready to use.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedMutexEntry
+}
+
+// Lock locks the mutex of key, waiting until it's available.
+func (r *KeyedMutex) Lock(key string) {
+	r.mu.Lock()
+	if r.locks == nil {
+		r.locks = make(map[string]*keyedMutexEntry)
+	}
+	entry, ok := r.locks[key]
+	if !ok {
+		entry = &keyedMutexEntry{}
+		r.locks[key] = entry
+	}
+	entry.refs++
+	r.mu.Unlock()
+
+	entry.mu.Lock()
+}
+
+// Unlock unlocks the mutex of key, it panics if key isn't locked.
+func (r *KeyedMutex) Unlock(key string) {
+	r.mu.Lock()
+	entry, ok := r.locks[key]
+	if !ok {
+		r.mu.Unlock()
+		panic("cache: unlock of unlocked key " + key)
+	}
+	entry.refs--
+	if entry.refs == 0 {
+		delete(r.locks, key)
+	}
+	r.mu.Unlock()
+
+	entry.mu.Unlock()
+}
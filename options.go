@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+type putOptions struct {
+	ttl      time.Duration
+	jitter   time.Duration
+	softTTL  time.Duration
+	cost     int64
+	priority Priority
+	validate func(value any) bool
+}
+
+// PutOption configures a single Put.
+type PutOption func(*putOptions)
+
+// WithTTL stores the item for the given time, NoExpiration keeps it indefinitely.
+func WithTTL(t time.Duration) PutOption {
+	return func(o *putOptions) {
+		o.ttl = t
+	}
+}
+
+// WithJitter adds a random duration in [0, jitter) to the TTL, so items written
+// together don't all expire at the same moment.
+func WithJitter(jitter time.Duration) PutOption {
+	return func(o *putOptions) {
+		o.jitter = jitter
+	}
+}
+
+// WithSoftTTL marks the item as stale once soft elapses. Stale items are still
+// returned until the TTL, but Remember refreshes them in the background.
+func WithSoftTTL(soft time.Duration) PutOption {
+	return func(o *putOptions) {
+		o.softTTL = soft
+	}
+}
+
+// WithCost sets what the item counts against the driver's max cost, 1 by default.
+func WithCost(n int64) PutOption {
+	return func(o *putOptions) {
+		o.cost = n
+	}
+}
+
+// WithPriority sets how readily the item is evicted, PriorityNormal by default.
+func WithPriority(priority Priority) PutOption {
+	return func(o *putOptions) {
+		o.priority = priority
+	}
+}
+
+func newPutOptions(opts ...PutOption) putOptions {
+	return resolvePutOptions(rand.N[time.Duration], opts...)
+}
+
+// resolvePutOptions applies opts, drawing the jitter added to the TTL with n.
+func resolvePutOptions(n func(time.Duration) time.Duration, opts ...PutOption) putOptions {
+	o := putOptions{cost: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.ttl != NoExpiration && o.jitter > 0 {
+		o.ttl += n(o.jitter)
+	}
+
+	return o
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned in place of a panic raised by a callback computing an item.
+type PanicError struct {
+	// Value is what the callback panicked with.
+	Value any
+	// Stack is the stack trace of the goroutine at the panic.
+	Stack []byte
+}
+
+func (r *PanicError) Error() string {
+	return fmt.Sprintf("cache: callback panicked: %v\n\n%s", r.Value, r.Stack)
+}
+
+// Unwrap returns the value panicked with if it's an error.
+func (r *PanicError) Unwrap() error {
+	if err, ok := r.Value.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// safeCall calls callback, turning a panic into a *PanicError.
+func safeCall(callback func() (any, error)) (val any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			val, err = nil, &PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+
+	return callback()
+}
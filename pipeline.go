@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	pipelinePut = iota
+	pipelineForget
+	pipelineIncrement
+	pipelineDecrement
+)
+
+type pipelineOp struct {
+	kind  int
+	key   string
+	value any
+	delta int64
+	opts  []PutOption
+}
+
+type Pipeline struct {
+	store Cache
+	ops   []pipelineOp
+}
+
+func NewPipeline(instance Cache) *Pipeline {
+	return &Pipeline{
+		store: instance,
+	}
+}
+
+// Put queues storing an item in the cache.
+func (r *Pipeline) Put(key string, value any, opts ...PutOption) *Pipeline {
+	r.ops = append(r.ops, pipelineOp{kind: pipelinePut, key: key, value: value, opts: opts})
+	return r
+}
+
+// Forget queues removing an item from the cache.
+func (r *Pipeline) Forget(key string) *Pipeline {
+	r.ops = append(r.ops, pipelineOp{kind: pipelineForget, key: key})
+	return r
+}
+
+// Increment queues incrementing the value of an item in the cache.
+func (r *Pipeline) Increment(key string, value ...int64) *Pipeline {
+	if len(value) == 0 {
+		value = append(value, 1)
+	}
+
+	r.ops = append(r.ops, pipelineOp{kind: pipelineIncrement, key: key, delta: value[0]})
+	return r
+}
+
+// Decrement queues decrementing the value of an item in the cache.
+func (r *Pipeline) Decrement(key string, value ...int64) *Pipeline {
+	if len(value) == 0 {
+		value = append(value, 1)
+	}
+
+	r.ops = append(r.ops, pipelineOp{kind: pipelineDecrement, key: key, delta: value[0]})
+	return r
+}
+
+// Len returns the number of queued operations.
+func (r *Pipeline) Len() int {
+	return len(r.ops)
+}
+
+// Exec runs the queued operations in order and clears the queue.
+// The result of each operation is returned at the same index: nil for Put,
+// bool for Forget and int64 for Increment/Decrement. Execution stops at the
+// first error or when ctx is done, returning the results collected so far.
+func (r *Pipeline) Exec(ctx context.Context) ([]any, error) {
+	ops := r.ops
+	r.ops = nil
+
+	return execPipeline(ctx, r.store, ops)
+}
+
+// ExecAtomic runs the queued operations in order within a transaction and clears the queue,
+// so either all of them are applied or none. The results are those of Exec. The transaction
+// is run again while it conflicts with other writes, and nothing is applied on an error.
+func (r *Pipeline) ExecAtomic(ctx context.Context) ([]any, error) {
+	ops := r.ops
+	r.ops = nil
+
+	for {
+		var res []any
+		err := r.store.Transaction(ctx, func(tx Cache) error {
+			var err error
+			res, err = execPipeline(ctx, tx, ops)
+			return err
+		})
+		switch {
+		case errors.Is(err, ErrTransactionConflict):
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		return res, nil
+	}
+}
+
+func execPipeline(ctx context.Context, store Cache, ops []pipelineOp) ([]any, error) {
+	res := make([]any, 0, len(ops))
+	for _, op := range ops {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+
+		switch op.kind {
+		case pipelinePut:
+			if err := store.Put(op.key, op.value, op.opts...); err != nil {
+				return res, err
+			}
+			res = append(res, nil)
+		case pipelineForget:
+			res = append(res, store.Forget(op.key))
+		case pipelineIncrement:
+			val, err := store.Increment(op.key, op.delta)
+			if err != nil {
+				return res, err
+			}
+			res = append(res, val)
+		case pipelineDecrement:
+			val, err := store.Decrement(op.key, op.delta)
+			if err != nil {
+				return res, err
+			}
+			res = append(res, val)
+		}
+	}
+
+	return res, nil
+}
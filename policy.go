@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// KeyPolicy is what the keys written to a store must comply with, see WithKeyPolicy.
+type KeyPolicy struct {
+	// Prefix is what keys must start with, e.g. the name of the service owning them.
+	Prefix string
+	// Allow is what keys must match, if not nil.
+	Allow *regexp.Regexp
+	// MaxKeys raises an alarm through OnViolation, once, when the number of distinct keys
+	// written exceeds it, as estimated by a HyperLogLog. Writes aren't rejected for it.
+	MaxKeys int64
+	// Warn reports violations to OnViolation without rejecting the writes.
+	Warn bool
+	// OnViolation, if not nil, is called with the key and the violation, an error wrapping ErrKeyPolicy.
+	OnViolation func(key string, err error)
+}
+
+// WithKeyPolicy is a middleware checking the keys written against policy, so a shared store
+// isn't filled with keys it wasn't meant to hold, such as one per request. Writes of keys
+// violating it are rejected, failing with an error wrapping ErrKeyPolicy where the method returns
+// errors and returning false otherwise, unless Warn is set. Reads aren't checked.
+func WithKeyPolicy(policy KeyPolicy) Middleware {
+	keys := NewHyperLogLog()
+	var alarmed atomic.Bool
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Op) (any, error) {
+			switch op.Name {
+			case "Add", "Decrement", "Forever", "Increment", "Put", "Remember", "RememberForever":
+			default:
+				return next(ctx, op)
+			}
+
+			var err error
+			switch {
+			case !strings.HasPrefix(op.Key, policy.Prefix):
+				err = fmt.Errorf("%w: %s doesn't start with %s", ErrKeyPolicy, op.Key, policy.Prefix)
+			case policy.Allow != nil && !policy.Allow.MatchString(op.Key):
+				err = fmt.Errorf("%w: %s doesn't match %s", ErrKeyPolicy, op.Key, policy.Allow)
+			}
+			if err != nil {
+				if policy.OnViolation != nil {
+					policy.OnViolation(op.Key, err)
+				}
+				if !policy.Warn {
+					return nil, err
+				}
+			}
+
+			if policy.MaxKeys > 0 && keys.Add(op.Key) && !alarmed.Load() && keys.Count() > policy.MaxKeys {
+				if !alarmed.Swap(true) && policy.OnViolation != nil {
+					policy.OnViolation(op.Key, fmt.Errorf("%w: more than %d keys written", ErrKeyPolicy, policy.MaxKeys))
+				}
+			}
+
+			return next(ctx, op)
+		}
+	}
+}
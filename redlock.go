@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// redlockStore backs a Redlock, holding a lock on a quorum of independent instances.
+// Only the operations used by Lock are spread over the instances, the others go to the first one.
+type redlockStore struct {
+	Cache
+	instances []Cache
+	token     string
+}
+
+// NewRedlock returns a lock held on a majority of the given independent instances, following
+// the Redlock algorithm, so it survives the loss of a minority of them. The lock is only acquired
+// if the majority was reached before the TTL elapsed, minus an allowance for clock drift,
+// and is released from every instance that still holds it.
+func NewRedlock(key string, ttl time.Duration, instances ...Cache) *Lock {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+
+	store := &redlockStore{
+		instances: instances,
+		token:     hex.EncodeToString(token),
+	}
+	if len(instances) > 0 {
+		store.Cache = instances[0]
+	}
+
+	return NewLock(store, key, ttl)
+}
+
+func (r *redlockStore) quorum() int {
+	return len(r.instances)/2 + 1
+}
+
+func (r *redlockStore) Add(key string, _ any, t time.Duration) bool {
+	start := time.Now()
+
+	var acquired []Cache
+	for _, instance := range r.instances {
+		if instance.Add(key, r.token, t) {
+			acquired = append(acquired, instance)
+		}
+	}
+
+	drift := t/100 + 2*time.Millisecond
+	if len(acquired) >= r.quorum() && (t == NoExpiration || time.Since(start) < t-drift) {
+		return true
+	}
+
+	for _, instance := range acquired {
+		r.release(instance, key)
+	}
+
+	return false
+}
+
+// Forget releases the lock from every instance still holding it, reporting whether they're a majority.
+func (r *redlockStore) Forget(key string) bool {
+	var released int
+	for _, instance := range r.instances {
+		if r.release(instance, key) {
+			released++
+		}
+	}
+
+	return released >= r.quorum()
+}
+
+// release forgets key from instance, provided it's still our lock.
+func (r *redlockStore) release(instance Cache, key string) bool {
+	var released bool
+	_ = instance.Transaction(context.Background(), func(tx Cache) error {
+		if tx.Get(key) == r.token {
+			released = tx.Forget(key)
+		}
+		return nil
+	})
+
+	return released
+}
+
+// Increment increments key on every instance, returning the highest value.
+func (r *redlockStore) Increment(key string, value ...int64) (int64, error) {
+	var res int64
+	var succeeded int
+	for _, instance := range r.instances {
+		if n, err := instance.Increment(key, value...); err == nil {
+			res = max(res, n)
+			succeeded++
+		}
+	}
+
+	if succeeded < r.quorum() {
+		return 0, ErrDriverUnavailable
+	}
+
+	return res, nil
+}
+
+func (r *redlockStore) Lock(key string, t ...time.Duration) *Lock {
+	return NewLock(r, key, t...)
+}
+
+func (r *redlockStore) WithContext(ctx context.Context) Cache {
+	instances := make([]Cache, len(r.instances))
+	for i, instance := range r.instances {
+		instances[i] = instance.WithContext(ctx)
+	}
+
+	store := &redlockStore{instances: instances, token: r.token}
+	if len(instances) > 0 {
+		store.Cache = instances[0]
+	}
+
+	return store
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RememberMany get several items from the cache, calling loader once with the keys that are missing
+// and storing whatever it returns. Keys the loader doesn't return are left out of the result.
+func RememberMany(instance Cache, keys []string, ttl time.Duration, loader func(missing []string) (map[string]any, error)) (map[string]any, error) {
+	res := make(map[string]any, len(keys))
+	var missing []string
+	for _, key := range keys {
+		if _, ok := res[key]; ok {
+			continue
+		}
+		if val, exist := instance.GetExists(key); exist {
+			res[key] = val
+			continue
+		}
+		missing = append(missing, key)
+	}
+
+	if len(missing) == 0 {
+		return res, nil
+	}
+
+	loaded, err := loader(missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range missing {
+		val, ok := loaded[key]
+		if !ok || val == nil {
+			continue
+		}
+		if err = instance.Put(key, val, WithTTL(ttl)); err != nil {
+			return nil, err
+		}
+		res[key] = val
+	}
+
+	return res, nil
+}
+
+// RememberWithTTL get an item from the cache, or execute the given Closure and store the result
+// for the TTL it returns, e.g. one taken from an upstream Cache-Control header. A TTL of KeepForever
+// stores the result indefinitely, and one of 0 or less, such as a computed TTL rounding to zero,
+// returns it without storing it.
+func RememberWithTTL(instance Cache, key string, callback func() (any, time.Duration, error)) (any, error) {
+	if val, exist := instance.GetExists(key); exist {
+		return val, nil
+	}
+
+	var ttl time.Duration
+	val, err := safeCall(func() (val any, err error) {
+		val, ttl, err = callback()
+		return val, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case ttl == KeepForever:
+		ttl = NoExpiration
+	case ttl <= 0:
+		return val, nil
+	}
+	if err = instance.Put(key, val, WithTTL(ttl)); err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// RememberDistributed get an item from the cache, or execute the given Closure and store the result,
+// with only one caller across everything sharing the cache running the Closure at a time. The others
+// wait for its result, and try to take over if it isn't stored within lockTTL.
+func RememberDistributed(instance Cache, key string, ttl, lockTTL time.Duration, callback func() (any, error)) (any, error) {
+	for {
+		val, hit, lock := GetOrLock(instance, key, lockTTL)
+		if hit {
+			return val, nil
+		}
+
+		if lock != nil {
+			return rememberLocked(instance, key, ttl, lock, callback)
+		}
+
+		val, err := WaitFor(context.Background(), instance, key, lockTTL)
+		if err == nil {
+			return val, nil
+		}
+		if !errors.Is(err, ErrWaitTimeout) {
+			return nil, err
+		}
+	}
+}
+
+// rememberLocked computes and stores an item while holding lock, releasing it afterwards.
+func rememberLocked(instance Cache, key string, ttl time.Duration, lock *Lock, callback func() (any, error)) (any, error) {
+	defer lock.Release()
+
+	val, err := safeCall(callback)
+	if err != nil {
+		return nil, err
+	}
+	if err = instance.Put(key, val, WithTTL(ttl)); err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// fileMemo is what RememberFile stores, the result along with the file it was computed from.
+type fileMemo struct {
+	ModTime time.Time
+	Size    int64
+	Value   any
+}
+
+// RememberFile get the result of callback computed from the file at path, or execute the given
+// Closure and store the result, until the modification time or size of the file changes,
+// e.g. to cache a parsed config or template. The file is checked on every call, failing with
+// the error of os.Stat when it's gone. Items are stored indefinitely under "file:" and the
+// absolute path.
+func RememberFile(instance Cache, path string, callback func() (any, error)) (any, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	key := "file:" + abs
+	if memo, ok := instance.Get(key).(fileMemo); ok && memo.ModTime.Equal(info.ModTime()) && memo.Size == info.Size() {
+		return memo.Value, nil
+	}
+
+	val, err := safeCall(callback)
+	if err != nil {
+		return nil, err
+	}
+	if err = instance.Put(key, fileMemo{ModTime: info.ModTime(), Size: info.Size(), Value: val}); err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
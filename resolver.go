@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// dnsNotFound is stored for names that don't resolve.
+type dnsNotFound struct {
+	Err string
+}
+
+// Resolver is a caching DNS resolver, with the lookup methods of net.Resolver.
+type Resolver struct {
+	cache       Cache
+	resolver    *net.Resolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+// NewResolver returns a resolver caching the lookups of resolver, net.DefaultResolver if nil,
+// in instance for ttl, and the names that aren't found for negativeTTL, 0 not to cache either.
+// Other failures, such as timeouts, aren't cached. net.Resolver doesn't tell the TTLs of the
+// records, so pick ttl below those of the names looked up. Lookups are stored under "dns:",
+// the kind of lookup and the name.
+func NewResolver(instance Cache, resolver *net.Resolver, ttl, negativeTTL time.Duration) *Resolver {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	return &Resolver{
+		cache:       instance,
+		resolver:    resolver,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
+
+// LookupHost looks up the addresses of host, as net.Resolver.LookupHost.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return lookup(ctx, r, "host", host, r.resolver.LookupHost)
+}
+
+// LookupIPAddr looks up the IP addresses of host, as net.Resolver.LookupIPAddr.
+func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return lookup(ctx, r, "ipaddr", host, r.resolver.LookupIPAddr)
+}
+
+// LookupIP looks up the IP addresses of host for network, "ip", "ip4" or "ip6", as net.Resolver.LookupIP.
+func (r *Resolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	return lookup(ctx, r, network, host, func(ctx context.Context, host string) ([]net.IP, error) {
+		return r.resolver.LookupIP(ctx, network, host)
+	})
+}
+
+// LookupAddr looks up the names of addr, as net.Resolver.LookupAddr.
+func (r *Resolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return lookup(ctx, r, "addr", addr, r.resolver.LookupAddr)
+}
+
+// DialContext returns a dial function connecting with dialer, a zero net.Dialer if nil, to the
+// address host resolves to, for http.Transport and the like. The addresses are tried in turn.
+func (r *Resolver) DialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ip := range addrs {
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+func lookup[T any](ctx context.Context, r *Resolver, kind, name string, fn func(ctx context.Context, name string) (T, error)) (T, error) {
+	var zero T
+	key := "dns:" + kind + ":" + name
+
+	val, err := RememberWithTTL(r.cache.WithContext(ctx), key, func() (any, time.Duration, error) {
+		res, err := fn(ctx, name)
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound && r.negativeTTL > 0 {
+			return dnsNotFound{Err: dnsErr.Err}, r.negativeTTL, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		return res, r.ttl, nil
+	})
+	if err != nil {
+		return zero, err
+	}
+
+	switch v := val.(type) {
+	case T:
+		return v, nil
+	case dnsNotFound:
+		return zero, &net.DNSError{Err: v.Err, Name: name, IsNotFound: true}
+	default:
+		r.cache.Forget(key)
+		return fn(ctx, name)
+	}
+}
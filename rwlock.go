@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var errRWLockBusy = errors.New("cache: read-write lock is busy")
+
+// rwLockState is stored under the key of an RWLock. It's replaced as a whole on every change,
+// never mutated, so transactions can tell it changed by its identity.
+type rwLockState struct {
+	writer      string
+	writerUntil time.Time
+	readers     map[string]time.Time
+}
+
+// RWLock is a lock that many readers can hold at once, while a writer holds it alone.
+// Each RWLock is a single holder; a hold lasts at most ttl, so holders that crash don't block others.
+type RWLock struct {
+	store Cache
+	key   string
+	ttl   time.Duration
+	id    string
+}
+
+func NewRWLock(instance Cache, key string, ttl time.Duration) *RWLock {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	return &RWLock{
+		store: instance,
+		key:   key,
+		ttl:   ttl,
+		id:    hex.EncodeToString(id),
+	}
+}
+
+// RLock acquires the lock for reading, returning false while a writer holds it.
+func (r *RWLock) RLock() bool {
+	return r.update(func(state *rwLockState, now time.Time) bool {
+		if state.writer != "" && state.writer != r.id {
+			return false
+		}
+		state.readers[r.id] = now.Add(r.ttl)
+		return true
+	})
+}
+
+// RUnlock releases the lock held for reading.
+func (r *RWLock) RUnlock() bool {
+	return r.update(func(state *rwLockState, _ time.Time) bool {
+		if _, ok := state.readers[r.id]; !ok {
+			return false
+		}
+		delete(state.readers, r.id)
+		return true
+	})
+}
+
+// WLock acquires the lock for writing, returning false while anyone else holds it.
+func (r *RWLock) WLock() bool {
+	return r.update(func(state *rwLockState, now time.Time) bool {
+		if state.writer != "" && state.writer != r.id {
+			return false
+		}
+		for reader := range state.readers {
+			if reader != r.id {
+				return false
+			}
+		}
+		state.writer, state.writerUntil = r.id, now.Add(r.ttl)
+		return true
+	})
+}
+
+// WUnlock releases the lock held for writing.
+func (r *RWLock) WUnlock() bool {
+	return r.update(func(state *rwLockState, _ time.Time) bool {
+		if state.writer != r.id {
+			return false
+		}
+		state.writer, state.writerUntil = "", time.Time{}
+		return true
+	})
+}
+
+// BlockRLock waits up to t to acquire the lock for reading, trying again every 100 milliseconds.
+func (r *RWLock) BlockRLock(t time.Duration) bool {
+	return r.block(t, r.RLock)
+}
+
+// BlockWLock waits up to t to acquire the lock for writing, trying again every 100 milliseconds.
+func (r *RWLock) BlockWLock(t time.Duration) bool {
+	return r.block(t, r.WLock)
+}
+
+func (r *RWLock) block(t time.Duration, acquire func() bool) bool {
+	timer := time.NewTimer(t)
+	defer timer.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if acquire() {
+			return true
+		}
+
+		select {
+		case <-timer.C:
+			return acquire()
+		case <-ticker.C:
+		}
+	}
+}
+
+// update applies fn to a fresh copy of the stored state, without the holds that expired,
+// and stores it if fn returns true. It retries when another holder changed the state concurrently.
+func (r *RWLock) update(fn func(state *rwLockState, now time.Time) bool) bool {
+	for {
+		err := r.store.Transaction(context.Background(), func(tx Cache) error {
+			now := time.Now()
+			next := &rwLockState{readers: make(map[string]time.Time)}
+			if state, ok := tx.Get(r.key).(*rwLockState); ok {
+				if state.writer != "" && now.Before(state.writerUntil) {
+					next.writer, next.writerUntil = state.writer, state.writerUntil
+				}
+				for reader, until := range state.readers {
+					if now.Before(until) {
+						next.readers[reader] = until
+					}
+				}
+			}
+
+			if !fn(next, now) {
+				return errRWLockBusy
+			}
+			if next.writer == "" && len(next.readers) == 0 {
+				tx.Forget(r.key)
+				return nil
+			}
+
+			// The state lives as long as the longest hold, so holders with a shorter ttl don't cut others short.
+			until := next.writerUntil
+			for _, reader := range next.readers {
+				if reader.After(until) {
+					until = reader
+				}
+			}
+			return tx.Put(r.key, next, WithTTL(until.Sub(now)))
+		})
+		if !errors.Is(err, ErrTransactionConflict) {
+			return err == nil
+		}
+	}
+}
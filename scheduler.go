@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// PatternForgetter is implemented by stores that can forget every key matching a pattern.
+type PatternForgetter interface {
+	// ForgetPattern removes the items whose key matches the glob pattern, returning how many.
+	ForgetPattern(pattern string) int
+}
+
+type scheduleRule struct {
+	schedule *schedule
+	next     time.Time
+	run      func()
+}
+
+// Scheduler forgets keys on cron schedules, e.g. purging daily reports at midnight.
+type Scheduler struct {
+	store Cache
+
+	mu    sync.Mutex
+	rules []*scheduleRule
+	wake  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func NewScheduler(instance Cache) *Scheduler {
+	return &Scheduler{
+		store: instance,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// ForgetKeys forgets the given keys every time spec fires.
+func (r *Scheduler) ForgetKeys(spec string, keys ...string) error {
+	return r.add(spec, func() {
+		for _, key := range keys {
+			r.store.Forget(key)
+		}
+	})
+}
+
+// ForgetPattern forgets the keys matching the glob pattern every time spec fires.
+// The store must implement PatternForgetter.
+func (r *Scheduler) ForgetPattern(spec, pattern string) error {
+	forgetter, ok := r.store.(PatternForgetter)
+	if !ok {
+		return ErrPatternUnsupported
+	}
+
+	return r.add(spec, func() {
+		forgetter.ForgetPattern(pattern)
+	})
+}
+
+// Start runs the rules in the background until Stop is called.
+func (r *Scheduler) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop != nil {
+		return
+	}
+	r.stop, r.done = make(chan struct{}), make(chan struct{})
+	go r.loop(r.stop, r.done)
+}
+
+// Stop stops running the rules, waiting for a run in progress to finish.
+func (r *Scheduler) Stop() {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (r *Scheduler) add(spec string, run func()) error {
+	s, err := parseSchedule(spec)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.rules = append(r.rules, &scheduleRule{schedule: s, next: s.next(time.Now()), run: run})
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (r *Scheduler) loop(stop, done chan struct{}) {
+	defer close(done)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-r.wake:
+		case now := <-timer.C:
+			r.run(now)
+		}
+
+		timer.Stop()
+		if next := r.next(); !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// run runs the rules due at now, scheduling their next run.
+func (r *Scheduler) run(now time.Time) {
+	r.mu.Lock()
+	var due []*scheduleRule
+	for _, rule := range r.rules {
+		if !rule.next.IsZero() && !rule.next.After(now) {
+			due = append(due, rule)
+			rule.next = rule.schedule.next(now)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, rule := range due {
+		rule.run()
+	}
+}
+
+// next returns when the next rule is due, zero if none is.
+func (r *Scheduler) next() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var res time.Time
+	for _, rule := range r.rules {
+		if !rule.next.IsZero() && (res.IsZero() || rule.next.Before(res)) {
+			res = rule.next
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type scopeState struct {
+	prefix string
+	mu     sync.Mutex
+	keys   map[string]struct{}
+}
+
+// Scope is a child store whose items are all removed when it's closed, see NewScope.
+type Scope struct {
+	Cache
+	parent Cache
+	state  *scopeState
+}
+
+// NewScope returns a child store of instance keeping its items under name+":",
+// and tracking every key it writes so Flush and Close remove them from instance,
+// e.g. for the items of a job or a test. Closing the scope leaves instance open.
+func NewScope(instance Cache, name string) *Scope {
+	r := &Scope{
+		parent: instance,
+		state:  &scopeState{prefix: name + ":", keys: make(map[string]struct{})},
+	}
+	r.Cache = Use(instance, r.scope)
+
+	return r
+}
+
+// Scope returns a child store of the driver, see NewScope.
+func (r *Memory) Scope(name string) *Scope {
+	return NewScope(r, name)
+}
+
+func (r *Scope) scope(next Handler) Handler {
+	return func(ctx context.Context, op *Op) (any, error) {
+		switch op.Name {
+		case "Flush":
+			r.forgetAll()
+			return true, nil
+		case "Forget", "Pull":
+			op.Key = r.state.prefix + op.Key
+			res, err := next(ctx, op)
+			r.state.mu.Lock()
+			delete(r.state.keys, op.Key)
+			r.state.mu.Unlock()
+			return res, err
+		case "Add", "Decrement", "Forever", "Increment", "Put", "Remember", "RememberForever":
+			op.Key = r.state.prefix + op.Key
+			r.state.mu.Lock()
+			r.state.keys[op.Key] = struct{}{}
+			r.state.mu.Unlock()
+			return next(ctx, op)
+		default:
+			op.Key = r.state.prefix + op.Key
+			return next(ctx, op)
+		}
+	}
+}
+
+// Keys returns how many keys the scope wrote and didn't remove yet.
+func (r *Scope) Keys() int {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+
+	return len(r.state.keys)
+}
+
+func (r *Scope) forgetAll() {
+	r.state.mu.Lock()
+	keys := r.state.keys
+	r.state.keys = make(map[string]struct{})
+	r.state.mu.Unlock()
+
+	for key := range keys {
+		r.parent.Forget(key)
+	}
+}
+
+// Close removes every item of the scope from the parent store.
+func (r *Scope) Close(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.forgetAll()
+	return nil
+}
+
+func (r *Scope) Lock(key string, t ...time.Duration) *Lock {
+	return NewLock(r, key, t...)
+}
+
+func (r *Scope) Pipeline() *Pipeline {
+	return NewPipeline(r)
+}
+
+func (r *Scope) WithContext(ctx context.Context) Cache {
+	return &Scope{Cache: r.Cache.WithContext(ctx), parent: r.parent, state: r.state}
+}
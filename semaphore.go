@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var errNotHeld = errors.New("cache: permit is no longer held")
+
+// Semaphore lets up to limit holders in at once across everything sharing the cache.
+// Each holder occupies a slot for at most ttl, so slots of crashed holders free up.
+type Semaphore struct {
+	store Cache
+	key   string
+	limit int
+	ttl   time.Duration
+}
+
+// Permit is a slot held in a Semaphore.
+type Permit struct {
+	store Cache
+	key   string
+	id    string
+}
+
+func NewSemaphore(instance Cache, key string, limit int, ttl time.Duration) *Semaphore {
+	return &Semaphore{
+		store: instance,
+		key:   key,
+		limit: limit,
+		ttl:   ttl,
+	}
+}
+
+// Acquire takes a free slot, returning false if all of them are held.
+func (r *Semaphore) Acquire() (*Permit, bool) {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	permit := &Permit{store: r.store, id: hex.EncodeToString(id)}
+
+	for i := 0; i < r.limit; i++ {
+		key := r.slot(i)
+		if r.store.Add(key, permit.id, r.ttl) {
+			permit.key = key
+			return permit, true
+		}
+	}
+
+	return nil, false
+}
+
+// Block waits up to t for a free slot, trying again every 100 milliseconds.
+func (r *Semaphore) Block(t time.Duration) (*Permit, bool) {
+	timer := time.NewTimer(t)
+	defer timer.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if permit, ok := r.Acquire(); ok {
+			return permit, true
+		}
+
+		select {
+		case <-timer.C:
+			return r.Acquire()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Held returns how many slots are currently held.
+func (r *Semaphore) Held() int {
+	var n int
+	for i := 0; i < r.limit; i++ {
+		if r.store.Has(r.slot(i)) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (r *Semaphore) slot(i int) string {
+	return r.key + ":" + strconv.Itoa(i)
+}
+
+// Release frees the slot, unless it expired and was taken by another holder in the meantime.
+func (r *Permit) Release() bool {
+	err := r.store.Transaction(context.Background(), func(tx Cache) error {
+		if tx.Get(r.key) != r.id {
+			return errNotHeld
+		}
+
+		tx.Forget(r.key)
+		return nil
+	})
+
+	return err == nil
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Series counts events in time buckets, see CounterSeries.
+type Series struct {
+	cache     Cache
+	key       string
+	bucket    time.Duration
+	retention time.Duration
+	clock     Clock
+}
+
+// CounterSeries returns counters of key in buckets of the given duration, e.g. a minute or an hour,
+// each kept for retention once it starts, for lightweight analytics such as requests per minute.
+// Buckets are aligned on multiples of their duration since the Unix epoch and stored under key,
+// a colon and their index.
+func CounterSeries(instance Cache, key string, bucket, retention time.Duration) *Series {
+	return &Series{
+		cache:     instance,
+		key:       key,
+		bucket:    bucket,
+		retention: retention,
+	}
+}
+
+func (r *Series) now() time.Time {
+	if r.clock != nil {
+		return r.clock.Now()
+	}
+
+	return time.Now()
+}
+
+func (r *Series) bucketKey(index int64) string {
+	return fmt.Sprintf("%s:%d", r.key, index)
+}
+
+// Add adds delta to the current bucket, returning its count.
+func (r *Series) Add(delta int64) (int64, error) {
+	key := r.bucketKey(r.now().UnixNano() / int64(r.bucket))
+
+	return incrementCounter(r.cache, key, delta, r.retention)
+}
+
+// Counts returns the counts of the buckets overlapping the last window, oldest first,
+// the current bucket being last. Buckets gone past their retention count 0.
+func (r *Series) Counts(window time.Duration) []int64 {
+	n := max(int64((window+r.bucket-1)/r.bucket), 1)
+	current := r.now().UnixNano() / int64(r.bucket)
+
+	res := make([]int64, 0, n)
+	for index := current - n + 1; index <= current; index++ {
+		res = append(res, r.cache.GetInt64(r.bucketKey(index)))
+	}
+
+	return res
+}
+
+// Sum returns the total of the buckets overlapping the last window.
+func (r *Series) Sum(window time.Duration) int64 {
+	var res int64
+	for _, count := range r.Counts(window) {
+		res += count
+	}
+
+	return res
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// ShadowStats counts how the candidate of a Shadow compared against the primary.
+type ShadowStats struct {
+	// Reads is the number of reads compared.
+	Reads uint64
+	// Misses is the number of reads the primary answered but the candidate missed.
+	Misses uint64
+	// Mismatches is the number of reads and counters the candidate answered differently.
+	Mismatches uint64
+	// Writes is the number of writes mirrored to the candidate.
+	Writes uint64
+	// Errors is the number of operations the candidate failed.
+	Errors uint64
+}
+
+type shadowStats struct {
+	reads, misses, mismatches, writes, errors atomic.Uint64
+}
+
+// ShadowCache serves every operation from the primary and mirrors it to the candidate,
+// see Shadow.
+type ShadowCache struct {
+	Cache
+	primary   Cache
+	candidate Cache
+	stats     *shadowStats
+}
+
+// Shadow returns a cache serving from primary while mirroring reads and writes to candidate,
+// recording where the candidate diverges, to validate a new backend before migrating to it.
+// The candidate never affects what callers see. Remember callbacks only run against primary,
+// their result is written to the candidate. Transactions run on primary alone.
+func Shadow(primary, candidate Cache) *ShadowCache {
+	r := &ShadowCache{
+		primary:   primary,
+		candidate: candidate,
+		stats:     &shadowStats{},
+	}
+	r.Cache = Use(primary, r.mirror)
+
+	return r
+}
+
+// Stats returns the divergence recorded so far.
+func (r *ShadowCache) Stats() ShadowStats {
+	return ShadowStats{
+		Reads:      r.stats.reads.Load(),
+		Misses:     r.stats.misses.Load(),
+		Mismatches: r.stats.mismatches.Load(),
+		Writes:     r.stats.writes.Load(),
+		Errors:     r.stats.errors.Load(),
+	}
+}
+
+func (r *ShadowCache) mirror(next Handler) Handler {
+	candidate := dispatch(r.candidate)
+
+	return func(ctx context.Context, op *Op) (any, error) {
+		res, err := next(ctx, op)
+
+		switch op.Name {
+		case "Get", "GetExists", "Has", "Pull":
+			if err != nil && !errors.Is(err, ErrKeyNotFound) {
+				break
+			}
+			shadow, shadowErr := candidate(ctx, op)
+			r.compare(res, err, shadow, shadowErr)
+		case "Increment", "Decrement":
+			if err != nil {
+				break
+			}
+			r.stats.writes.Add(1)
+			shadow, shadowErr := candidate(ctx, op)
+			if shadowErr != nil {
+				r.stats.errors.Add(1)
+			} else if shadow != res {
+				r.stats.mismatches.Add(1)
+			}
+		case "Remember", "RememberForever":
+			if err != nil {
+				break
+			}
+			ttl := op.TTL
+			if op.Name == "RememberForever" {
+				ttl = NoExpiration
+			}
+			shadow, shadowErr := candidate(ctx, &Op{Name: "GetExists", Key: op.Key})
+			r.compare(res, nil, shadow, shadowErr)
+			if shadowErr != nil {
+				r.write(ctx, candidate, &Op{Name: "Put", Key: op.Key, Value: res, Opts: []PutOption{WithTTL(ttl)}})
+			}
+		default:
+			if err == nil {
+				r.write(ctx, candidate, op)
+			}
+		}
+
+		return res, err
+	}
+}
+
+// compare records how the candidate answered a read the primary answered with res and err.
+func (r *ShadowCache) compare(res any, err error, shadow any, shadowErr error) {
+	r.stats.reads.Add(1)
+
+	switch {
+	case shadowErr != nil && !errors.Is(shadowErr, ErrKeyNotFound):
+		r.stats.errors.Add(1)
+	case err == nil && shadowErr != nil, res != nil && shadow == nil:
+		r.stats.misses.Add(1)
+	case (err == nil) != (shadowErr == nil), !reflect.DeepEqual(res, shadow):
+		r.stats.mismatches.Add(1)
+	}
+}
+
+func (r *ShadowCache) write(ctx context.Context, candidate Handler, op *Op) {
+	r.stats.writes.Add(1)
+	if _, err := candidate(ctx, op); err != nil {
+		r.stats.errors.Add(1)
+	}
+}
+
+// Close closes both the primary and the candidate.
+func (r *ShadowCache) Close(ctx context.Context) error {
+	return errors.Join(r.primary.Close(ctx), r.candidate.Close(ctx))
+}
+
+func (r *ShadowCache) Lock(key string, t ...time.Duration) *Lock {
+	return NewLock(r, key, t...)
+}
+
+func (r *ShadowCache) Pipeline() *Pipeline {
+	return NewPipeline(r)
+}
+
+// Transaction runs fn on the primary only, its writes aren't mirrored.
+func (r *ShadowCache) Transaction(ctx context.Context, fn func(tx Cache) error) error {
+	return r.primary.Transaction(ctx, fn)
+}
+
+func (r *ShadowCache) WithContext(ctx context.Context) Cache {
+	return &ShadowCache{Cache: r.Cache.WithContext(ctx), primary: r.primary, candidate: r.candidate, stats: r.stats}
+}
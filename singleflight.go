@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	val any
+	err error
+}
+
+// flightGroup runs a function once per key at a time, handing its result to every caller waiting on the key.
+// A panic of the function is returned to all of them as a *PanicError.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+func (r *flightGroup) do(key string, fn func() (any, error)) (any, error) {
+	r.mu.Lock()
+	if r.calls == nil {
+		r.calls = make(map[string]*flightCall)
+	}
+	if c, ok := r.calls[key]; ok {
+		r.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+
+	c := &flightCall{}
+	c.wg.Add(1)
+	r.calls[key] = c
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.calls, key)
+		r.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.val, c.err = safeCall(fn)
+	return c.val, c.err
+}
+
+// doResult is what DoDistributed hands to the callers waiting on a call.
+type doResult struct {
+	Value any
+	Err   string
+}
+
+// DoDistributed runs fn once per key at a time across everything sharing the cache, handing
+// its result to every caller asking for the key meanwhile, as Do does within a process.
+// The caller running fn holds key+":do" for up to lockTTL, storing there an ID of its call,
+// and the others wait for the result it stores for lockTTL under a key of that ID, trying to
+// take over if it isn't stored in time. Errors reach the other callers with their message only.
+// An error is returned when the cache refuses the lock, e.g. for being read-only.
+func DoDistributed(instance Cache, key string, lockTTL time.Duration, fn func() (any, error)) (any, error) {
+	lockKey := key + ":do"
+	for missing := 0; ; {
+		id := make([]byte, 8)
+		_, _ = rand.Read(id)
+		call := hex.EncodeToString(id)
+		if instance.Add(lockKey, call, lockTTL) {
+			val, err := safeCall(fn)
+			res := doResult{Value: val}
+			if err != nil {
+				res.Err = err.Error()
+			}
+			_ = instance.Put(lockKey+":"+call, res, WithTTL(lockTTL))
+			// The lock may have expired and been taken by another call meanwhile.
+			if instance.Get(lockKey) == call {
+				instance.Forget(lockKey)
+			}
+			return val, err
+		}
+
+		// The call holding the lock is the one whose result to wait for; if it's gone, take over.
+		call, ok := instance.Get(lockKey).(string)
+		if !ok {
+			// The lock being missing more than a few times means the cache refuses it.
+			if missing++; missing == 3 {
+				return nil, fmt.Errorf("cache: lock %s can't be stored", lockKey)
+			}
+			continue
+		}
+		val, err := WaitFor(context.Background(), instance, lockKey+":"+call, lockTTL)
+		if errors.Is(err, ErrWaitTimeout) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		res, ok := val.(doResult)
+		if !ok {
+			return nil, fmt.Errorf("cache: value of key %s:%s is %T, not cache.doResult", lockKey, call, val)
+		}
+		if res.Err != "" {
+			return res.Value, errors.New(res.Err)
+		}
+		return res.Value, nil
+	}
+}
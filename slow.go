@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// WithSlowThreshold is a middleware calling callback for every operation taking longer than d,
+// with the name of the operation, its key and how long it took.
+func WithSlowThreshold(d time.Duration, callback func(op, key string, dur time.Duration)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Op) (any, error) {
+			start := time.Now()
+			res, err := next(ctx, op)
+			if dur := time.Since(start); dur > d {
+				callback(op.Name, op.Key, dur)
+			}
+
+			return res, err
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package cache
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/gob"
+	"math/rand/v2"
+	"sync"
+)
+
+// zMaxLevel bounds the height of the skiplist, plenty for 4^32 members.
+const zMaxLevel = 32
+
+// SortedSetter is implemented by stores holding sets of members ordered by score, as Redis sorted sets.
+// Members with the same score are ordered by name.
+type SortedSetter interface {
+	// ZAdd adds members to the set of key, creating it if missing, updating the score of those
+	// already there. It returns how many members were added.
+	ZAdd(key string, members ...ZMember) (int, error)
+	// ZRem removes members from the set of key, returning how many were there.
+	ZRem(key string, members ...string) (int, error)
+	// ZScore returns the score of member in the set of key, reporting whether it's there.
+	ZScore(key, member string) (float64, bool, error)
+	// ZRange returns the members ranked from start to stop included, lowest score first.
+	// Negative ranks count from the end, -1 being the last member.
+	ZRange(key string, start, stop int64) ([]ZMember, error)
+	// ZRevRange is ZRange with the highest score first.
+	ZRevRange(key string, start, stop int64) ([]ZMember, error)
+	// ZCard returns the number of members in the set of key.
+	ZCard(key string) (int64, error)
+}
+
+// ZMember is a member of a sorted set.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+type zNode struct {
+	ZMember
+	prev *zNode
+	next []*zNode
+}
+
+// SortedSet is a skiplist of members ordered by score. It's safe for concurrent use.
+type SortedSet struct {
+	mu     sync.RWMutex
+	head   *zNode
+	tail   *zNode
+	level  int
+	scores map[string]float64
+}
+
+func NewSortedSet() *SortedSet {
+	return &SortedSet{
+		head:   &zNode{next: make([]*zNode, zMaxLevel)},
+		level:  1,
+		scores: make(map[string]float64),
+	}
+}
+
+func zLess(a, b ZMember) bool {
+	if c := cmp.Compare(a.Score, b.Score); c != 0 {
+		return c < 0
+	}
+
+	return a.Member < b.Member
+}
+
+// Add adds members, updating the score of those already there, and returns how many were added.
+func (r *SortedSet) Add(members ...ZMember) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for _, member := range members {
+		if score, ok := r.scores[member.Member]; ok {
+			if score == member.Score {
+				continue
+			}
+			r.delete(ZMember{Member: member.Member, Score: score})
+		} else {
+			n++
+		}
+		r.insert(member)
+		r.scores[member.Member] = member.Score
+	}
+
+	return n
+}
+
+// Remove removes members, returning how many were there.
+func (r *SortedSet) Remove(members ...string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for _, member := range members {
+		if score, ok := r.scores[member]; ok {
+			r.delete(ZMember{Member: member, Score: score})
+			delete(r.scores, member)
+			n++
+		}
+	}
+
+	return n
+}
+
+// Score returns the score of member, reporting whether it's there.
+func (r *SortedSet) Score(member string) (float64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	score, ok := r.scores[member]
+	return score, ok
+}
+
+// Len returns the number of members.
+func (r *SortedSet) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.scores)
+}
+
+// Range returns the members ranked from start to stop included, lowest score first.
+// Negative ranks count from the end, -1 being the last member.
+func (r *SortedSet) Range(start, stop int64) []ZMember {
+	return r.rank(start, stop, false)
+}
+
+// RevRange is Range with the highest score first.
+func (r *SortedSet) RevRange(start, stop int64) []ZMember {
+	return r.rank(start, stop, true)
+}
+
+func (r *SortedSet) rank(start, stop int64, reverse bool) []ZMember {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	n := int64(len(r.scores))
+	if start < 0 {
+		start = max(start+n, 0)
+	}
+	if stop < 0 {
+		stop += n
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return nil
+	}
+
+	node := r.head.next[0]
+	if reverse {
+		node = r.tail
+	}
+	for i := int64(0); i < start; i++ {
+		node = r.step(node, reverse)
+	}
+
+	res := make([]ZMember, 0, stop-start+1)
+	for i := start; i <= stop; i++ {
+		res = append(res, node.ZMember)
+		node = r.step(node, reverse)
+	}
+
+	return res
+}
+
+func (r *SortedSet) step(node *zNode, reverse bool) *zNode {
+	if reverse {
+		return node.prev
+	}
+
+	return node.next[0]
+}
+
+func (r *SortedSet) insert(member ZMember) {
+	var update [zMaxLevel]*zNode
+	x := r.head
+	for i := r.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && zLess(x.next[i].ZMember, member) {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+
+	level := 1
+	for level < zMaxLevel && rand.IntN(4) == 0 {
+		level++
+	}
+	for i := r.level; i < level; i++ {
+		update[i] = r.head
+	}
+	r.level = max(r.level, level)
+
+	node := &zNode{ZMember: member, next: make([]*zNode, level)}
+	for i := 0; i < level; i++ {
+		node.next[i] = update[i].next[i]
+		update[i].next[i] = node
+	}
+	if update[0] != r.head {
+		node.prev = update[0]
+	}
+	if node.next[0] != nil {
+		node.next[0].prev = node
+	} else {
+		r.tail = node
+	}
+}
+
+func (r *SortedSet) delete(member ZMember) {
+	var update [zMaxLevel]*zNode
+	x := r.head
+	for i := r.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && zLess(x.next[i].ZMember, member) {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+
+	node := x.next[0]
+	if node == nil || node.ZMember != member {
+		return
+	}
+	for i := 0; i < len(node.next); i++ {
+		update[i].next[i] = node.next[i]
+	}
+	if node.next[0] != nil {
+		node.next[0].prev = node.prev
+	} else {
+		r.tail = node.prev
+	}
+	for r.level > 1 && r.head.next[r.level-1] == nil {
+		r.level--
+	}
+}
+
+func (r *SortedSet) Clone() any {
+	res := NewSortedSet()
+	res.Add(r.Range(0, -1)...)
+
+	return res
+}
+
+// GobEncode encodes the members, so codecs such as GobCodec can store the set.
+func (r *SortedSet) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(r.Range(0, -1)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (r *SortedSet) GobDecode(data []byte) error {
+	var members []ZMember
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&members); err != nil {
+		return err
+	}
+
+	*r = SortedSet{
+		head:   &zNode{next: make([]*zNode, zMaxLevel)},
+		level:  1,
+		scores: make(map[string]float64),
+	}
+	r.Add(members...)
+	return nil
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Splitter migrates keys from one backend to another by percentage, see Split.
+type Splitter struct {
+	Cache
+	old    Cache
+	next   Cache
+	points *atomic.Int64
+}
+
+// Split returns a cache writing to both old and next, and serving the reads of percent
+// of the keys, picked by hash, from next and the rest from old. Raising the percentage
+// moves more keys over, lowering it rolls them back, both backends hold every write.
+// Remember callbacks run once, against the backend serving the key.
+// Transactions run on old alone.
+func Split(old, next Cache, percent float64) *Splitter {
+	r := &Splitter{
+		old:    old,
+		next:   next,
+		points: &atomic.Int64{},
+	}
+	r.SetPercent(percent)
+	r.Cache = Use(old, r.route)
+
+	return r
+}
+
+// SetPercent changes the percentage of keys served from the new backend, clamped to [0, 100].
+func (r *Splitter) SetPercent(percent float64) {
+	r.points.Store(int64(math.Round(math.Max(0, math.Min(100, percent)) * 100)))
+}
+
+// Percent returns the percentage of keys served from the new backend.
+func (r *Splitter) Percent() float64 {
+	return float64(r.points.Load()) / 100
+}
+
+// Routed reports whether key is served from the new backend.
+func (r *Splitter) Routed(key string) bool {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	return int64(h.Sum64()%10000) < r.points.Load()
+}
+
+func (r *Splitter) route(old Handler) Handler {
+	next := dispatch(r.next)
+
+	return func(ctx context.Context, op *Op) (any, error) {
+		serve, other := old, next
+		if op.Key != "" && r.Routed(op.Key) {
+			serve, other = next, old
+		}
+
+		switch op.Name {
+		case "Get", "GetExists", "Has":
+			return serve(ctx, op)
+		case "Remember", "RememberForever":
+			res, err := serve(ctx, op)
+			if err != nil {
+				return nil, err
+			}
+			ttl := op.TTL
+			if op.Name == "RememberForever" {
+				ttl = NoExpiration
+			}
+			_, _ = other(ctx, &Op{Name: "Put", Key: op.Key, Value: res, Opts: []PutOption{WithTTL(ttl)}})
+			return res, nil
+		default:
+			res, err := serve(ctx, op)
+			_, _ = other(ctx, op)
+			return res, err
+		}
+	}
+}
+
+// Close closes both backends.
+func (r *Splitter) Close(ctx context.Context) error {
+	return errors.Join(r.old.Close(ctx), r.next.Close(ctx))
+}
+
+func (r *Splitter) Lock(key string, t ...time.Duration) *Lock {
+	return NewLock(r, key, t...)
+}
+
+func (r *Splitter) Pipeline() *Pipeline {
+	return NewPipeline(r)
+}
+
+// Transaction runs fn on the old backend only, its writes don't reach the new one.
+func (r *Splitter) Transaction(ctx context.Context, fn func(tx Cache) error) error {
+	return r.old.Transaction(ctx, fn)
+}
+
+func (r *Splitter) WithContext(ctx context.Context) Cache {
+	return &Splitter{Cache: r.Cache.WithContext(ctx), old: r.old, next: r.next, points: r.points}
+}
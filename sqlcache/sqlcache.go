@@ -0,0 +1,179 @@
+// Package sqlcache caches the results of database/sql queries, keyed by their normalized SQL
+// and arguments, and invalidates them by table when statements write to the tables they read.
+package sqlcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-rat/cache"
+)
+
+// keyPrefix starts the keys of results and table versions.
+const keyPrefix = "sqlcache:"
+
+// tablePattern matches the tables named by a statement.
+var tablePattern = regexp.MustCompile("(?i)\\b(?:from|join|into|update|truncate(?:\\s+table)?|(?:alter|drop)\\s+table(?:\\s+if\\s+exists)?)\\s+([`\"\\[]?[\\w.]+)")
+
+// Result is the result of a query, read in full.
+type Result struct {
+	Columns []string
+	Rows    [][]any
+}
+
+// DB runs queries on a *sql.DB, serving their results from a cache while the tables they read
+// don't change.
+//
+// Every table has a version in the cache, which is part of the keys of the queries reading it,
+// so bumping it with Invalidate orphans them at once, to expire with their TTL. Exec bumps the
+// tables of the statements it runs, and Invalidate is there for the writes going elsewhere,
+// e.g. to call from the hooks of an ORM or a change feed.
+type DB struct {
+	db    *sql.DB
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// New creates a DB caching the results of the queries run on db in instance for ttl.
+func New(db *sql.DB, instance cache.Cache, ttl time.Duration) *DB {
+	return &DB{
+		db:    db,
+		cache: instance,
+		ttl:   ttl,
+	}
+}
+
+// Query runs query with args, or returns its cached result. Queries are told apart by their SQL
+// as Normalize makes it and by their arguments, and invalidated by the tables they name.
+// The database is sent query as it is.
+func (r *DB) Query(ctx context.Context, query string, args ...any) (*Result, error) {
+	key := r.key(Normalize(query), args)
+
+	val, err := r.cache.WithContext(ctx).Remember(key, r.ttl, func() (any, error) {
+		return r.query(ctx, query, args)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, ok := val.(*Result)
+	if !ok {
+		return nil, fmt.Errorf("sqlcache: value of key %s is %T, not *sqlcache.Result", key, val)
+	}
+
+	return res, nil
+}
+
+func (r *DB) query(ctx context.Context, query string, args []any) (*Result, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := &Result{}
+	if res.Columns, err = rows.Columns(); err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		row := make([]any, len(res.Columns))
+		dest := make([]any, len(row))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		res.Rows = append(res.Rows, row)
+	}
+
+	return res, rows.Err()
+}
+
+// Exec runs a statement with args, then invalidates the tables it names.
+func (r *DB) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, r.Invalidate(Tables(query)...)
+}
+
+// Invalidate drops the cached results of the queries reading tables.
+func (r *DB) Invalidate(tables ...string) error {
+	for _, table := range tables {
+		if _, err := r.cache.Increment(keyPrefix + "table:" + strings.ToLower(table)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// key returns the key of the result of query, which changes with the versions of its tables.
+func (r *DB) key(query string, args []any) string {
+	h := sha256.New()
+	_, _ = h.Write([]byte(query))
+	for _, arg := range args {
+		_, _ = fmt.Fprintf(h, "\x00%T:%v", arg, arg)
+	}
+	for _, table := range Tables(query) {
+		_, _ = fmt.Fprintf(h, "\x00%s:%d", table, r.cache.GetInt64(keyPrefix+"table:"+table))
+	}
+
+	return keyPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// Normalize collapses the whitespace of query outside of quotes, so queries differing only by it
+// share their results, while those differing within a string literal or quoted name don't.
+func Normalize(query string) string {
+	var b strings.Builder
+	var quote rune
+	space := false
+	for _, c := range strings.TrimSpace(query) {
+		switch {
+		case quote != 0:
+			// A doubled quote is an escaped one, closing and reopening the same quote.
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case unicode.IsSpace(c):
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// Tables returns the tables named by query after FROM, JOIN, INTO, UPDATE, TRUNCATE, ALTER TABLE
+// and DROP TABLE, lowercased and sorted.
+// Quoted names are unquoted; names built in other ways, e.g. in views, aren't seen.
+func Tables(query string) []string {
+	var res []string
+	for _, match := range tablePattern.FindAllStringSubmatch(query, -1) {
+		table := strings.ToLower(strings.Trim(match[1], "`\"[]"))
+		if !slices.Contains(res, table) {
+			res = append(res, table)
+		}
+	}
+	slices.Sort(res)
+
+	return res
+}
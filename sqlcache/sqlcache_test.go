@@ -0,0 +1,177 @@
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/go-rat/cache"
+)
+
+// fakeDriver serves a single users row, whose name is set by any statement.
+type fakeDriver struct {
+	name    atomic.Value
+	last    atomic.Value
+	queries atomic.Int32
+}
+
+func (r *fakeDriver) Open(string) (driver.Conn, error) {
+	return fakeConn{r}, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (r fakeConn) Prepare(query string) (driver.Stmt, error) {
+	r.driver.last.Store(query)
+	return fakeStmt(r), nil
+}
+
+func (r fakeConn) Close() error {
+	return nil
+}
+
+func (r fakeConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+type fakeStmt struct {
+	driver *fakeDriver
+}
+
+func (r fakeStmt) Close() error {
+	return nil
+}
+
+func (r fakeStmt) NumInput() int {
+	return -1
+}
+
+func (r fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	r.driver.name.Store(args[0])
+	return driver.RowsAffected(1), nil
+}
+
+func (r fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	r.driver.queries.Add(1)
+	return &fakeRows{name: r.driver.name.Load()}, nil
+}
+
+type fakeRows struct {
+	name any
+	done bool
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"id", "name"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0], dest[1] = int64(1), r.name
+	return nil
+}
+
+var fake = &fakeDriver{}
+
+func init() {
+	sql.Register("sqlcache-fake", fake)
+}
+
+type SQLCacheTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+func TestSQLCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(SQLCacheTestSuite))
+}
+
+func (s *SQLCacheTestSuite) SetupTest() {
+	db, err := sql.Open("sqlcache-fake", "")
+	s.Require().Nil(err)
+	s.T().Cleanup(func() { _ = db.Close() })
+
+	fake.name.Store("Rat")
+	fake.queries.Store(0)
+	s.db = New(db, cache.NewMemory(), 1*time.Second)
+}
+
+func (s *SQLCacheTestSuite) TestQuery() {
+	ctx := context.Background()
+	res, err := s.db.Query(ctx, "SELECT id, name FROM users WHERE id = ?", 1)
+	s.Nil(err)
+	s.Equal([]string{"id", "name"}, res.Columns)
+	s.Equal([][]any{{int64(1), "Rat"}}, res.Rows)
+
+	res, err = s.db.Query(ctx, "SELECT id, name\n\tFROM users  WHERE id = ?", 1)
+	s.Nil(err)
+	s.Equal([][]any{{int64(1), "Rat"}}, res.Rows)
+	s.Equal(int32(1), fake.queries.Load())
+
+	// Queries are sent as they are, and differ by the content of their literals.
+	_, err = s.db.Query(ctx, "SELECT id FROM users WHERE name = 'Rat  Go'")
+	s.Nil(err)
+	s.Equal("SELECT id FROM users WHERE name = 'Rat  Go'", fake.last.Load())
+	_, err = s.db.Query(ctx, "SELECT id FROM users WHERE name = 'Rat Go'")
+	s.Nil(err)
+	s.Equal(int32(3), fake.queries.Load())
+
+	// Other arguments are other queries.
+	_, err = s.db.Query(ctx, "SELECT id, name FROM users WHERE id = ?", 2)
+	s.Nil(err)
+	s.Equal(int32(4), fake.queries.Load())
+}
+
+func (s *SQLCacheTestSuite) TestInvalidate() {
+	ctx := context.Background()
+	query := "SELECT u.id, u.name FROM users u JOIN teams t ON t.id = u.team_id"
+	_, err := s.db.Query(ctx, query)
+	s.Nil(err)
+
+	_, err = s.db.Exec(ctx, "UPDATE `Users` SET name = ?", "Cat")
+	s.Nil(err)
+	res, err := s.db.Query(ctx, query)
+	s.Nil(err)
+	s.Equal([][]any{{int64(1), "Cat"}}, res.Rows)
+	s.Equal(int32(2), fake.queries.Load())
+
+	_, err = s.db.Query(ctx, query)
+	s.Nil(err)
+	s.Equal(int32(2), fake.queries.Load())
+	s.Nil(s.db.Invalidate("teams"))
+	_, err = s.db.Query(ctx, query)
+	s.Nil(err)
+	s.Equal(int32(3), fake.queries.Load())
+
+	// Unrelated tables keep the result.
+	s.Nil(s.db.Invalidate("orders"))
+	_, err = s.db.Query(ctx, query)
+	s.Nil(err)
+	s.Equal(int32(3), fake.queries.Load())
+}
+
+func (s *SQLCacheTestSuite) TestTables() {
+	s.Equal([]string{"orders", "users"}, Tables(`SELECT * FROM "Users" u LEFT JOIN orders o ON o.user_id = u.id`))
+	s.Equal([]string{"public.users"}, Tables("INSERT INTO public.users (name) VALUES (?)"))
+	s.Equal([]string{"users"}, Tables("DELETE FROM [users] WHERE id = ?"))
+	s.Equal([]string{"users"}, Tables("TRUNCATE TABLE users"))
+	s.Equal([]string{"users"}, Tables("truncate users"))
+	s.Equal([]string{"orders", "users"}, Tables("DROP TABLE IF EXISTS orders; ALTER TABLE users ADD age int"))
+	s.Equal("SELECT * FROM users", Normalize("  SELECT *\n  FROM users "))
+	s.Equal("SELECT 'a  b', \"c\td\" FROM users WHERE name = 'it''s  here'", Normalize("SELECT 'a  b',\n \"c\td\"  FROM users WHERE name = 'it''s  here'"))
+}
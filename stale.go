@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// ServeStale is a middleware keeping a copy of what Remember and RememberForever compute for
+// staleTTL, or indefinitely if NoExpiration, and returning it when their callback fails later on,
+// e.g. during an outage of the upstream, long after the item itself expired. Every failure served
+// that way is reported to onError, if not nil, e.g. to count them. The stale copy isn't stored as
+// the item, so the next call tries the callback again. Copies are kept under "stale:" and the key.
+func ServeStale(staleTTL time.Duration, onError func(key string, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Op) (any, error) {
+			if op.Name != "Remember" && op.Name != "RememberForever" {
+				return next(ctx, op)
+			}
+
+			key, callback := op.Key, op.Callback
+			op.Callback = func() (any, error) {
+				val, err := callback()
+				if err == nil {
+					_, _ = next(ctx, &Op{Name: "Put", Key: "stale:" + key, Value: val, Opts: []PutOption{WithTTL(staleTTL)}})
+				}
+				return val, err
+			}
+
+			res, err := next(ctx, op)
+			if err == nil {
+				return res, nil
+			}
+
+			stale, serr := next(ctx, &Op{Name: "GetExists", Key: "stale:" + key})
+			if serr != nil {
+				return res, err
+			}
+			if onError != nil {
+				onError(key, err)
+			}
+			return stale, nil
+		}
+	}
+}
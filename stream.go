@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+)
+
+// streamChunkSize is the size of the chunks PutReader splits payloads into.
+const streamChunkSize = 256 << 10
+
+// Streamer is implemented by stores that can write and read items as streams natively.
+type Streamer interface {
+	// PutReader stores what rd yields under key, for t or indefinitely if NoExpiration.
+	PutReader(key string, rd io.Reader, t time.Duration) error
+	// GetReader returns a reader of the item of key, reporting whether it exists.
+	GetReader(key string) (io.ReadCloser, bool)
+}
+
+// streamManifest is stored under the key of a chunked payload, the chunks being stored
+// under the key followed by the ID and their index.
+type streamManifest struct {
+	ID     string
+	Chunks int
+	Size   int64
+	// Checksum is the CRC-32C of the payload.
+	Checksum uint32
+	// Text reports whether the payload was a string rather than a []byte.
+	Text bool
+}
+
+func newStreamManifest() streamManifest {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return streamManifest{ID: hex.EncodeToString(id)}
+}
+
+func (r streamManifest) chunk(key string, i int) string {
+	return fmt.Sprintf("%s:%s:%d", key, r.ID, i)
+}
+
+// verify checks that the chunks read add up to the payload written.
+func (r streamManifest) verify(key string, size int64, checksum uint32) error {
+	if size != r.Size {
+		return fmt.Errorf("%w: %s is %d bytes, not %d", ErrChunkMissing, key, size, r.Size)
+	}
+	if checksum != r.Checksum {
+		return fmt.Errorf("%w: chunks of %s", ErrChecksumMismatch, key)
+	}
+
+	return nil
+}
+
+// PutReader stores what rd yields under key, for t or indefinitely if NoExpiration, without
+// holding the whole payload at once. Stores that aren't a Streamer get it as chunks of 256 KiB
+// and a manifest under key, written last, so readers see either the old payload or the new one.
+func PutReader(instance Cache, key string, rd io.Reader, t time.Duration) error {
+	if streamer, ok := instance.(Streamer); ok {
+		return streamer.PutReader(key, rd, t)
+	}
+
+	manifest := newStreamManifest()
+
+	forget := func(m streamManifest) {
+		for i := 0; i < m.Chunks; i++ {
+			instance.Forget(m.chunk(key, i))
+		}
+	}
+
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, err := io.ReadFull(rd, buf)
+		if n > 0 {
+			if err := instance.Put(manifest.chunk(key, manifest.Chunks), bytes.Clone(buf[:n]), WithTTL(t)); err != nil {
+				forget(manifest)
+				return err
+			}
+			manifest.Chunks++
+			manifest.Size += int64(n)
+			manifest.Checksum = crc32.Update(manifest.Checksum, crc32c, buf[:n])
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			forget(manifest)
+			return err
+		}
+	}
+
+	prev, chunked := instance.Get(key).(streamManifest)
+	if err := instance.Put(key, manifest, WithTTL(t)); err != nil {
+		forget(manifest)
+		return err
+	}
+	if chunked {
+		forget(prev)
+	}
+
+	return nil
+}
+
+// GetReader returns a reader of the item of key written by PutReader, reporting whether it exists.
+// Chunks are fetched as they're read; one gone missing or replaced meanwhile fails the read
+// with ErrChunkMissing or ErrChecksumMismatch.
+// String and []byte items are read as is.
+func GetReader(instance Cache, key string) (io.ReadCloser, bool) {
+	if streamer, ok := instance.(Streamer); ok {
+		return streamer.GetReader(key)
+	}
+
+	switch val := instance.Get(key).(type) {
+	case streamManifest:
+		return &chunkReader{instance: instance, key: key, manifest: val}, true
+	case []byte:
+		return io.NopCloser(bytes.NewReader(val)), true
+	case string:
+		return io.NopCloser(strings.NewReader(val)), true
+	default:
+		return nil, false
+	}
+}
+
+type chunkReader struct {
+	instance Cache
+	key      string
+	manifest streamManifest
+	next     int
+	read     int64
+	checksum uint32
+	buf      []byte
+	closed   bool
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	for len(r.buf) == 0 {
+		if r.next == r.manifest.Chunks {
+			if err := r.manifest.verify(r.key, r.read, r.checksum); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+
+		chunk, ok := r.instance.Get(r.manifest.chunk(r.key, r.next)).([]byte)
+		if !ok {
+			return 0, fmt.Errorf("%w: %d of %s", ErrChunkMissing, r.next, r.key)
+		}
+		r.buf = chunk
+		r.next++
+	}
+
+	n := copy(p, r.buf)
+	r.checksum = crc32.Update(r.checksum, crc32c, r.buf[:n])
+	r.buf = r.buf[n:]
+	r.read += int64(n)
+
+	return n, nil
+}
+
+func (r *chunkReader) Close() error {
+	r.buf, r.closed = nil, true
+	return nil
+}
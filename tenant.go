@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Tenants hands out an isolated Memory driver per tenant. Each tenant gets its own
+// items, limits and Flush, so one tenant can never evict or flush another's items.
+type Tenants struct {
+	opts []MemoryOption
+
+	mu     sync.RWMutex
+	stores map[string]*Memory
+}
+
+// NewTenants creates the facade, every tenant's driver is created with opts.
+func NewTenants(opts ...MemoryOption) *Tenants {
+	return &Tenants{
+		opts:   opts,
+		stores: make(map[string]*Memory),
+	}
+}
+
+// Tenant returns the cache of the tenant, creating it on first use.
+func (r *Tenants) Tenant(id string) Cache {
+	r.mu.RLock()
+	store, ok := r.stores[id]
+	r.mu.RUnlock()
+	if ok {
+		return store
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if store, ok = r.stores[id]; !ok {
+		store = NewMemory(r.opts...)
+		r.stores[id] = store
+	}
+
+	return store
+}
+
+// Tenants returns the ids of the tenants created so far.
+func (r *Tenants) Tenants() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.stores))
+	for id := range r.stores {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// Remove closes the cache of the tenant and drops it.
+func (r *Tenants) Remove(id string) {
+	r.mu.Lock()
+	store, ok := r.stores[id]
+	delete(r.stores, id)
+	r.mu.Unlock()
+
+	if ok {
+		_ = store.Close(context.Background())
+	}
+}
+
+// Close closes the caches of every tenant and drops them.
+func (r *Tenants) Close(ctx context.Context) error {
+	r.mu.Lock()
+	stores := r.stores
+	r.stores = make(map[string]*Memory)
+	r.mu.Unlock()
+
+	var errs []error
+	for _, store := range stores {
+		errs = append(errs, store.Close(ctx))
+	}
+
+	return errors.Join(errs...)
+}
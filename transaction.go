@@ -0,0 +1,363 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+type txRead struct {
+	value    any
+	entry    *memoryEntry
+	snapshot any
+	exist    bool
+}
+
+// changed reports whether e, the entry now stored under the key, differs from what was read.
+// Entries are immutable except for counters, so an entry still in place only changed if
+// its counter moved, while an entry replaced since changed unless it holds an equal value.
+func (r txRead) changed(e *memoryEntry) bool {
+	if _, counter := counterValue(e.value); e == r.entry && !counter {
+		return false
+	}
+
+	return !sameValue(r.snapshot, e.value)
+}
+
+type txWrite struct {
+	value   any
+	opts    []PutOption
+	deleted bool
+}
+
+// memoryTransaction stages writes against a Memory driver and applies them on commit,
+// provided none of the keys it observed changed in the meantime.
+type memoryTransaction struct {
+	ctx    context.Context
+	memory *Memory
+	reads  map[string]txRead
+	writes map[string]txWrite
+}
+
+func newMemoryTransaction(ctx context.Context, memory *Memory) *memoryTransaction {
+	return &memoryTransaction{
+		ctx:    ctx,
+		memory: memory,
+		reads:  make(map[string]txRead),
+		writes: make(map[string]txWrite),
+	}
+}
+
+// load returns the value of key as seen by the transaction.
+func (r *memoryTransaction) load(key string) (any, bool) {
+	if w, ok := r.writes[key]; ok {
+		return w.value, !w.deleted
+	}
+	if read, ok := r.reads[key]; ok {
+		return read.value, read.exist
+	}
+
+	var val, snapshot any
+	e, exist := r.memory.load(key)
+	if exist {
+		val = r.memory.output(e.value)
+		snapshot = snapshotValue(e.value)
+	}
+	r.reads[key] = txRead{value: val, entry: e, snapshot: snapshot, exist: exist}
+
+	return val, exist
+}
+
+func (r *memoryTransaction) store(key string, value any, opts ...PutOption) {
+	r.writes[key] = txWrite{value: value, opts: opts}
+}
+
+func (r *memoryTransaction) commit() error {
+	keys := make([]string, 0, len(r.reads)+len(r.writes))
+	for key := range r.reads {
+		keys = append(keys, key)
+	}
+	for key := range r.writes {
+		if _, ok := r.reads[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		r.memory.locks.Lock(key)
+	}
+	err := r.apply()
+	for _, key := range keys {
+		r.memory.locks.Unlock(key)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Dependents are forgotten once the locks are released, as they may be among the keys.
+	for key := range r.writes {
+		r.memory.cascade(key)
+	}
+
+	return nil
+}
+
+// apply checks the keys read are unchanged and writes those written, with their locks held.
+func (r *memoryTransaction) apply() error {
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+	if r.memory.readOnly.Load() {
+		return ErrReadOnly
+	}
+
+	for key, read := range r.reads {
+		e, exist := r.memory.load(key)
+		if exist != read.exist || (exist && read.changed(e)) {
+			return ErrTransactionConflict
+		}
+	}
+
+	for key, w := range r.writes {
+		r.memory.invalidate(key)
+		if w.deleted {
+			r.memory.remove(key)
+			continue
+		}
+		if _, _, err := r.memory.put(key, w.value, r.memory.putOptions(w.opts...)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryTransaction) Add(key string, value any, t time.Duration) bool {
+	if _, exist := r.load(key); exist {
+		return false
+	}
+
+	r.store(key, value, WithTTL(t))
+	return true
+}
+
+// Close is not supported inside a transaction, it does nothing.
+func (r *memoryTransaction) Close(context.Context) error {
+	return nil
+}
+
+func (r *memoryTransaction) Decrement(key string, value ...int64) (int64, error) {
+	if len(value) == 0 {
+		value = append(value, 1)
+	}
+
+	return r.Increment(key, -value[0])
+}
+
+func (r *memoryTransaction) Forever(key string, value any) bool {
+	r.store(key, value)
+	return true
+}
+
+func (r *memoryTransaction) Forget(key string) bool {
+	r.load(key)
+	r.writes[key] = txWrite{deleted: true}
+	return true
+}
+
+// Flush is not supported inside a transaction.
+func (r *memoryTransaction) Flush() bool {
+	return false
+}
+
+func (r *memoryTransaction) Get(key string, def ...any) any {
+	val, exist := r.load(key)
+	if exist {
+		return val
+	}
+
+	return defaultValue(def...)
+}
+
+func (r *memoryTransaction) GetExists(key string) (any, bool) {
+	return r.load(key)
+}
+
+func (r *memoryTransaction) GetBool(key string, def ...bool) bool {
+	if len(def) == 0 {
+		def = append(def, false)
+	}
+
+	return cast.ToBool(r.Get(key, def[0]))
+}
+
+func (r *memoryTransaction) GetInt(key string, def ...int) int {
+	if len(def) == 0 {
+		def = append(def, 0)
+	}
+
+	return cast.ToInt(r.Get(key, def[0]))
+}
+
+func (r *memoryTransaction) GetInt64(key string, def ...int64) int64 {
+	if len(def) == 0 {
+		def = append(def, 0)
+	}
+
+	return cast.ToInt64(r.Get(key, def[0]))
+}
+
+func (r *memoryTransaction) GetString(key string, def ...string) string {
+	if len(def) == 0 {
+		def = append(def, "")
+	}
+
+	return cast.ToString(r.Get(key, def[0]))
+}
+
+func (r *memoryTransaction) Has(key string) bool {
+	_, exist := r.load(key)
+	return exist
+}
+
+func (r *memoryTransaction) Increment(key string, value ...int64) (int64, error) {
+	if len(value) == 0 {
+		value = append(value, 1)
+	}
+
+	var current int64
+	if val, exist := r.load(key); exist {
+		switch nv := val.(type) {
+		case *atomic.Int64:
+			current = nv.Load()
+		case *atomic.Int32:
+			current = int64(nv.Load())
+		case *int64:
+			current = atomic.LoadInt64(nv)
+		case *int32:
+			current = int64(atomic.LoadInt32(nv))
+		default:
+			return 0, ErrInvalidValueType
+		}
+	}
+
+	nv := current + value[0]
+	r.store(key, &nv, r.keep(key)...)
+
+	return nv, nil
+}
+
+// keep returns the options writing key again with the expiration, cost and priority it has.
+func (r *memoryTransaction) keep(key string) []PutOption {
+	if w, ok := r.writes[key]; ok {
+		return w.opts
+	}
+	read, ok := r.reads[key]
+	if !ok || !read.exist {
+		return nil
+	}
+
+	e := read.entry
+	opts := []PutOption{WithCost(e.cost), WithPriority(e.priority), func(o *putOptions) {
+		o.validate = e.validate
+	}}
+	if !e.expires.IsZero() {
+		opts = append(opts, WithTTL(max(e.expires.Sub(r.memory.now()), time.Nanosecond)))
+	}
+
+	return opts
+}
+
+// Lock returns a lock on the underlying driver, locks are not transactional.
+func (r *memoryTransaction) Lock(key string, t ...time.Duration) *Lock {
+	return r.memory.Lock(key, t...)
+}
+
+func (r *memoryTransaction) Pipeline() *Pipeline {
+	return NewPipeline(r)
+}
+
+func (r *memoryTransaction) Put(key string, value any, opts ...PutOption) error {
+	r.store(key, value, opts...)
+	return nil
+}
+
+func (r *memoryTransaction) Pull(key string, def ...any) any {
+	res := r.Get(key, def...)
+	r.Forget(key)
+
+	return res
+}
+
+func (r *memoryTransaction) Remember(key string, ttl time.Duration, callback func() (any, error)) (any, error) {
+	if val, exist := r.load(key); exist {
+		return val, nil
+	}
+
+	val, err := safeCall(callback)
+	if err != nil {
+		return nil, err
+	}
+
+	r.store(key, val, WithTTL(ttl))
+	return val, nil
+}
+
+func (r *memoryTransaction) RememberForever(key string, callback func() (any, error)) (any, error) {
+	return r.Remember(key, NoExpiration, callback)
+}
+
+// Transaction joins the running transaction.
+func (r *memoryTransaction) Transaction(_ context.Context, fn func(tx Cache) error) error {
+	return fn(r)
+}
+
+// WithContext returns a view of the transaction bound to ctx, staging into the same writes.
+func (r *memoryTransaction) WithContext(ctx context.Context) Cache {
+	tx := *r
+	tx.ctx = ctx
+
+	return &tx
+}
+
+// snapshotValue captures the current number behind counter pointers, since they
+// are mutated in place and can't be compared by identity.
+func snapshotValue(val any) any {
+	if n, ok := counterValue(val); ok {
+		return n
+	}
+
+	return val
+}
+
+// counterValue returns the number behind a counter pointer, reporting whether val is one.
+func counterValue(val any) (int64, bool) {
+	switch nv := val.(type) {
+	case *atomic.Int64:
+		return nv.Load(), true
+	case *atomic.Int32:
+		return int64(nv.Load()), true
+	case *int64:
+		return atomic.LoadInt64(nv), true
+	case *int32:
+		return int64(atomic.LoadInt32(nv)), true
+	default:
+		return 0, false
+	}
+}
+
+// sameValue reports whether the stored value still matches a snapshot taken by snapshotValue.
+func sameValue(snapshot, val any) (same bool) {
+	defer func() {
+		// Values holding uncomparable types are treated as changed.
+		if recover() != nil {
+			same = false
+		}
+	}()
+
+	return snapshot == snapshotValue(val)
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+)
+
+// TransformFunc changes a value on its way to or from the cache.
+type TransformFunc func(key string, value any) (any, error)
+
+// Transform is a middleware passing the values written through onWrite before they're stored,
+// e.g. to redact fields before they reach a shared backend, and the values read through onRead
+// before they're returned, e.g. to inject computed fields. Either can be nil. A failing write
+// transform fails the write, a failing read transform reports a miss.
+func Transform(onWrite, onRead TransformFunc) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Op) (any, error) {
+			switch op.Name {
+			case "Add", "Forever", "Put":
+				if onWrite != nil {
+					val, err := onWrite(op.Key, op.Value)
+					if err != nil {
+						return nil, err
+					}
+					op.Value = val
+				}
+				return next(ctx, op)
+			case "Remember", "RememberForever":
+				if onWrite != nil {
+					callback := op.Callback
+					op.Callback = func() (any, error) {
+						val, err := callback()
+						if err != nil {
+							return nil, err
+						}
+						return onWrite(op.Key, val)
+					}
+				}
+			case "Get", "GetExists", "Pull":
+			default:
+				return next(ctx, op)
+			}
+
+			res, err := next(ctx, op)
+			if err != nil || onRead == nil || res == nil {
+				return res, err
+			}
+			return onRead(op.Key, res)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"time"
+)
+
+// UniqueSeries estimates the distinct items seen in a sliding window, see UniqueCounter.
+type UniqueSeries struct {
+	series Series
+	window time.Duration
+}
+
+// UniqueCounter returns a counter of the distinct items seen under key within the last window,
+// such as the users active in the last 15 minutes. Items are added to a HyperLogLog per bucket,
+// the buckets overlapping the window being merged by Count, so the window slides by bucket and
+// the count is an estimate within about 1%. Buckets are stored under key, a colon and their index,
+// for the window and a bucket more. Stores implementing DistinctCounter count them natively.
+func UniqueCounter(instance Cache, key string, bucket, window time.Duration) *UniqueSeries {
+	return &UniqueSeries{
+		series: Series{cache: instance, key: key, bucket: bucket, retention: window + bucket},
+		window: window,
+	}
+}
+
+// Add records items as seen now.
+func (r *UniqueSeries) Add(items ...string) error {
+	now := r.series.now()
+	index := now.UnixNano() / int64(r.series.bucket)
+	key := r.series.bucketKey(index)
+
+	// Buckets are updated in one step with what's left of their retention since they started,
+	// so they can't expire in between and be created again without an expiration.
+	start := time.Unix(0, index*int64(r.series.bucket))
+	ttl := max(start.Add(r.series.retention).Sub(now), time.Nanosecond)
+
+	return Update(r.series.cache, key, func(old any, exists bool) (any, bool) {
+		hll := NewHyperLogLog()
+		if prev, ok := old.(*HyperLogLog); ok {
+			hll = prev.Clone().(*HyperLogLog)
+		}
+		return hll, hll.Add(items...) || !exists
+	}, WithTTL(ttl))
+}
+
+// Count returns the estimated number of distinct items seen within the window.
+func (r *UniqueSeries) Count() (int64, error) {
+	n := max(int64((r.window+r.series.bucket-1)/r.series.bucket), 1)
+	current := r.series.now().UnixNano() / int64(r.series.bucket)
+
+	keys := make([]string, 0, n)
+	for index := current - n + 1; index <= current; index++ {
+		keys = append(keys, r.series.bucketKey(index))
+	}
+
+	if counter, ok := r.series.cache.(DistinctCounter); ok {
+		return counter.PFCount(keys...)
+	}
+
+	merged := NewHyperLogLog()
+	for _, key := range keys {
+		if hll, ok := r.series.cache.Get(key).(*HyperLogLog); ok {
+			merged.Merge(hll)
+		}
+	}
+
+	return merged.Count(), nil
+}
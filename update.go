@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Update applies a read-modify-write to the item of key atomically: fn is given the current item,
+// and whether it exists, and returns the item to store with opts, or false to leave it as is.
+// fn is called again with the new item when another writer changed it meanwhile,
+// so it must not have side effects.
+func Update(instance Cache, key string, fn func(old any, exists bool) (any, bool), opts ...PutOption) error {
+	for {
+		err := instance.Transaction(context.Background(), func(tx Cache) error {
+			old, exists := tx.GetExists(key)
+			val, keep := fn(old, exists)
+			if !keep {
+				return nil
+			}
+
+			return tx.Put(key, val, opts...)
+		})
+		if !errors.Is(err, ErrTransactionConflict) {
+			return err
+		}
+	}
+}
+
+// incrementCounter adds delta to the counter of key in one step, creating it to expire after ttl
+// when missing, while an existing counter keeps its expiration. Unlike Add then Increment, the
+// counter can't expire in between and be created again without an expiration.
+func incrementCounter(instance Cache, key string, delta int64, ttl time.Duration) (int64, error) {
+	var n int64
+	for {
+		err := instance.Transaction(context.Background(), func(tx Cache) error {
+			tx.Add(key, new(int64), ttl)
+
+			var err error
+			n, err = tx.Increment(key, delta)
+			return err
+		})
+		if !errors.Is(err, ErrTransactionConflict) {
+			return n, err
+		}
+	}
+}
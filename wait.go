@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Waiter is implemented by stores that are notified when a key is stored.
+type Waiter interface {
+	// WaitFor blocks until an item is stored under key, returning it.
+	WaitFor(ctx context.Context, key string, timeout time.Duration) (any, error)
+}
+
+// WaitFor blocks until an item is stored under key, returning it right away if it exists.
+// It returns ErrWaitTimeout after timeout, or the context error once ctx is done. Stores that
+// aren't a Waiter are polled every 50 milliseconds.
+func WaitFor(ctx context.Context, instance Cache, key string, timeout time.Duration) (any, error) {
+	if waiter, ok := instance.(Waiter); ok {
+		return waiter.WaitFor(ctx, key, timeout)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if val, exist := instance.GetExists(key); exist {
+			return val, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, ErrWaitTimeout
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"context"
+)
+
+// EventType is the kind of change reported by Watch.
+type EventType int
+
+const (
+	// EventSet means an item was stored under the key.
+	EventSet EventType = iota
+	// EventDelete means the item was forgotten, flushed or evicted.
+	EventDelete
+	// EventExpire means the item expired.
+	EventExpire
+)
+
+// Event is a change of a watched key.
+type Event struct {
+	Type EventType
+	Key  string
+	// Value is the item stored, for EventSet only.
+	Value any
+}
+
+// Watcher is implemented by stores that report the changes of their keys.
+type Watcher interface {
+	// Watch returns a channel receiving the changes of key until ctx is done.
+	Watch(ctx context.Context, key string) (<-chan Event, error)
+}